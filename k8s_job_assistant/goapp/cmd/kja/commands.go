@@ -0,0 +1,515 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"goapp/pkg/client"
+	"goapp/pkg/model"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// pollInterval is how often 'wait' checks the run state
+var pollInterval = 2 * time.Second
+
+func listCmd(ctx context.Context, api *client.Client, args []string) (int, error) {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	output := outputFlag(flags)
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return exitError, err
+	}
+
+	jobs, err := api.List(ctx)
+	if err != nil {
+		return exitError, err
+	}
+	return exitOK, printOutput(os.Stdout, *output, jobs, func() [][]string { return jobsTable(jobs.Jobs) })
+}
+
+func statusCmd(ctx context.Context, api *client.Client, args []string) (int, error) {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	output := outputFlag(flags)
+	namespace, name, err := parseJobArgs(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+
+	job, err := api.Status(ctx, namespace, name)
+	if err != nil {
+		return exitError, err
+	}
+	return exitOK, printOutput(os.Stdout, *output, job, func() [][]string { return jobsTable([]model.DecoratedJob{*job}) })
+}
+
+func runsCmd(ctx context.Context, api *client.Client, args []string) (int, error) {
+	flags := flag.NewFlagSet("runs", flag.ContinueOnError)
+	output := outputFlag(flags)
+	namespace, name, err := parseJobArgs(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+
+	runs, err := api.Runs(ctx, namespace, name)
+	if err != nil {
+		return exitError, err
+	}
+	return exitOK, printOutput(os.Stdout, *output, runs, func() [][]string { return runsTable(runs.Runs) })
+}
+
+func runCmd(ctx context.Context, api *client.Client, args []string) (int, error) {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	wait := flags.Bool("wait", false, "wait for the run to finish, the exit code reflects its outcome")
+	timeout := flags.Duration("timeout", 0, "with -wait, give up waiting after this duration (0 waits forever)")
+	overrides := overrideFlags(flags)
+	namespace, name, err := parseJobArgs(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+	opts, err := overrides()
+	if err != nil {
+		return exitError, err
+	}
+
+	queued, err := api.Run(ctx, namespace, name, opts)
+	if err != nil {
+		return exitError, err
+	}
+	if queued != nil {
+		fmt.Fprintf(os.Stderr, "job %s/%s is running, run queued at position %d\n", namespace, name, queued.Position)
+		if *wait {
+			return exitError, fmt.Errorf("-wait can not follow queued runs, cancel it with: kja dequeue -id %s %s/%s", queued.ID, namespace, name)
+		}
+		return exitOK, nil
+	}
+	job, err := api.Status(ctx, namespace, name)
+	if err != nil {
+		return exitError, err
+	}
+	fmt.Fprintf(os.Stderr, "job %s/%s started, run %s\n", namespace, name, orNone(job.RunID))
+
+	if !*wait {
+		return exitOK, nil
+	}
+	return waitForRun(ctx, api, namespace, name, job.RunID, *timeout)
+}
+
+// overrideFlags defines the flags overriding the Job fields for a run, the returned func reads them once parsed
+func overrideFlags(flags *flag.FlagSet) func() (client.RunOptions, error) {
+	container := flags.String("container", "", "container -image-tag, -arg and the resources apply to, defaults to the first one")
+	imageTag := flags.String("image-tag", "", "override the image tag of the container")
+	var args, nodeSelector listFlag
+	flags.Var(&args, "arg", "override the args of the container, repeat it for each arg")
+	resources := map[string]*string{}
+	for _, name := range []string{"cpu-request", "cpu-limit", "memory-request", "memory-limit"} {
+		resources[name] = flags.String(name, "", "override the "+strings.ReplaceAll(name, "-", " ")+" of the container, ie: 4Gi")
+	}
+	parallelism := flags.Int("parallelism", -1, "override the parallelism of the Job")
+	completions := flags.Int("completions", -1, "override the completions of the Job")
+	flags.Var(&nodeSelector, "node-selector", "override the node selector of the pods, key=value, repeat it for each label")
+
+	return func() (client.RunOptions, error) {
+		overrides := &model.RunOverrides{Container: *container, ImageTag: *imageTag, Args: args}
+		for name, value := range resources {
+			if *value == "" {
+				continue
+			}
+			resource, kind, _ := strings.Cut(name, "-")
+			target := &overrides.Requests
+			if kind == "limit" {
+				target = &overrides.Limits
+			}
+			if *target == nil {
+				*target = map[string]string{}
+			}
+			(*target)[resource] = *value
+		}
+		if *parallelism >= 0 {
+			overrides.Parallelism = ptr(int32(*parallelism))
+		}
+		if *completions >= 0 {
+			overrides.Completions = ptr(int32(*completions))
+		}
+		for _, selector := range nodeSelector {
+			key, value, found := strings.Cut(selector, "=")
+			if !found {
+				return client.RunOptions{}, fmt.Errorf("invalid -node-selector %q, expected key=value", selector)
+			}
+			if overrides.NodeSelector == nil {
+				overrides.NodeSelector = map[string]string{}
+			}
+			overrides.NodeSelector[key] = value
+		}
+		if reflect.ValueOf(*overrides).IsZero() {
+			return client.RunOptions{}, nil
+		}
+		return client.RunOptions{Overrides: overrides}, nil
+	}
+}
+
+// listFlag is a flag which can be repeated
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func queueCmd(ctx context.Context, api *client.Client, args []string) (int, error) {
+	flags := flag.NewFlagSet("queue", flag.ContinueOnError)
+	output := outputFlag(flags)
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return exitError, err
+	}
+
+	queued, err := api.Queue(ctx)
+	if err != nil {
+		return exitError, err
+	}
+	return exitOK, printOutput(os.Stdout, *output, queued, func() [][]string { return queueTable(queued.Runs) })
+}
+
+func dequeueCmd(ctx context.Context, api *client.Client, args []string) (int, error) {
+	flags := flag.NewFlagSet("dequeue", flag.ContinueOnError)
+	id := flags.String("id", "", "ID of the queued run, see 'kja queue'")
+	namespace, name, err := parseJobArgs(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+	if *id == "" {
+		return exitError, errors.New("-id is required")
+	}
+
+	if err := api.CancelQueuedRun(ctx, namespace, name, *id); err != nil {
+		return exitError, err
+	}
+	fmt.Fprintf(os.Stderr, "queued run %s of job %s/%s cancelled\n", *id, namespace, name)
+	return exitOK, nil
+}
+
+func killCmd(ctx context.Context, api *client.Client, args []string) (int, error) {
+	flags := flag.NewFlagSet("kill", flag.ContinueOnError)
+	opts := killFlags(flags)
+	namespace, name, err := parseJobArgs(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+
+	if err = api.Kill(ctx, namespace, name, opts()); err != nil {
+		return exitError, err
+	}
+	fmt.Fprintf(os.Stderr, "job %s/%s killed\n", namespace, name)
+	return exitOK, nil
+}
+
+func restartCmd(ctx context.Context, api *client.Client, args []string) (int, error) {
+	flags := flag.NewFlagSet("restart", flag.ContinueOnError)
+	opts := killFlags(flags)
+	namespace, name, err := parseJobArgs(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+
+	if err = api.Restart(ctx, namespace, name, opts()); err != nil {
+		return exitError, err
+	}
+	fmt.Fprintf(os.Stderr, "job %s/%s restarted\n", namespace, name)
+	return exitOK, nil
+}
+
+func retryFailedCmd(ctx context.Context, api *client.Client, args []string) (int, error) {
+	flags := flag.NewFlagSet("retry-failed", flag.ContinueOnError)
+	output := outputFlag(flags)
+	namespace, name, err := parseJobArgs(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+
+	retried, err := api.RetryFailedIndexes(ctx, namespace, name)
+	if err != nil {
+		return exitError, err
+	}
+	fmt.Fprintf(os.Stderr, "job %s/%s: retrying %d failed index(es)\n", namespace, name, retried.Count)
+	return exitOK, printOutput(os.Stdout, *output, retried, func() [][]string { return retriedTable(namespace, name, retried.Indexes) })
+}
+
+// killFlags defines the flags telling how to kill a run, the returned func reads them once parsed
+func killFlags(flags *flag.FlagSet) func() client.KillOptions {
+	mode := flags.String("mode", "", "graceful, force or escalate, defaults to graceful")
+	grace := flags.Duration("grace", -1, "grace period of the pods, defaults to their terminationGracePeriodSeconds")
+	escalateAfter := flags.Duration("escalate-after", 0, "how long the escalate mode waits before forcing the kill, defaults to 30s")
+	reason := flags.String("reason", "", "reason shown in the status of the Job")
+	return func() client.KillOptions {
+		opts := client.KillOptions{Mode: *mode, EscalateAfter: *escalateAfter, Reason: *reason}
+		if *grace >= 0 {
+			opts.GracePeriod = grace
+		}
+		return opts
+	}
+}
+
+// logFilterFlags declares the flags filtering the lines of the logs, the returned func reads them
+func logFilterFlags(flags *flag.FlagSet) func() (client.LogFilter, error) {
+	grep := flags.String("grep", "", "only print the lines containing this text")
+	regex := flags.String("regex", "", "only print the lines matching this regular expression, ie: '(?i)timeout|refused'")
+	level := flags.String("level", "", "only print the JSON lines whose level field is one of these, ie: error,warn")
+	since := flags.String("since", "", "only print the lines logged from this RFC3339 time, or this duration ago, ie: 15m")
+	until := flags.String("until", "", "only print the lines logged up to this RFC3339 time, or this duration ago")
+	timestamps := flags.Bool("timestamps", false, "start each line with the time it was logged at")
+	return func() (client.LogFilter, error) {
+		filter := client.LogFilter{Grep: *grep, Regex: *regex, Timestamps: *timestamps}
+		if *level != "" {
+			filter.Levels = strings.Split(*level, ",")
+		}
+		var err error
+		if filter.Since, err = parseLogTime("since", *since); err != nil {
+			return filter, err
+		}
+		filter.Until, err = parseLogTime("until", *until)
+		return filter, err
+	}
+}
+
+// parseLogTime parses an RFC3339 time or a duration ago, the zero time when value is empty
+func parseLogTime(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ago, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-ago), nil
+	}
+	at, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -%s %q, expected an RFC3339 time or a duration ago, ie: 15m", name, value)
+	}
+	return at, nil
+}
+
+func logsCmd(ctx context.Context, api *client.Client, args []string) (int, error) {
+	flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+	follow := flags.Bool("f", false, "follow the logs while the run is going on")
+	container := flags.String("c", "", "container to print the logs of, defaults to the first one")
+	tail := flags.Int("tail", -1, "only print the last lines, all lines when negative")
+	runID := flags.String("run", "", "print the archived logs of this past run instead")
+	pod := flags.String("pod", "", "pod to print the logs of, defaults to the most recent one (all with -run)")
+	filter := logFilterFlags(flags)
+	namespace, name, err := parseJobArgs(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+	logFilter, err := filter()
+	if err != nil {
+		return exitError, err
+	}
+	if *runID != "" {
+		return archivedLogsCmd(ctx, api, namespace, name, *runID, *pod, *container, logFilter)
+	}
+
+	opts := client.LogOptions{Container: *container, Pod: *pod, Follow: *follow, Filter: logFilter}
+	if *tail >= 0 {
+		tailLines := int64(*tail)
+		opts.TailLines = &tailLines
+	}
+
+	logs, err := api.Logs(ctx, namespace, name, opts)
+	if err != nil {
+		return exitError, err
+	}
+	defer logs.Close()
+
+	if _, err = io.Copy(os.Stdout, logs); err != nil && ctx.Err() == nil {
+		return exitError, err
+	}
+	return exitOK, nil
+}
+
+// archivedLogsCmd prints the archived logs of the containers of a run, each preceded by a
+// "==> pod/container <==" header
+func archivedLogsCmd(ctx context.Context, api *client.Client, namespace, name, runID, pod, container string, filter client.LogFilter) (int, error) {
+	archived, err := api.ArchivedLogs(ctx, namespace, name, runID)
+	if err != nil {
+		return exitError, err
+	}
+	printed := 0
+	for _, log := range archived.Logs {
+		if (pod != "" && log.Pod != pod) || (container != "" && log.Container != container) {
+			continue
+		}
+		logs, err := api.ArchivedLog(ctx, namespace, name, runID, log.Pod, log.Container, filter)
+		if err != nil {
+			return exitError, err
+		}
+		fmt.Printf("==> %s/%s <==\n", log.Pod, log.Container)
+		_, err = io.Copy(os.Stdout, logs)
+		logs.Close()
+		if err != nil {
+			return exitError, err
+		}
+		printed++
+	}
+	if printed == 0 {
+		return exitError, fmt.Errorf("no archived logs for run %s of %s/%s", runID, namespace, name)
+	}
+	return exitOK, nil
+}
+
+func bundleCmd(ctx context.Context, api *client.Client, args []string) (int, error) {
+	flags := flag.NewFlagSet("bundle", flag.ContinueOnError)
+	runID := flags.String("run", "", "run to bundle, defaults to the current one")
+	format := flags.String("format", "zip", "archive format: zip or tar.gz")
+	out := flags.String("out", "", "file to write, defaults to <namespace>-<name>-<run>.<format>")
+	namespace, name, err := parseJobArgs(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+	if *runID == "" {
+		job, err := api.Status(ctx, namespace, name)
+		if err != nil {
+			return exitError, err
+		}
+		if job.RunID == "" {
+			return exitError, fmt.Errorf("%s/%s was never run through KJA, use -run", namespace, name)
+		}
+		*runID = job.RunID
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("%s-%s-%s.%s", namespace, name, *runID, *format)
+	}
+
+	bundle, err := api.Bundle(ctx, namespace, name, *runID, *format)
+	if err != nil {
+		return exitError, err
+	}
+	defer bundle.Close()
+	file, err := os.Create(*out)
+	if err != nil {
+		return exitError, err
+	}
+	if _, err = io.Copy(file, bundle); err != nil {
+		file.Close()
+		return exitError, err
+	}
+	if err := file.Close(); err != nil {
+		return exitError, err
+	}
+	fmt.Println(*out)
+	return exitOK, nil
+}
+
+func waitCmd(ctx context.Context, api *client.Client, args []string) (int, error) {
+	flags := flag.NewFlagSet("wait", flag.ContinueOnError)
+	timeout := flags.Duration("timeout", 0, "give up waiting after this duration (0 waits forever)")
+	namespace, name, err := parseJobArgs(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+	return waitForRun(ctx, api, namespace, name, "", *timeout)
+}
+
+// waitForRun polls the runs of the Job until runID (the current run when empty) finishes.
+func waitForRun(ctx context.Context, api *client.Client, namespace, name, runID string, timeout time.Duration) (int, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		runs, err := api.Runs(ctx, namespace, name)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return exitTimeout, fmt.Errorf("run did not finish within %s", timeout)
+		}
+		if err != nil {
+			return exitError, err
+		}
+
+		if len(runs.Runs) == 0 {
+			return exitError, fmt.Errorf("job %s/%s has never run", namespace, name)
+		}
+		run := runs.Runs[0]
+		if runID != "" && run.ID != runID {
+			return exitError, fmt.Errorf("run %s has been replaced by run %s", runID, run.ID)
+		}
+		if run.State.Finished() {
+			fmt.Fprintf(os.Stderr, "run %s of job %s/%s %s\n", orNone(run.ID), namespace, name, strings.ToLower(string(run.State)))
+			return runExitCode(run.State), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return exitTimeout, fmt.Errorf("run did not finish within %s", timeout)
+			}
+			return exitError, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func runExitCode(state model.RunState) int {
+	switch state {
+	case model.RunFailed:
+		return exitFailed
+	case model.RunKilled:
+		return exitKilled
+	default:
+		return exitOK
+	}
+}
+
+func outputFlag(flags *flag.FlagSet) *string {
+	return flags.String("o", outputTable, "output format: table, json or yaml")
+}
+
+// parseJobArgs parses flags and the 'namespace/name' positional argument, flags can
+// be given before or after it.
+func parseJobArgs(flags *flag.FlagSet, args []string) (namespace, name string, err error) {
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return "", "", err
+	}
+	namespace, name, ok := strings.Cut(positional[0], "/")
+	if !ok || namespace == "" || name == "" {
+		return "", "", fmt.Errorf("invalid job %q, expected namespace/name", positional[0])
+	}
+	return namespace, name, nil
+}
+
+// parseArgs parses flags placed anywhere among args and checks the number of positional arguments.
+func parseArgs(flags *flag.FlagSet, args []string, expected int) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	if len(positional) != expected {
+		return nil, fmt.Errorf("expected %d argument(s), got %d", expected, len(positional))
+	}
+	if output := flags.Lookup("o"); output != nil {
+		if err := validOutput(output.Value.String()); err != nil {
+			return nil, err
+		}
+	}
+	return positional, nil
+}
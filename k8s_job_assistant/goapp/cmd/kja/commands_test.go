@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/pkg/client"
+	"goapp/pkg/model"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeRunsServer answers /runs with the given states, one per call, then sticks to the last one
+func fakeRunsServer(t *testing.T, states ...model.RunState) *httptest.Server {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/runs/billing/nightly" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"jobs.batch \"nightly\" not found"}`))
+			return
+		}
+		i := int(calls.Add(1)) - 1
+		if i >= len(states) {
+			i = len(states) - 1
+		}
+		_ = json.NewEncoder(w).Encode(model.ListRuns{Runs: []model.Run{{ID: "run-1", State: states[i]}}, Count: 1})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestClient(t *testing.T, server string) *client.Client {
+	api, err := client.New(server)
+	require.NoError(t, err)
+	return api
+}
+
+func TestWaitForRunExitCodes(t *testing.T) {
+	pollInterval = 10 * time.Millisecond
+
+	for state, expected := range map[model.RunState]int{
+		model.RunSucceeded: exitOK,
+		model.RunFailed:    exitFailed,
+		model.RunKilled:    exitKilled,
+	} {
+		t.Run(string(state), func(t *testing.T) {
+			api := newTestClient(t, fakeRunsServer(t, model.RunPending, model.RunRunning, state).URL)
+			code, err := waitForRun(context.Background(), api, "billing", "nightly", "run-1", 0)
+			require.NoError(t, err)
+			assert.Equal(t, expected, code)
+		})
+	}
+}
+
+func TestWaitForRunTimeout(t *testing.T) {
+	pollInterval = 10 * time.Millisecond
+	api := newTestClient(t, fakeRunsServer(t, model.RunRunning).URL)
+
+	code, err := waitForRun(context.Background(), api, "billing", "nightly", "", 50*time.Millisecond)
+	assert.Error(t, err)
+	assert.Equal(t, exitTimeout, code)
+}
+
+func TestWaitForRunUnknownJob(t *testing.T) {
+	api := newTestClient(t, fakeRunsServer(t, model.RunRunning).URL)
+
+	code, err := waitForRun(context.Background(), api, "billing", "unknown", "", 0)
+	var notFound *client.NotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Equal(t, http.StatusNotFound, notFound.StatusCode)
+	assert.Equal(t, exitError, code)
+}
+
+func TestParseJobArgs(t *testing.T) {
+	flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+	follow := flags.Bool("f", false, "")
+	namespace, name, err := parseJobArgs(flags, []string{"billing/nightly", "-f"})
+	require.NoError(t, err)
+	assert.Equal(t, "billing", namespace)
+	assert.Equal(t, "nightly", name)
+	assert.True(t, *follow)
+
+	_, _, err = parseJobArgs(flag.NewFlagSet("status", flag.ContinueOnError), []string{"nightly"})
+	assert.Error(t, err)
+
+	flags = flag.NewFlagSet("status", flag.ContinueOnError)
+	outputFlag(flags)
+	_, _, err = parseJobArgs(flags, []string{"-o", "xml", "billing/nightly"})
+	assert.Error(t, err)
+}
+
+func TestOverrideFlags(t *testing.T) {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	overrides := overrideFlags(flags)
+	_, _, err := parseJobArgs(flags, []string{"-memory-limit", "4Gi", "-arg", "--from", "-arg", "2025-06-01", "-parallelism", "0", "billing/nightly"})
+	require.NoError(t, err)
+	opts, err := overrides()
+	require.NoError(t, err)
+	assert.Equal(t, &model.RunOverrides{
+		Args:        []string{"--from", "2025-06-01"},
+		Limits:      map[string]string{"memory": "4Gi"},
+		Parallelism: ptr(int32(0)),
+	}, opts.Overrides)
+
+	flags = flag.NewFlagSet("run", flag.ContinueOnError)
+	overrides = overrideFlags(flags)
+	_, _, err = parseJobArgs(flags, []string{"billing/nightly"})
+	require.NoError(t, err)
+	opts, err = overrides()
+	require.NoError(t, err)
+	assert.Nil(t, opts.Overrides, "no flag runs the Job as defined")
+}
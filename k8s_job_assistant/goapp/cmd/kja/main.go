@@ -0,0 +1,112 @@
+// Command kja is a command line client of the Kubernetes Job Assistant HTTP API.
+//
+// It performs the same guarded operations as the KJA UI, without requiring any
+// kubectl access to the cluster:
+//
+//	kja [-server URL] <command> [flags] [namespace/name]
+//
+// Exit codes reflect the outcome of the command, and of the Job run for 'wait'
+// and 'run -wait': see the exit* constants.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"goapp/pkg/client"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const (
+	exitOK      = 0
+	exitError   = 1 // invalid usage or KJA API error
+	exitFailed  = 2 // the run failed
+	exitKilled  = 3 // the run was killed
+	exitTimeout = 4 // the run did not finish within -timeout
+)
+
+// command runs a subcommand with its arguments and returns the exit code
+type command struct {
+	usage string
+	run   func(ctx context.Context, api *client.Client, args []string) (int, error)
+}
+
+var commands = map[string]command{
+	"list":         {usage: "list managed Jobs", run: listCmd},
+	"status":       {usage: "show the status of a Job", run: statusCmd},
+	"run":          {usage: "run a Job, optionally waiting for its completion", run: runCmd},
+	"kill":         {usage: "kill the running Job, -mode force or escalate to not wait for the pods", run: killCmd},
+	"restart":      {usage: "kill the running Job and run it again with the same params", run: restartCmd},
+	"retry-failed": {usage: "re-run the failed indexes of an Indexed Job, each in a Job of its own", run: retryFailedCmd},
+	"logs":         {usage: "print (or follow with -f) the logs of the current run, or with -run those archived of a past run", run: logsCmd},
+	"runs":         {usage: "list the runs of a Job", run: runsCmd},
+	"bundle":       {usage: "download the logs, manifests and events of a run (-run, defaults to the current one)", run: bundleCmd},
+	"wait":         {usage: "wait for the current run to finish", run: waitCmd},
+	"queue":        {usage: "list the runs queued while their Job was running", run: queueCmd},
+	"dequeue":      {usage: "cancel a queued run (-id)", run: dequeueCmd},
+}
+
+var commandOrder = []string{"list", "status", "run", "kill", "restart", "retry-failed", "logs", "runs", "bundle", "wait", "queue", "dequeue"}
+
+func main() {
+	os.Exit(kja(os.Args[1:]))
+}
+
+func kja(args []string) int {
+	flags := flag.NewFlagSet("kja", flag.ContinueOnError)
+	server := flags.String("server", envOr("KJA_SERVER", "http://localhost:8080"), "KJA server URL, defaults to $KJA_SERVER")
+	token := flags.String("token", os.Getenv("KJA_TOKEN"), "bearer token sent to KJA (or the proxy in front of it), defaults to $KJA_TOKEN")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: kja [-server URL] <command> [flags] [namespace/name]")
+		fmt.Fprintln(flags.Output(), "\nCommands:")
+		for _, name := range commandOrder {
+			fmt.Fprintf(flags.Output(), "  %-8s %s\n", name, commands[name].usage)
+		}
+		fmt.Fprintln(flags.Output(), "\nRun 'kja <command> -h' for the command flags.")
+		fmt.Fprintln(flags.Output(), "\nGlobal flags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitError
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "kja: unknown command %q\n", flags.Arg(0))
+		flags.Usage()
+		return exitError
+	}
+
+	opts := []client.Option{client.WithUserAgent("kja-cli")}
+	if *token != "" {
+		opts = append(opts, client.WithToken(*token))
+	}
+	api, err := client.New(*server, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kja: %v\n", err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	code, err := cmd.run(ctx, api, flags.Args()[1:])
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(os.Stderr, "kja %s: %v\n", flags.Arg(0), err)
+	}
+	return code
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"goapp/pkg/model"
+	"io"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Supported values of the -o flag
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+func validOutput(output string) error {
+	switch output {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	default:
+		return fmt.Errorf("invalid output %q, expected %s, %s or %s", output, outputTable, outputJSON, outputYAML)
+	}
+}
+
+// printOutput writes v in the given output format, table uses the rows returned by table
+func printOutput(w io.Writer, output string, v any, table func() [][]string) error {
+	switch output {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case outputYAML:
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	default:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, row := range table() {
+			for i, cell := range row {
+				if i > 0 {
+					fmt.Fprint(tw, "\t")
+				}
+				fmt.Fprint(tw, cell)
+			}
+			fmt.Fprintln(tw)
+		}
+		return tw.Flush()
+	}
+}
+
+func jobsTable(jobs []model.DecoratedJob) [][]string {
+	rows := [][]string{{"NAMESPACE", "NAME", "STATE", "MESSAGE", "RUN", "START", "DURATION", "PODS", "LAST SUCCESS", "INDEXES"}}
+	for _, job := range jobs {
+		row := []string{job.Namespace, job.Name, orNone(string(job.State)), orNone(job.LastStatus.Message), orNone(job.RunID)}
+		if run := job.CurrentRun; run != nil {
+			if run.FailureReason != "" {
+				row[3] = run.FailureReason
+			}
+			row = append(row, formatTime(run.StartTime), orNone(formatMetaDuration(run.Duration)),
+				fmt.Sprintf("%d active, %d succeeded, %d/%d failed", run.Active, run.Succeeded, run.Failed, run.BackoffLimit))
+		} else {
+			row = append(row, orNone(""), orNone(""), orNone(""))
+		}
+		if job.LastSuccess != nil {
+			row = append(row, formatTime(job.LastSuccess.CompletionTime))
+		} else {
+			row = append(row, orNone(""))
+		}
+		rows = append(rows, append(row, formatIndexes(job.Indexes)))
+	}
+	return rows
+}
+
+func retriedTable(namespace, name string, indexes []int) [][]string {
+	rows := [][]string{{"INDEX", "RETRY JOB"}}
+	for _, index := range indexes {
+		rows = append(rows, []string{strconv.Itoa(index), fmt.Sprintf("%s/%s-retry-%d", namespace, name, index)})
+	}
+	return rows
+}
+
+// formatIndexes summarizes the indexes of Indexed Jobs, ie: '6/8 failed:3,7 retried:1'
+func formatIndexes(indexes *model.Indexes) string {
+	if indexes == nil {
+		return orNone("")
+	}
+	summary := fmt.Sprintf("%d/%d", indexes.SucceededCount, indexes.Completions)
+	if indexes.Failed != "" {
+		summary += " failed:" + indexes.Failed
+	}
+	if len(indexes.Retries) > 0 {
+		summary += fmt.Sprintf(" retried:%d", len(indexes.Retries))
+	}
+	return summary
+}
+
+func runsTable(runs []model.Run) [][]string {
+	rows := [][]string{{"RUN", "STATE", "TRIGGERED BY", "START", "COMPLETION", "DURATION", "PARAMS", "EXIT CODES"}}
+	for _, run := range runs {
+		rows = append(rows, []string{
+			orNone(run.ID),
+			string(run.State),
+			orNone(run.TriggeredBy),
+			formatTime(run.StartTime),
+			formatTime(run.CompletionTime),
+			formatDuration(run.StartTime, run.CompletionTime),
+			formatParams(run.Params),
+			formatExitCodes(run.ExitCodes),
+		})
+	}
+	return rows
+}
+
+// formatExitCodes shows each distinct container=code of the pods of a run, ie: main=3,main=0
+func formatExitCodes(exitCodes []model.ExitCode) string {
+	var codes []string
+	for _, exitCode := range exitCodes {
+		code := fmt.Sprintf("%s=%d", exitCode.Container, exitCode.ExitCode)
+		if !slices.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+	return orNone(strings.Join(codes, ","))
+}
+
+// formatParams shows params sorted by name, ie: DATE=2025-06-02,DRY_RUN=true
+func formatParams(values map[string]string) string {
+	params := make([]string, 0, len(values))
+	for name, value := range values {
+		params = append(params, name+"="+value)
+	}
+	sort.Strings(params)
+	return orNone(strings.Join(params, ","))
+}
+
+func queueTable(queued []model.QueuedRun) [][]string {
+	rows := [][]string{{"NAMESPACE", "NAME", "POSITION", "ID", "TRIGGERED BY", "QUEUED", "PARAMS"}}
+	for _, run := range queued {
+		rows = append(rows, []string{
+			run.Namespace,
+			run.Name,
+			strconv.Itoa(run.Position),
+			run.ID,
+			orNone(run.TriggeredBy),
+			formatTime(run.QueuedAt),
+			formatParams(run.Params),
+		})
+	}
+	return rows
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+func formatTime(t *metav1.Time) string {
+	if t == nil {
+		return "<none>"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+func formatMetaDuration(d *metav1.Duration) string {
+	if d == nil {
+		return ""
+	}
+	return d.Duration.String()
+}
+
+func formatDuration(start, end *metav1.Time) string {
+	if start == nil {
+		return "<none>"
+	}
+	if end == nil {
+		return time.Since(start.Time).Round(time.Second).String()
+	}
+	return end.Sub(start.Time).Round(time.Second).String()
+}
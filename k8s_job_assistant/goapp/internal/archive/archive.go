@@ -0,0 +1,80 @@
+// Package archive keeps the logs of the runs of managed Jobs, which are lost when the Job is
+// re-created or its pods deleted. Backends store objects by key, see NewFilesystem and NewS3.
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Backends selectable with the -archive flag
+const (
+	BackendNone       = "none"
+	BackendFilesystem = "filesystem"
+	BackendS3         = "s3"
+)
+
+// ErrNotFound is returned when getting an object which is not archived
+var ErrNotFound = errors.New("not archived")
+
+// Object is an archived object
+type Object struct {
+	Key  string
+	Size int64
+}
+
+type Backend interface {
+	// Put stores size bytes read from body under key, replacing any previous object
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+	// Get returns an object and its size, ErrNotFound if there is none. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, int64, error)
+	// List returns the objects whose key starts with prefix, sorted by key
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+// logSuffix ends the keys of the archived logs
+const logSuffix = ".log"
+
+// RunPrefix is the prefix of the keys of the archived logs of a run, ie: 'billing/nightly/20250602-030000-4f2a9c/'
+func RunPrefix(namespace, name, runID string) (string, error) {
+	for _, part := range []string{namespace, name, runID} {
+		if err := checkKeyPart(part); err != nil {
+			return "", err
+		}
+	}
+	return namespace + "/" + name + "/" + runID + "/", nil
+}
+
+// LogKey is the key of the archived logs of a container of a pod of a run, ie:
+// 'billing/nightly/20250602-030000-4f2a9c/nightly-x2x4d/main.log'
+func LogKey(namespace, name, runID, pod, container string) (string, error) {
+	prefix, err := RunPrefix(namespace, name, runID)
+	if err != nil {
+		return "", err
+	}
+	for _, part := range []string{pod, container} {
+		if err := checkKeyPart(part); err != nil {
+			return "", err
+		}
+	}
+	return prefix + pod + "/" + container + logSuffix, nil
+}
+
+// ParseLogKey returns the pod and container of a log key relative to its RunPrefix
+func ParseLogKey(key string) (pod, container string, ok bool) {
+	pod, file, ok := strings.Cut(key, "/")
+	container, isLog := strings.CutSuffix(file, logSuffix)
+	return pod, container, ok && isLog && pod != "" && container != "" && !strings.Contains(container, "/")
+}
+
+// checkKeyPart rejects parts of keys which could escape their prefix: parts are Kubernetes
+// names and run IDs, which contain neither slashes nor start with a dot
+func checkKeyPart(part string) error {
+	if part == "" || strings.ContainsAny(part, "/\\") || strings.HasPrefix(part, ".") {
+		return fmt.Errorf("invalid archive key part %q", part)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Package audit records who did what on managed Jobs
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"goapp/internal/logging"
+	"goapp/internal/metrics"
+	"goapp/pkg/model"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Entry is one audited action on a managed Job, as returned by the API.
+type Entry = model.AuditEntry
+
+type reasonKey struct{}
+
+// WithReason returns a context whose audited actions record why they were performed
+func WithReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, reasonKey{}, reason)
+}
+
+type Logger interface {
+	// Record audits an action performed within ctx, user, request ID and reason are taken from ctx.
+	Record(ctx context.Context, action, namespace, name, outcome string, err error)
+	// Close flushes pending entries and stops accepting new ones.
+	Close(ctx context.Context) error
+}
+
+// Sink keeps audit entries besides the JSON lines, ie: store.Store
+type Sink interface {
+	AddAuditEntry(ctx context.Context, entry Entry) error
+}
+
+// sinkTimeout bounds the recording of an entry in a Sink
+const sinkTimeout = 10 * time.Second
+
+// jsonLogger writes entries as JSON lines from a single goroutine so callers are
+// never slowed down by the audit sink. Entries are dropped when the buffer is full.
+type jsonLogger struct {
+	w       io.Writer
+	sinks   []Sink
+	entries chan Entry
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewJSONLogger returns a Logger writing one JSON entry per line to w, and adding them to
+// sinks, buffering up to bufferSize entries. Entries recorded while the buffer is full are dropped.
+func NewJSONLogger(w io.Writer, bufferSize int, sinks ...Sink) Logger {
+	l := &jsonLogger{w: w, sinks: sinks, entries: make(chan Entry, bufferSize), done: make(chan struct{})}
+	go l.write()
+	return l
+}
+
+func (l *jsonLogger) Record(ctx context.Context, action, namespace, name, outcome string, err error) {
+	entry := Entry{
+		Time:      time.Now().UTC(),
+		RequestID: logging.RequestIDFromContext(ctx),
+		User:      logging.UserFromContext(ctx),
+		Action:    action,
+		Namespace: namespace,
+		Name:      name,
+		Outcome:   outcome,
+	}
+	entry.Reason, _ = ctx.Value(reasonKey{}).(string)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		logging.FromContext(ctx).Error("audit logger closed, entry dropped", "entry", entry)
+		metrics.IncAuditEntryDropped()
+		return
+	}
+	select {
+	case l.entries <- entry:
+	default:
+		logging.FromContext(ctx).Error("audit log full, entry dropped", "entry", entry)
+		metrics.IncAuditEntryDropped()
+	}
+}
+
+func (l *jsonLogger) write() {
+	defer close(l.done)
+	encoder := json.NewEncoder(l.w)
+	for entry := range l.entries {
+		if err := encoder.Encode(entry); err != nil {
+			slog.Error("failed to write audit entry", "entry", entry, "error", err)
+		}
+		for _, sink := range l.sinks {
+			ctx, cancel := context.WithTimeout(context.Background(), sinkTimeout)
+			if err := sink.AddAuditEntry(ctx, entry); err != nil {
+				slog.Error("failed to store audit entry", "entry", entry, "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+func (l *jsonLogger) Close(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.entries)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
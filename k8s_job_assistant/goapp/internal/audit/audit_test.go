@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/logging"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sliceSink keeps the entries, it is only called from the writer goroutine
+type sliceSink struct {
+	entries []Entry
+}
+
+func (s *sliceSink) AddAuditEntry(_ context.Context, entry Entry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func TestJSONLoggerFlushesOnClose(t *testing.T) {
+	var out bytes.Buffer
+	sink := &sliceSink{}
+	logger := NewJSONLogger(&out, 10, sink)
+
+	ctx := logging.WithUser(context.Background(), "alice")
+	logger.Record(ctx, "run", "billing", "nightly", "success", nil)
+	logger.Record(WithReason(ctx, "stuck on a lock"), "kill", "billing", "nightly", "error", errors.New("boom"))
+	require.NoError(t, logger.Close(context.Background()))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var entry Entry
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "alice", entry.User)
+	assert.Equal(t, "kill", entry.Action)
+	assert.Equal(t, "billing", entry.Namespace)
+	assert.Equal(t, "nightly", entry.Name)
+	assert.Equal(t, "error", entry.Outcome)
+	assert.Equal(t, "stuck on a lock", entry.Reason)
+	assert.Equal(t, "boom", entry.Error)
+	assert.Equal(t, []Entry{entry}, sink.entries[1:], "entries are added to the sinks")
+
+	// entries recorded after Close are dropped, not panicking on the closed channel
+	logger.Record(ctx, "run", "billing", "nightly", "success", nil)
+	assert.NoError(t, logger.Close(context.Background()))
+}
+
+// blockingSink holds the writer goroutine until released
+type blockingSink struct {
+	release chan struct{}
+}
+
+func (s *blockingSink) AddAuditEntry(context.Context, Entry) error {
+	<-s.release
+	return nil
+}
+
+func TestJSONLoggerDropsEntriesWhenFull(t *testing.T) {
+	var out bytes.Buffer
+	sink := &blockingSink{release: make(chan struct{})}
+	logger := NewJSONLogger(&out, 1, sink)
+	ctx := context.Background()
+
+	recorded := make(chan struct{})
+	go func() {
+		for range 5 {
+			logger.Record(ctx, "run", "billing", "nightly", "success", nil)
+		}
+		close(recorded)
+	}()
+	select {
+	case <-recorded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Record blocked on the full audit log")
+	}
+
+	close(sink.release)
+	require.NoError(t, logger.Close(context.Background()))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Less(t, len(lines), 5, "entries beyond the buffer are dropped")
+}
@@ -0,0 +1,87 @@
+// Package bundle writes the files describing a run (logs, manifests, events) as a single zip
+// or tar.gz archive, for support engineers to attach to incident tickets.
+package bundle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Formats of the bundles
+const (
+	FormatZip   = "zip"
+	FormatTarGz = "tar.gz"
+)
+
+// File of a bundle, its content is only read while writing the bundle so that a single file is
+// held in memory at a time
+type File struct {
+	// Name is the path of the file in the bundle, ie: 'nightly-x2x4d/main.log'
+	Name    string
+	Content func(ctx context.Context) ([]byte, error)
+}
+
+// ContentType returns the media type of a format, and whether the format is supported
+func ContentType(format string) (string, bool) {
+	switch format {
+	case FormatZip:
+		return "application/zip", true
+	case FormatTarGz:
+		return "application/gzip", true
+	default:
+		return "", false
+	}
+}
+
+// Write writes files under dir in the given format. A file whose content cannot be read is
+// replaced by a '<name>.error' file holding the error, the rest of the bundle is still useful.
+func Write(ctx context.Context, w io.Writer, format, dir string, files []File) error {
+	modTime := time.Now()
+	switch format {
+	case FormatZip:
+		archive := zip.NewWriter(w)
+		for _, file := range files {
+			name, content := read(ctx, file)
+			entry, err := archive.CreateHeader(&zip.FileHeader{Name: dir + "/" + name, Method: zip.Deflate, Modified: modTime})
+			if err != nil {
+				return err
+			}
+			if _, err := entry.Write(content); err != nil {
+				return err
+			}
+		}
+		return archive.Close()
+	case FormatTarGz:
+		compressed := gzip.NewWriter(w)
+		archive := tar.NewWriter(compressed)
+		for _, file := range files {
+			name, content := read(ctx, file)
+			header := &tar.Header{Name: dir + "/" + name, Mode: 0o644, Size: int64(len(content)), ModTime: modTime, Typeflag: tar.TypeReg}
+			if err := archive.WriteHeader(header); err != nil {
+				return err
+			}
+			if _, err := archive.Write(content); err != nil {
+				return err
+			}
+		}
+		if err := archive.Close(); err != nil {
+			return err
+		}
+		return compressed.Close()
+	default:
+		return fmt.Errorf("unknown bundle format %q, expected %s or %s", format, FormatZip, FormatTarGz)
+	}
+}
+
+func read(ctx context.Context, file File) (string, []byte) {
+	content, err := file.Content(ctx)
+	if err != nil {
+		return file.Name + ".error", []byte(err.Error() + "\n")
+	}
+	return file.Name, content
+}
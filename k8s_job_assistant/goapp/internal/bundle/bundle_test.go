@@ -0,0 +1,68 @@
+package bundle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+var testFiles = []File{
+	{Name: "job.yaml", Content: func(context.Context) ([]byte, error) { return []byte("kind: Job\n"), nil }},
+	{Name: "nightly-x2x4d/main.log", Content: func(context.Context) ([]byte, error) { return []byte("exporting\n"), nil }},
+	{Name: "events.yaml", Content: func(context.Context) ([]byte, error) { return nil, errors.New("events are forbidden") }},
+}
+
+var expectedFiles = map[string]string{
+	"nightly-20250602/job.yaml":               "kind: Job\n",
+	"nightly-20250602/nightly-x2x4d/main.log": "exporting\n",
+	"nightly-20250602/events.yaml.error":      "events are forbidden\n",
+}
+
+func TestWriteZip(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Write(context.Background(), &out, FormatZip, "nightly-20250602", testFiles))
+
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	require.NoError(t, err)
+	files := map[string]string{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		files[file.Name] = string(content)
+	}
+	assert.Equal(t, expectedFiles, files)
+}
+
+func TestWriteTarGz(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Write(context.Background(), &out, FormatTarGz, "nightly-20250602", testFiles))
+
+	compressed, err := gzip.NewReader(&out)
+	require.NoError(t, err)
+	archive := tar.NewReader(compressed)
+	files := map[string]string{}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(archive)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+	assert.Equal(t, expectedFiles, files)
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	assert.Error(t, Write(context.Background(), io.Discard, "rar", "nightly", testFiles))
+}
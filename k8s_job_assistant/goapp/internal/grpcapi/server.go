@@ -0,0 +1,397 @@
+// Package grpcapi serves the KJA gRPC API (see api/kja/v1/kja.proto) on top of the job service,
+// alongside the HTTP API.
+package grpcapi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	kjav1 "goapp/api/kja/v1"
+	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"goapp/internal/service"
+	"goapp/pkg/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"log/slog"
+	"net/netip"
+	"os"
+	"time"
+)
+
+// NewServer returns a gRPC server logging and tracing each call, served over TLS when
+// tlsConfig is set (see TLSConfig) or in plaintext otherwise. The userHeader metadata is only
+// trusted from trustedProxies, see logging.TrustedUserFromContext.
+func NewServer(logger *slog.Logger, userHeader string, trustedProxies []netip.Prefix, tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(logging.UnaryServerInterceptor(logger, userHeader, trustedProxies)),
+		grpc.ChainStreamInterceptor(logging.StreamServerInterceptor(logger, userHeader, trustedProxies)),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	return grpc.NewServer(opts...)
+}
+
+// TLSConfig loads the server certificate, and requires clients to present a certificate
+// signed by clientCAFile (mTLS) when it is set.
+func TLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the gRPC server certificate: %w", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	caPEM, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the gRPC client CA: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificate found in the gRPC client CA %s", clientCAFile)
+	}
+	config.ClientCAs = clientCAs
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+type jobServer struct {
+	kjav1.UnimplementedJobServiceServer
+	jobSvc        service.JobService
+	watchInterval time.Duration
+	// watchCtx ends the WatchJobs streams, which would otherwise hold a graceful stop forever
+	watchCtx context.Context
+}
+
+// RegisterJobService serves jobSvc through server. WatchJobs lists the Jobs every watchInterval
+// to find the ones which changed, its streams end when watchCtx is done.
+func RegisterJobService(watchCtx context.Context, server *grpc.Server, jobSvc service.JobService, watchInterval time.Duration) {
+	kjav1.RegisterJobServiceServer(server, &jobServer{jobSvc: jobSvc, watchInterval: watchInterval, watchCtx: watchCtx})
+}
+
+func (s *jobServer) ListDecoratedJobs(ctx context.Context, _ *kjav1.ListDecoratedJobsRequest) (*kjav1.ListDecoratedJobsResponse, error) {
+	jobs, err := s.jobSvc.ListDecoratedJobs(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to list jobs", "error", err)
+		return nil, toStatus(err)
+	}
+	resp := &kjav1.ListDecoratedJobsResponse{Jobs: make([]*kjav1.DecoratedJob, 0, len(jobs))}
+	for _, job := range jobs {
+		resp.Jobs = append(resp.Jobs, toProto(job))
+	}
+	return resp, nil
+}
+
+func (s *jobServer) Run(ctx context.Context, req *kjav1.RunRequest) (*kjav1.RunResponse, error) {
+	if err := validateJob(req.GetNamespace(), req.GetName()); err != nil {
+		return nil, err
+	}
+	queued, err := s.jobSvc.Run(ctx, req.GetNamespace(), req.GetName(), kube.RunOptions{Params: req.GetParams(), Overrides: fromProtoOverrides(req.GetOverrides())})
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to run job", "namespace", req.GetNamespace(), "name", req.GetName(), "error", err)
+		return nil, toStatus(err)
+	}
+	resp := &kjav1.RunResponse{}
+	if queued != nil {
+		resp.Queued = &kjav1.QueuedRun{
+			Id:          queued.ID,
+			Namespace:   queued.Namespace,
+			Name:        queued.Name,
+			TriggeredBy: queued.TriggeredBy,
+			Params:      queued.Params,
+			QueuedAt:    toTimestamp(queued.QueuedAt),
+			Position:    int32(queued.Position),
+		}
+	}
+	return resp, nil
+}
+
+func fromProtoOverrides(overrides *kjav1.RunOverrides) *model.RunOverrides {
+	if overrides == nil {
+		return nil
+	}
+	return &model.RunOverrides{
+		Container:    overrides.GetContainer(),
+		ImageTag:     overrides.GetImageTag(),
+		Args:         overrides.GetArgs(),
+		Requests:     overrides.GetRequests(),
+		Limits:       overrides.GetLimits(),
+		Parallelism:  overrides.Parallelism,
+		Completions:  overrides.Completions,
+		NodeSelector: overrides.GetNodeSelector(),
+	}
+}
+
+// killModes maps the kill modes of the API to the kube ones, unspecified kills gracefully
+var killModes = map[kjav1.KillRequest_KillMode]kube.KillMode{
+	kjav1.KillRequest_KILL_MODE_GRACEFUL: kube.KillGraceful,
+	kjav1.KillRequest_KILL_MODE_FORCE:    kube.KillForce,
+	kjav1.KillRequest_KILL_MODE_ESCALATE: kube.KillEscalate,
+}
+
+func (s *jobServer) Kill(ctx context.Context, req *kjav1.KillRequest) (*kjav1.KillResponse, error) {
+	if err := validateJob(req.GetNamespace(), req.GetName()); err != nil {
+		return nil, err
+	}
+	opts := killOptions(req.GetMode(), req.GetGracePeriod(), req.GetEscalateAfter(), req.GetReason())
+	if err := s.jobSvc.Kill(ctx, req.GetNamespace(), req.GetName(), opts); err != nil {
+		logging.FromContext(ctx).Error("failed to kill job", "namespace", req.GetNamespace(), "name", req.GetName(), "error", err)
+		return nil, toStatus(err)
+	}
+	return &kjav1.KillResponse{}, nil
+}
+
+func (s *jobServer) Restart(ctx context.Context, req *kjav1.RestartRequest) (*kjav1.RestartResponse, error) {
+	if err := validateJob(req.GetNamespace(), req.GetName()); err != nil {
+		return nil, err
+	}
+	opts := killOptions(req.GetMode(), req.GetGracePeriod(), req.GetEscalateAfter(), req.GetReason())
+	if err := s.jobSvc.Restart(ctx, req.GetNamespace(), req.GetName(), opts); err != nil {
+		logging.FromContext(ctx).Error("failed to restart job", "namespace", req.GetNamespace(), "name", req.GetName(), "error", err)
+		return nil, toStatus(err)
+	}
+	return &kjav1.RestartResponse{}, nil
+}
+
+func (s *jobServer) RetryFailedIndexes(ctx context.Context, req *kjav1.RetryFailedIndexesRequest) (*kjav1.RetryFailedIndexesResponse, error) {
+	if err := validateJob(req.GetNamespace(), req.GetName()); err != nil {
+		return nil, err
+	}
+	retried, err := s.jobSvc.RetryFailedIndexes(ctx, req.GetNamespace(), req.GetName())
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to retry failed indexes", "namespace", req.GetNamespace(), "name", req.GetName(), "error", err)
+		return nil, toStatus(err)
+	}
+	resp := &kjav1.RetryFailedIndexesResponse{}
+	for _, index := range retried {
+		resp.Indexes = append(resp.Indexes, int32(index))
+	}
+	return resp, nil
+}
+
+func killOptions(mode kjav1.KillRequest_KillMode, gracePeriod, escalateAfter *durationpb.Duration, reason string) kube.KillOptions {
+	opts := kube.KillOptions{Mode: killModes[mode], Reason: reason}
+	if gracePeriod != nil {
+		grace := gracePeriod.AsDuration()
+		opts.GracePeriod = &grace
+	}
+	if escalateAfter != nil {
+		opts.EscalateAfter = escalateAfter.AsDuration()
+	}
+	return opts
+}
+
+func (s *jobServer) WatchJobs(req *kjav1.WatchJobsRequest, stream grpc.ServerStreamingServer[kjav1.WatchJobsResponse]) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+
+	known := map[string]*kjav1.DecoratedJob{}
+	for {
+		jobs, err := s.jobSvc.ListDecoratedJobs(ctx)
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to list jobs", "error", err)
+			return toStatus(err)
+		}
+
+		seen := map[string]bool{}
+		for _, job := range jobs {
+			if req.GetNamespace() != "" && job.Namespace != req.GetNamespace() {
+				continue
+			}
+			key := job.Namespace + "/" + job.Name
+			seen[key] = true
+			current := toProto(job)
+			previous, ok := known[key]
+			known[key] = current
+
+			eventType := kjav1.WatchJobsResponse_EVENT_TYPE_ADDED
+			if ok {
+				if proto.Equal(previous, current) {
+					continue
+				}
+				eventType = kjav1.WatchJobsResponse_EVENT_TYPE_MODIFIED
+			}
+			if err := stream.Send(&kjav1.WatchJobsResponse{Type: eventType, Job: current}); err != nil {
+				return err
+			}
+		}
+		for key, job := range known {
+			if seen[key] {
+				continue
+			}
+			delete(known, key)
+			if err := stream.Send(&kjav1.WatchJobsResponse{Type: kjav1.WatchJobsResponse_EVENT_TYPE_DELETED, Job: job}); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.watchCtx.Done():
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *jobServer) StreamLogs(req *kjav1.StreamLogsRequest, stream grpc.ServerStreamingServer[kjav1.StreamLogsResponse]) error {
+	if err := validateJob(req.GetNamespace(), req.GetName()); err != nil {
+		return err
+	}
+	if req.TailLines != nil && req.GetTailLines() < 0 {
+		return status.Error(codes.InvalidArgument, "invalid tail_lines, expected a positive number of lines")
+	}
+	ctx := stream.Context()
+	opts := kube.LogOptions{Container: req.GetContainer(), Follow: req.GetFollow(), TailLines: req.TailLines}
+
+	logs, err := s.jobSvc.Logs(ctx, req.GetNamespace(), req.GetName(), opts)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to get job logs", "namespace", req.GetNamespace(), "name", req.GetName(), "error", err)
+		return toStatus(err)
+	}
+	defer logs.Close()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := logs.Read(buf)
+		if n > 0 {
+			if sendErr := stream.Send(&kjav1.StreamLogsResponse{Data: append([]byte(nil), buf[:n]...)}); sendErr != nil {
+				return sendErr // client went away
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			logging.FromContext(ctx).Warn("log stream interrupted", "error", err)
+			return toStatus(err)
+		}
+	}
+}
+
+func validateJob(namespace, name string) error {
+	if namespace == "" || name == "" {
+		return status.Error(codes.InvalidArgument, "namespace and name are required")
+	}
+	return nil
+}
+
+// toStatus maps service errors to gRPC status codes, as errorStatus does for HTTP
+func toStatus(err error) error {
+	var alreadyRunning *kube.JobAlreadyRunningError
+	var noPod *kube.NoPodError
+	var invalidOptions *kube.InvalidRunOptionsError
+	var invalidKill *kube.InvalidKillOptionsError
+	var queueFull *service.RunQueueFullError
+	var busy *service.JobBusyError
+	var overrideForbidden *kube.OverrideForbiddenError
+	switch {
+	case errors.As(err, &alreadyRunning):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.As(err, &queueFull):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.As(err, &busy):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.As(err, &overrideForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.As(err, &invalidOptions), errors.As(err, &invalidKill):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &noPod), k8serrors.IsNotFound(err):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func toProto(job model.DecoratedJob) *kjav1.DecoratedJob {
+	return &kjav1.DecoratedJob{
+		Namespace:                         job.Namespace,
+		Name:                              job.Name,
+		RunId:                             job.RunID,
+		LastSuccessfullyRunStartTime:      toTimestamp(job.LastSuccessfullyRunStarTime),
+		LastStatus:                        &kjav1.LastStatus{Type: job.LastStatus.Type, Message: job.LastStatus.Message},
+		LastSuccessfullyRunCompletionTime: toTimestamp(job.LastSuccessfullyRunCompletionTime),
+		Indexes:                           toProtoIndexes(job.Indexes),
+		State:                             string(job.State),
+		CurrentRun:                        toProtoRunStatus(job.CurrentRun),
+		LastSuccess:                       toProtoSuccessfulRun(job.LastSuccess),
+	}
+}
+
+func toProtoRunStatus(run *model.RunStatus) *kjav1.RunStatus {
+	if run == nil {
+		return nil
+	}
+	return &kjav1.RunStatus{
+		StartTime:      toTimestamp(run.StartTime),
+		CompletionTime: toTimestamp(run.CompletionTime),
+		Duration:       toDuration(run.Duration),
+		Active:         run.Active,
+		Succeeded:      run.Succeeded,
+		Failed:         run.Failed,
+		BackoffLimit:   run.BackoffLimit,
+		FailureReason:  run.FailureReason,
+		FailureMessage: run.FailureMessage,
+	}
+}
+
+func toProtoSuccessfulRun(run *model.SuccessfulRun) *kjav1.SuccessfulRun {
+	if run == nil {
+		return nil
+	}
+	return &kjav1.SuccessfulRun{
+		StartTime:      toTimestamp(run.StartTime),
+		CompletionTime: toTimestamp(run.CompletionTime),
+		Duration:       toDuration(run.Duration),
+	}
+}
+
+func toProtoIndexes(indexes *model.Indexes) *kjav1.Indexes {
+	if indexes == nil {
+		return nil
+	}
+	result := &kjav1.Indexes{
+		Completions:    indexes.Completions,
+		Succeeded:      indexes.Succeeded,
+		SucceededCount: int32(indexes.SucceededCount),
+		Failed:         indexes.Failed,
+		FailedCount:    int32(indexes.FailedCount),
+	}
+	for _, retry := range indexes.Retries {
+		result.Retries = append(result.Retries, &kjav1.IndexRetry{Index: int32(retry.Index), Job: retry.Job, State: string(retry.State)})
+	}
+	return result
+}
+
+func toDuration(d *metav1.Duration) *durationpb.Duration {
+	if d == nil {
+		return nil
+	}
+	return durationpb.New(d.Duration)
+}
+
+func toTimestamp(t *metav1.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(t.Time)
+}
@@ -0,0 +1,295 @@
+package grpcapi
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kjav1 "goapp/api/kja/v1"
+	"goapp/internal/bundle"
+	"goapp/internal/kube"
+	"goapp/internal/service"
+	"goapp/pkg/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"io"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeJobService serves the Jobs set by the test
+type fakeJobService struct {
+	mu   sync.Mutex
+	jobs []model.DecoratedJob
+	logs string
+	// killed holds the options of the last kill
+	killed kube.KillOptions
+}
+
+func (f *fakeJobService) setJobs(jobs ...model.DecoratedJob) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jobs = jobs
+}
+
+func (f *fakeJobService) ListDecoratedJobs(context.Context) ([]model.DecoratedJob, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]model.DecoratedJob(nil), f.jobs...), nil
+}
+
+func (f *fakeJobService) Status(context.Context, string, string) (*model.DecoratedJob, error) {
+	return nil, nil
+}
+
+func (f *fakeJobService) Runs(context.Context, string, string) ([]model.Run, error) {
+	return nil, nil
+}
+
+func (f *fakeJobService) CheckParams(context.Context, string, string, map[string]string) error {
+	return nil
+}
+
+func (f *fakeJobService) Logs(_ context.Context, namespace, name string, _ kube.LogOptions) (io.ReadCloser, error) {
+	if name != "nightly" {
+		return nil, &kube.NoPodError{Namespace: namespace, JobName: name}
+	}
+	return io.NopCloser(strings.NewReader(f.logs)), nil
+}
+
+func (f *fakeJobService) Bundle(context.Context, string, string, string) ([]bundle.File, error) {
+	return nil, &service.ArchiveDisabledError{}
+}
+
+func (f *fakeJobService) ArchivedLogs(context.Context, string, string, string) ([]model.ArchivedLog, error) {
+	return nil, &service.ArchiveDisabledError{}
+}
+
+func (f *fakeJobService) ArchivedLog(context.Context, string, string, string, string, string) (io.ReadCloser, int64, error) {
+	return nil, 0, &service.ArchiveDisabledError{}
+}
+
+func (f *fakeJobService) Run(_ context.Context, namespace, name string, opts kube.RunOptions) (*model.QueuedRun, error) {
+	switch name {
+	case "overridden":
+		if opts.Overrides == nil || opts.Overrides.ImageTag != "1.5.0" || *opts.Overrides.Parallelism != 4 {
+			return nil, &kube.InvalidRunOptionsError{Reason: "overrides not received"}
+		}
+	case "forbidden":
+		return nil, &kube.OverrideForbiddenError{User: "john.smith", Annotation: "job-assistant/override-users"}
+	case "running":
+		return nil, &kube.JobAlreadyRunningError{}
+	case "queued":
+		return &model.QueuedRun{ID: "20250602-030000-4f2a9c", Namespace: namespace, Name: name, Position: 1}, nil
+	case "full":
+		return nil, &service.RunQueueFullError{Namespace: namespace, JobName: name, Depth: 1}
+	case "unknown":
+		return nil, k8serrors.NewNotFound(schema.GroupResource{Group: "batch", Resource: "jobs"}, name)
+	}
+	return nil, nil
+}
+
+func (f *fakeJobService) RunFromHook(context.Context, string, string, []byte, func([]byte) error) (*model.QueuedRun, error) {
+	return nil, nil
+}
+
+func (f *fakeJobService) Kill(_ context.Context, _, name string, opts kube.KillOptions) error {
+	if name == "invalid" {
+		return &kube.InvalidKillOptionsError{Reason: "unknown mode"}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.killed = opts
+	return nil
+}
+
+func (f *fakeJobService) Restart(_ context.Context, namespace, name string, opts kube.KillOptions) error {
+	if name == "busy" {
+		return &service.JobBusyError{Namespace: namespace, JobName: name, Operation: "restart"}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.killed = opts
+	return nil
+}
+
+func (f *fakeJobService) RetryFailedIndexes(_ context.Context, namespace, name string) ([]int, error) {
+	if name != "indexed" {
+		return nil, &kube.InvalidRunOptionsError{Reason: "job " + namespace + "/" + name + " is not an Indexed Job"}
+	}
+	return []int{3, 7}, nil
+}
+
+func (f *fakeJobService) WatchFinishedRuns(context.Context, func() bool, func(context.Context, service.FinishedRun)) (func() bool, error) {
+	return nil, nil
+}
+
+func (f *fakeJobService) Queue(context.Context) []model.QueuedRun {
+	return nil
+}
+
+func (f *fakeJobService) CancelQueuedRun(context.Context, string, string, string) error {
+	return nil
+}
+
+func (f *fakeJobService) ProcessQueue(context.Context, time.Duration) {}
+
+func (f *fakeJobService) Watchdog(context.Context, time.Duration, float64) {}
+
+func newTestClient(t *testing.T, jobSvc *fakeJobService) kjav1.JobServiceClient {
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewServer(slog.New(slog.NewTextHandler(io.Discard, nil)), "X-Forwarded-User", nil, nil)
+	RegisterJobService(context.Background(), server, jobSvc, 10*time.Millisecond)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return kjav1.NewJobServiceClient(conn)
+}
+
+func TestRunErrorCodes(t *testing.T) {
+	client := newTestClient(t, &fakeJobService{})
+	ctx := context.Background()
+
+	resp, err := client.Run(ctx, &kjav1.RunRequest{Namespace: "billing", Name: "nightly"})
+	assert.NoError(t, err)
+	assert.Nil(t, resp.GetQueued())
+	resp, err = client.Run(ctx, &kjav1.RunRequest{Namespace: "billing", Name: "queued"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), resp.GetQueued().GetPosition())
+
+	_, err = client.Run(ctx, &kjav1.RunRequest{Namespace: "billing", Name: "overridden", Overrides: &kjav1.RunOverrides{ImageTag: "1.5.0", Parallelism: proto.Int32(4)}})
+	assert.NoError(t, err)
+
+	for name, code := range map[string]codes.Code{"forbidden": codes.PermissionDenied, "running": codes.FailedPrecondition, "full": codes.ResourceExhausted, "unknown": codes.NotFound, "": codes.InvalidArgument} {
+		_, err = client.Run(ctx, &kjav1.RunRequest{Namespace: "billing", Name: name})
+		assert.Equal(t, code, status.Code(err), name)
+	}
+}
+
+func TestKillOptions(t *testing.T) {
+	jobSvc := &fakeJobService{}
+	client := newTestClient(t, jobSvc)
+	ctx := context.Background()
+
+	_, err := client.Kill(ctx, &kjav1.KillRequest{
+		Namespace:     "billing",
+		Name:          "nightly",
+		Mode:          kjav1.KillRequest_KILL_MODE_ESCALATE,
+		GracePeriod:   durationpb.New(10 * time.Second),
+		EscalateAfter: durationpb.New(time.Minute),
+		Reason:        "stuck on a lock",
+	})
+	require.NoError(t, err)
+	gracePeriod := 10 * time.Second
+	assert.Equal(t, kube.KillOptions{Mode: kube.KillEscalate, GracePeriod: &gracePeriod, EscalateAfter: time.Minute, Reason: "stuck on a lock"}, jobSvc.killed)
+
+	_, err = client.Kill(ctx, &kjav1.KillRequest{Namespace: "billing", Name: "nightly"})
+	require.NoError(t, err)
+	assert.Equal(t, kube.KillOptions{}, jobSvc.killed, "unspecified mode lets the kube layer pick its default")
+
+	_, err = client.Kill(ctx, &kjav1.KillRequest{Namespace: "billing", Name: "invalid"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestRestart(t *testing.T) {
+	jobSvc := &fakeJobService{}
+	client := newTestClient(t, jobSvc)
+	ctx := context.Background()
+
+	_, err := client.Restart(ctx, &kjav1.RestartRequest{Namespace: "billing", Name: "nightly", Mode: kjav1.KillRequest_KILL_MODE_FORCE})
+	require.NoError(t, err)
+	assert.Equal(t, kube.KillOptions{Mode: kube.KillForce}, jobSvc.killed)
+
+	_, err = client.Restart(ctx, &kjav1.RestartRequest{Namespace: "billing", Name: "busy"})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+}
+
+func TestRetryFailedIndexes(t *testing.T) {
+	jobSvc := &fakeJobService{}
+	jobSvc.setJobs(model.DecoratedJob{Namespace: "billing", Name: "indexed", Indexes: &model.Indexes{
+		Completions: 8, Succeeded: "0-2,4-6", SucceededCount: 6, Failed: "3,7", FailedCount: 2,
+		Retries: []model.IndexRetry{{Index: 3, Job: "indexed-retry-3", State: model.RunRunning}},
+	}})
+	client := newTestClient(t, jobSvc)
+	ctx := context.Background()
+
+	jobs, err := client.ListDecoratedJobs(ctx, &kjav1.ListDecoratedJobsRequest{})
+	require.NoError(t, err)
+	require.Len(t, jobs.GetJobs(), 1)
+	indexes := jobs.GetJobs()[0].GetIndexes()
+	assert.Equal(t, "3,7", indexes.GetFailed())
+	require.Len(t, indexes.GetRetries(), 1)
+	assert.Equal(t, "Running", indexes.GetRetries()[0].GetState())
+
+	resp, err := client.RetryFailedIndexes(ctx, &kjav1.RetryFailedIndexesRequest{Namespace: "billing", Name: "indexed"})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{3, 7}, resp.GetIndexes())
+
+	_, err = client.RetryFailedIndexes(ctx, &kjav1.RetryFailedIndexesRequest{Namespace: "billing", Name: "nightly"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestWatchJobs(t *testing.T) {
+	jobSvc := &fakeJobService{}
+	jobSvc.setJobs(model.DecoratedJob{Namespace: "billing", Name: "nightly", LastStatus: model.LastStatus{Type: "Suspended"}},
+		model.DecoratedJob{Namespace: "other", Name: "ignored"})
+	client := newTestClient(t, jobSvc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.WatchJobs(ctx, &kjav1.WatchJobsRequest{Namespace: "billing"})
+	require.NoError(t, err)
+
+	event, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, kjav1.WatchJobsResponse_EVENT_TYPE_ADDED, event.GetType())
+	assert.Equal(t, "nightly", event.GetJob().GetName())
+
+	jobSvc.setJobs(model.DecoratedJob{Namespace: "billing", Name: "nightly", RunID: "run-1", LastStatus: model.LastStatus{Type: "Running"}})
+	event, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, kjav1.WatchJobsResponse_EVENT_TYPE_MODIFIED, event.GetType())
+	assert.Equal(t, "run-1", event.GetJob().GetRunId())
+
+	jobSvc.setJobs()
+	event, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, kjav1.WatchJobsResponse_EVENT_TYPE_DELETED, event.GetType())
+}
+
+func TestStreamLogs(t *testing.T) {
+	client := newTestClient(t, &fakeJobService{logs: "starting export\nexported 1234 rows\n"})
+
+	stream, err := client.StreamLogs(context.Background(), &kjav1.StreamLogsRequest{Namespace: "billing", Name: "nightly"})
+	require.NoError(t, err)
+	var logs strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		logs.Write(chunk.GetData())
+	}
+	assert.Equal(t, "starting export\nexported 1234 rows\n", logs.String())
+
+	stream, err = client.StreamLogs(context.Background(), &kjav1.StreamLogsRequest{Namespace: "billing", Name: "no-pod"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
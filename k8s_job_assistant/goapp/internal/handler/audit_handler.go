@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"goapp/internal/logging"
+	"goapp/internal/store"
+	"goapp/pkg/model"
+	"net/http"
+	"strconv"
+)
+
+// DecorateRouterWithAuditHandlers serves the audit entries kept in the store, most recent first.
+func DecorateRouterWithAuditHandlers(router *gin.Engine, st store.Store) {
+	router.GET("/audit", func(c *gin.Context) {
+		limit := -1
+		if value := c.Query("limit"); value != "" {
+			var err error
+			if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit, expected a positive number of entries"})
+				return
+			}
+		}
+		entries, err := st.AuditEntries(c.Request.Context())
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to list audit entries", "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if limit >= 0 && len(entries) > limit {
+			entries = entries[:limit]
+		}
+		c.JSON(http.StatusOK, model.ListAuditEntries{Entries: entries, Count: len(entries)})
+	})
+}
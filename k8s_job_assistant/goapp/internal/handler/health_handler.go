@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"goapp/internal/health"
+	"net/http"
+)
+
+func DecorateRouterWithHealthHandlers(router *gin.Engine, checker *health.Checker) {
+	// liveness: the process is up and serving HTTP
+	router.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// readiness: KJA can actually serve Job operations
+	router.GET("/readyz", func(c *gin.Context) {
+		if failures := checker.Ready(c.Request.Context()); len(failures) > 0 {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "checks": failures})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	})
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"goapp/internal/logging"
+	"goapp/internal/service"
+	"goapp/internal/signing"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxHookPayload is the largest inbound webhook payload accepted
+	maxHookPayload = 1 << 20
+	// hookMaxAge is how old the timestamp of an inbound webhook can be, to prevent replays
+	hookMaxAge = 5 * time.Minute
+)
+
+// DecorateRouterWithHookHandlers lets other systems run Jobs with webhooks signed with a secret
+// of the Job (see the signing package), instead of user credentials. Each signed request is
+// accepted once, replays are rejected.
+func DecorateRouterWithHookHandlers(router *gin.Engine, jobSvc service.JobService) {
+	replays := signing.NewReplayGuard(hookMaxAge)
+	router.POST("/hooks/:namespace/:name", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxHookPayload))
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Payload too large"})
+			return
+		}
+
+		timestamp := c.GetHeader(signing.TimestampHeader)
+		signature := c.GetHeader(signing.SignatureHeader)
+		verify := func(secret []byte) error {
+			now := time.Now()
+			if err := signing.Verify(secret, timestamp, signature, payload, hookMaxAge, now); err != nil {
+				return err
+			}
+			return replays.Check(namespace+"/"+name+" "+signature, timestamp, now)
+		}
+		queued, err := jobSvc.RunFromHook(c.Request.Context(), namespace, name, payload, verify)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to run job from webhook", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if queued != nil {
+			c.JSON(http.StatusAccepted, queued)
+			return
+		}
+		c.Status(http.StatusAccepted)
+	})
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"goapp/internal/service"
+	"goapp/internal/signing"
+	"goapp/pkg/model"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hookJobService verifies the webhooks with a fixed secret and counts the runs, other JobService
+// methods are not used
+type hookJobService struct {
+	service.JobService
+	runs int
+}
+
+func (s *hookJobService) RunFromHook(_ context.Context, _, _ string, _ []byte, verify func(secret []byte) error) (*model.QueuedRun, error) {
+	if err := verify([]byte("s3cr3t")); err != nil {
+		return nil, &service.InvalidHookError{Err: err}
+	}
+	s.runs++
+	return nil, nil
+}
+
+func TestReplayedHookIsRejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	jobSvc := &hookJobService{}
+	DecorateRouterWithHookHandlers(router, jobSvc)
+
+	body := `{"params": {"SOURCE_FILE": "s3://vendor/2025-06-02.csv"}}`
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	send := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/hooks/billing/nightly", strings.NewReader(body))
+		req.Header.Set(signing.TimestampHeader, timestamp)
+		req.Header.Set(signing.SignatureHeader, signing.Sign([]byte("s3cr3t"), timestamp, []byte(body)))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusAccepted, send())
+	assert.Equal(t, http.StatusUnauthorized, send(), "replayed")
+	assert.Equal(t, 1, jobSvc.runs)
+}
@@ -1,19 +1,34 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"goapp/internal/model"
+	"goapp/internal/bundle"
+	"goapp/internal/kube"
+	"goapp/internal/logfilter"
+	"goapp/internal/logging"
 	"goapp/internal/service"
+	"goapp/internal/signing"
+	"goapp/pkg/model"
+	"io"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
 	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func DecorateRouterWithJobHandlers(router *gin.Engine, jobSvc service.JobService) {
 	router.GET("/list", func(c *gin.Context) {
-		jobs, err := jobSvc.ListDecoratedJobs()
+		jobs, err := jobSvc.ListDecoratedJobs(c.Request.Context())
 		if err != nil {
-			fmt.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			logging.FromContext(c.Request.Context()).Error("failed to list jobs", "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 		listJobs := model.ListJobs{
@@ -24,6 +39,151 @@ func DecorateRouterWithJobHandlers(router *gin.Engine, jobSvc service.JobService
 		c.JSON(http.StatusOK, listJobs)
 	})
 
+	router.GET("/status/:namespace/:name", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		job, err := jobSvc.Status(c.Request.Context(), namespace, name)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to get job status", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	})
+
+	router.GET("/runs/:namespace/:name", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		runs, err := jobSvc.Runs(c.Request.Context(), namespace, name)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to list job runs", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, model.ListRuns{Runs: runs, Count: len(runs)})
+	})
+
+	router.GET("/runs/:namespace/:name/:run/logs", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		logs, err := jobSvc.ArchivedLogs(c.Request.Context(), namespace, name, c.Param("run"))
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to list archived logs", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, model.ListArchivedLogs{Logs: logs, Count: len(logs)})
+	})
+
+	router.GET("/runs/:namespace/:name/:run/logs/:pod/:container", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		var limitBytes int64 = -1
+		if limit := c.Query("limitBytes"); limit != "" {
+			var err error
+			if limitBytes, err = strconv.ParseInt(limit, 10, 64); err != nil || limitBytes < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limitBytes, expected a positive number of bytes"})
+				return
+			}
+		}
+		filter, ok := logFilter(c)
+		if !ok {
+			return
+		}
+		filter.Timestamped = true // see service.LogArchive
+		run, pod, container := c.Param("run"), c.Param("pod"), c.Param("container")
+		logs, size, err := jobSvc.ArchivedLog(c.Request.Context(), namespace, name, run, pod, container)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to get archived logs", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		defer logs.Close()
+
+		var body io.Reader = logs
+		if filter.Filters() || !filter.KeepTimestamps {
+			body, size = logfilter.Filter(logs, filter), -1
+		}
+		if limitBytes >= 0 {
+			body, size = io.LimitReader(body, limitBytes), min(size, limitBytes) // size is -1 when unknown
+		}
+		extraHeaders := map[string]string{}
+		if c.Query("download") == "true" {
+			extraHeaders["Content-Disposition"] = fmt.Sprintf(`attachment; filename="%s-%s-%s-%s.log"`, name, run, pod, container)
+		}
+		c.DataFromReader(http.StatusOK, size, "text/plain; charset=utf-8", body, extraHeaders)
+	})
+
+	router.GET("/runs/:namespace/:name/:run/bundle", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		run := c.Param("run")
+		format := c.DefaultQuery("format", bundle.FormatZip)
+		contentType, ok := bundle.ContentType(format)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid format, expected %s or %s", bundle.FormatZip, bundle.FormatTarGz)})
+			return
+		}
+		files, err := jobSvc.Bundle(c.Request.Context(), namespace, name, run)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to bundle run", "namespace", namespace, "name", name, "run_id", run, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		dir := fmt.Sprintf("%s-%s-%s", namespace, name, run)
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, dir, format))
+		c.Status(http.StatusOK)
+		if err := bundle.Write(c.Request.Context(), c.Writer, format, dir, files); err != nil {
+			// the answer started, the client gets a truncated archive
+			logging.FromContext(c.Request.Context()).Error("failed to write run bundle", "namespace", namespace, "name", name, "run_id", run, "error", err)
+		}
+	})
+
+	router.GET("/logs/:namespace/:name", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		opts := kube.LogOptions{
+			Container: c.Query("container"),
+			Pod:       c.Query("pod"),
+			Follow:    c.Query("follow") == "true",
+		}
+		if tail := c.Query("tail"); tail != "" {
+			tailLines, err := strconv.ParseInt(tail, 10, 64)
+			if err != nil || tailLines < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tail, expected a positive number of lines"})
+				return
+			}
+			opts.TailLines = &tailLines
+		}
+		filter, ok := logFilter(c)
+		if !ok {
+			return
+		}
+		if !filter.Since.IsZero() {
+			opts.SinceTime = &metav1.Time{Time: filter.Since} // skipped by Kubernetes
+		}
+		opts.Timestamps = filter.KeepTimestamps || !filter.Until.IsZero()
+		filter.Timestamped = opts.Timestamps
+
+		logs, err := jobSvc.Logs(c.Request.Context(), namespace, name, opts)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to get job logs", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		defer logs.Close()
+
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		c.Status(http.StatusOK)
+		if filter.Filters() {
+			streamLogs(c, logfilter.Filter(logs, filter))
+		} else {
+			streamLogs(c, logs)
+		}
+	})
+
 	router.GET("/run/:namespace/:name", func(c *gin.Context) {
 		namespace := c.Param("namespace")
 		name := c.Param("name")
@@ -31,9 +191,18 @@ func DecorateRouterWithJobHandlers(router *gin.Engine, jobSvc service.JobService
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path"})
 			return
 		}
-		if err := jobSvc.Run(namespace, name); err != nil {
-			fmt.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		overrides, ok := runOverrides(c)
+		if !ok {
+			return
+		}
+		queued, err := jobSvc.Run(c.Request.Context(), namespace, name, kube.RunOptions{Overrides: overrides})
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to run job", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if queued != nil {
+			c.JSON(http.StatusAccepted, queued)
 			return
 		}
 		c.Status(http.StatusOK)
@@ -46,11 +215,245 @@ func DecorateRouterWithJobHandlers(router *gin.Engine, jobSvc service.JobService
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path"})
 			return
 		}
-		if err := jobSvc.Kill(namespace, name); err != nil {
-			fmt.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		opts, ok := killOptions(c)
+		if !ok {
+			return
+		}
+		if err := jobSvc.Kill(c.Request.Context(), namespace, name, opts); err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to kill job", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 		c.Status(http.StatusOK)
 	})
+
+	router.GET("/restart/:namespace/:name", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		if namespace == "" || name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path"})
+			return
+		}
+		opts, ok := killOptions(c)
+		if !ok {
+			return
+		}
+		if err := jobSvc.Restart(c.Request.Context(), namespace, name, opts); err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to restart job", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	router.POST("/retry/:namespace/:name", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		retried, err := jobSvc.RetryFailedIndexes(c.Request.Context(), namespace, name)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to retry failed indexes", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if retried == nil {
+			retried = []int{}
+		}
+		c.JSON(http.StatusOK, model.RetriedIndexes{Indexes: retried, Count: len(retried)})
+	})
+
+	router.GET("/queue", func(c *gin.Context) {
+		queued := jobSvc.Queue(c.Request.Context())
+		c.JSON(http.StatusOK, model.ListQueuedRuns{Runs: queued, Count: len(queued)})
+	})
+
+	router.POST("/queue/:namespace/:name/:id/cancel", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		if err := jobSvc.CancelQueuedRun(c.Request.Context(), namespace, name, c.Param("id")); err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to cancel queued run", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+}
+
+// runOverrides reads the overrides of a run from the query, nil when none is set. It answers 400
+// when they are invalid.
+func runOverrides(c *gin.Context) (*model.RunOverrides, bool) {
+	overrides := &model.RunOverrides{
+		Container: c.Query("container"),
+		ImageTag:  c.Query("imageTag"),
+		Requests:  queryResources(c, "Request"),
+		Limits:    queryResources(c, "Limit"),
+	}
+	if args := c.QueryArray("arg"); len(args) > 0 {
+		overrides.Args = args
+	}
+	var ok bool
+	if overrides.Parallelism, ok = queryInt32(c, "parallelism"); !ok {
+		return nil, false
+	}
+	if overrides.Completions, ok = queryInt32(c, "completions"); !ok {
+		return nil, false
+	}
+	for _, selector := range c.QueryArray("nodeSelector") {
+		key, value, found := strings.Cut(selector, "=")
+		if !found || key == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid nodeSelector, expected key=value"})
+			return nil, false
+		}
+		if overrides.NodeSelector == nil {
+			overrides.NodeSelector = map[string]string{}
+		}
+		overrides.NodeSelector[key] = value
+	}
+	if reflect.ValueOf(*overrides).IsZero() {
+		return nil, true
+	}
+	return overrides, true
+}
+
+// queryResources reads the cpu and memory quantities of the query, ie: memoryLimit=4Gi for the "Limit" suffix
+func queryResources(c *gin.Context, suffix string) map[string]string {
+	var resources map[string]string
+	for _, name := range []string{"cpu", "memory"} {
+		if value := c.Query(name + suffix); value != "" {
+			if resources == nil {
+				resources = map[string]string{}
+			}
+			resources[name] = value
+		}
+	}
+	return resources
+}
+
+// queryInt32 reads an optional number from the query, answering 400 when it is invalid
+func queryInt32(c *gin.Context, name string) (*int32, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	parsed, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + ", expected a number"})
+		return nil, false
+	}
+	return pointer.Int32(int32(parsed)), true
+}
+
+// killOptions reads the kill options from the query, answering 400 when they are invalid
+func killOptions(c *gin.Context) (kube.KillOptions, bool) {
+	opts := kube.KillOptions{
+		Mode:   kube.KillMode(c.Query("mode")),
+		Reason: c.Query("reason"),
+	}
+	if grace := c.Query("grace"); grace != "" {
+		gracePeriod, err := time.ParseDuration(grace)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid grace, expected a duration like 10s"})
+			return opts, false
+		}
+		opts.GracePeriod = &gracePeriod
+	}
+	if escalateAfter := c.Query("escalateAfter"); escalateAfter != "" {
+		var err error
+		if opts.EscalateAfter, err = time.ParseDuration(escalateAfter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid escalateAfter, expected a duration like 1m"})
+			return opts, false
+		}
+	}
+	return opts, true
+}
+
+// errorStatus maps service errors to HTTP status codes
+func errorStatus(err error) int {
+	var alreadyRunning *kube.JobAlreadyRunningError
+	var noPod *kube.NoPodError
+	var noHook *kube.HookNotConfiguredError
+	var invalidOptions *kube.InvalidRunOptionsError
+	var invalidKill *kube.InvalidKillOptionsError
+	var overrideForbidden *kube.OverrideForbiddenError
+	var invalidHook *service.InvalidHookError
+	var pipelineRunning *service.PipelineAlreadyRunningError
+	var queueFull *service.RunQueueFullError
+	var busy *service.JobBusyError
+	var invalidPipeline *service.InvalidPipelineError
+	var invalidSchedule *service.InvalidScheduleError
+	var archiveDisabled *service.ArchiveDisabledError
+	switch {
+	case errors.As(err, &alreadyRunning), errors.As(err, &pipelineRunning), errors.As(err, &queueFull), errors.As(err, &busy):
+		return http.StatusConflict
+	case errors.As(err, &noPod), errors.As(err, &noHook), errors.As(err, &archiveDisabled), k8serrors.IsNotFound(err):
+		return http.StatusNotFound
+	case errors.As(err, &invalidHook):
+		if errors.Is(err, signing.ErrInvalidSignature) || errors.Is(err, signing.ErrExpiredTimestamp) || errors.Is(err, signing.ErrReplayed) {
+			return http.StatusUnauthorized
+		}
+		return http.StatusBadRequest
+	case errors.As(err, &invalidOptions), errors.As(err, &invalidKill), errors.As(err, &invalidSchedule):
+		return http.StatusBadRequest
+	case errors.As(err, &overrideForbidden):
+		return http.StatusForbidden
+	case errors.As(err, &invalidPipeline):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// logFilter reads the filter of the logs from the query, answering 400 when it is invalid
+func logFilter(c *gin.Context) (logfilter.Options, bool) {
+	filter := logfilter.Options{
+		Grep:           c.Query("grep"),
+		KeepTimestamps: c.Query("timestamps") == "true",
+	}
+	if expression := c.Query("regex"); expression != "" {
+		regex, err := regexp.Compile(expression)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid regex: " + err.Error()})
+			return filter, false
+		}
+		filter.Regex = regex
+	}
+	for _, levels := range c.QueryArray("level") {
+		for _, level := range strings.Split(levels, ",") {
+			if level = strings.TrimSpace(level); level != "" {
+				filter.Levels = append(filter.Levels, level)
+			}
+		}
+	}
+	for name, bound := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		if ago, err := time.ParseDuration(value); err == nil && ago >= 0 {
+			*bound = time.Now().Add(-ago)
+		} else if *bound, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + ", expected an RFC3339 time or a duration ago, ie: 15m"})
+			return filter, false
+		}
+	}
+	return filter, true
+}
+
+// streamLogs copies logs to the response, flushing after each chunk so followed logs show up right away
+func streamLogs(c *gin.Context, logs io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := logs.Read(buf)
+		if n > 0 {
+			if _, writeErr := c.Writer.Write(buf[:n]); writeErr != nil {
+				return // client went away
+			}
+			c.Writer.Flush()
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logging.FromContext(c.Request.Context()).Warn("log stream interrupted", "error", err)
+			}
+			return
+		}
+	}
 }
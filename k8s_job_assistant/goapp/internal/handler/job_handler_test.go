@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"goapp/internal/service"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// archiveJobService serves a fixed archived log, other JobService methods are not used
+type archiveJobService struct {
+	service.JobService
+	log string
+}
+
+func (s *archiveJobService) ArchivedLog(_ context.Context, _, _, _, _, _ string) (io.ReadCloser, int64, error) {
+	return io.NopCloser(strings.NewReader(s.log)), int64(len(s.log)), nil
+}
+
+func TestArchivedLogLimitBytesAppliesToFilteredLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	DecorateRouterWithJobHandlers(router, &archiveJobService{log: "2025-06-02T03:00:00Z starting\n" +
+		"2025-06-02T03:00:01Z error: no such file\n" +
+		"2025-06-02T03:00:02Z done\n"})
+
+	req := httptest.NewRequest(http.MethodGet, "/runs/billing/nightly/20250602-030000-4f2a9c/logs/nightly-x2x4d/main?grep=error&limitBytes=5", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "error", rec.Body.String())
+}
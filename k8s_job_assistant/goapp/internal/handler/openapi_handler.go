@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"goapp/internal/openapi"
+	"net/http"
+)
+
+// DecorateRouterWithOpenAPIHandlers serves the OpenAPI document of the API at /openapi.json
+// and renders it at /docs, with the Redoc bundle embedded in the binary
+func DecorateRouterWithOpenAPIHandlers(router *gin.Engine, doc *openapi3.T) error {
+	spec, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the OpenAPI document: %w", err)
+	}
+
+	router.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", spec)
+	})
+	router.GET("/docs", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage)
+	})
+	router.GET("/docs/"+openapi.RedocBundleFile, func(c *gin.Context) {
+		redoc, ok := openapi.RedocBundle()
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Redoc is not embedded in this build, see `make redoc`"})
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=86400")
+		c.Data(http.StatusOK, "text/javascript; charset=utf-8", redoc)
+	})
+	return nil
+}
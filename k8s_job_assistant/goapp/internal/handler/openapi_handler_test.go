@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/openapi"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestOpenAPIMatchesRoutes fails when a Job route is added, removed or changed without
+// updating the OpenAPI document (and the other way around)
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	doc, err := openapi.Spec()
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	DecorateRouterWithJobHandlers(router, nil)
+	DecorateRouterWithHookHandlers(router, nil)
+	DecorateRouterWithPipelineHandlers(router, nil)
+	DecorateRouterWithScheduleHandlers(router, nil)
+	DecorateRouterWithAuditHandlers(router, nil)
+
+	var routes []string
+	for _, route := range router.Routes() {
+		routes = append(routes, route.Method+" "+openapi.Path(route.Path))
+	}
+	var documented []string
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented = append(documented, method+" "+path)
+		}
+	}
+	sort.Strings(routes)
+	sort.Strings(documented)
+	assert.Equal(t, routes, documented)
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	doc, err := openapi.Spec()
+	require.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	require.NoError(t, DecorateRouterWithOpenAPIHandlers(router, doc))
+
+	rec := serve(router, http.MethodGet, "/openapi.json", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	served, err := openapi3.NewLoader().LoadFromData(rec.Body.Bytes())
+	require.NoError(t, err)
+	assert.NotNil(t, served.Paths.Find("/status/{namespace}/{name}"))
+
+	rec = serve(router, http.MethodGet, "/docs", "text/html")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.Contains(rec.Body.String(), `spec-url="openapi.json"`))
+	assert.True(t, strings.Contains(rec.Body.String(), `src="docs/redoc.standalone.js"`), "Redoc is not loaded from a CDN")
+
+	rec = serve(router, http.MethodGet, "/docs/redoc.standalone.js", "")
+	if _, embedded := openapi.RedocBundle(); embedded {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	} else {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"goapp/internal/logging"
+	"goapp/internal/service"
+	"goapp/pkg/model"
+	"net/http"
+)
+
+// DecorateRouterWithPipelineHandlers serves the pipelines: list them, start, follow and cancel their runs.
+func DecorateRouterWithPipelineHandlers(router *gin.Engine, pipelineSvc service.PipelineService) {
+	router.GET("/pipelines", func(c *gin.Context) {
+		pipelines, err := pipelineSvc.ListPipelines(c.Request.Context())
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to list pipelines", "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, model.ListPipelines{Pipelines: pipelines, Count: len(pipelines)})
+	})
+
+	router.POST("/pipelines/:namespace/:name/runs", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		run, err := pipelineSvc.StartPipeline(c.Request.Context(), namespace, name)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to start pipeline", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, run)
+	})
+
+	router.GET("/pipelines/:namespace/:name/runs", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		runs, err := pipelineSvc.PipelineRuns(c.Request.Context(), namespace, name)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to list pipeline runs", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, model.ListPipelineRuns{Runs: runs, Count: len(runs)})
+	})
+
+	router.GET("/pipelines/:namespace/:name/runs/:run", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		run, err := pipelineSvc.PipelineRun(c.Request.Context(), namespace, name, c.Param("run"))
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to get pipeline run", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, run)
+	})
+
+	router.POST("/pipelines/:namespace/:name/runs/:run/cancel", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		if err := pipelineSvc.CancelPipelineRun(c.Request.Context(), namespace, name, c.Param("run")); err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to cancel pipeline run", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusAccepted)
+	})
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"goapp/internal/logging"
+	"goapp/internal/service"
+	"goapp/pkg/model"
+	"net/http"
+)
+
+// DecorateRouterWithScheduleHandlers lets users schedule single runs of managed Jobs at a given time.
+func DecorateRouterWithScheduleHandlers(router *gin.Engine, scheduleSvc service.ScheduleService) {
+	router.GET("/schedule", func(c *gin.Context) {
+		runs, err := scheduleSvc.Schedules(c.Request.Context())
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to list scheduled runs", "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, model.ListScheduledRuns{Runs: runs, Count: len(runs)})
+	})
+
+	router.POST("/schedule/:namespace/:name", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		var req model.ScheduleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid body, expected {\"at\": ..., \"timeZone\": ..., \"params\": {...}}"})
+			return
+		}
+		run, err := scheduleSvc.Schedule(c.Request.Context(), namespace, name, req)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to schedule run", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, run)
+	})
+
+	router.POST("/schedule/:namespace/:name/:id/cancel", func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		if err := scheduleSvc.CancelSchedule(c.Request.Context(), namespace, name, c.Param("id")); err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to cancel scheduled run", "namespace", namespace, "name", name, "error", err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+}
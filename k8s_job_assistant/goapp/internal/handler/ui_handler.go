@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"goapp/internal/ui"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	// Vite fingerprints the assets file names, they can be cached forever
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	// index.html references the fingerprinted assets, it must always be revalidated
+	noCacheControl = "no-cache"
+)
+
+// DecorateRouterWithUIHandlers serves the React UI from assets, with SPA fallback:
+// any GET request for an HTML page not matching an API route gets index.html
+// for the React app to route it client side.
+func DecorateRouterWithUIHandlers(router *gin.Engine, assets fs.FS) {
+	serveIndex := func(c *gin.Context) {
+		index, err := fs.ReadFile(assets, ui.IndexFile)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "UI not available"})
+			return
+		}
+		c.Header("Cache-Control", noCacheControl)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", index)
+	}
+
+	router.GET("/", serveIndex)
+
+	router.GET("/assets/*filepath", func(c *gin.Context) {
+		name := path.Join("assets", path.Clean("/"+c.Param("filepath")))
+		if _, err := fs.Stat(assets, name); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		c.Header("Cache-Control", immutableCacheControl)
+		http.ServeFileFS(c.Writer, c.Request, assets, name)
+	})
+
+	router.NoRoute(func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		// top level static files (favicon.ico, robots.txt, ...)
+		name := strings.TrimPrefix(path.Clean(c.Request.URL.Path), "/")
+		if info, err := fs.Stat(assets, name); err == nil && !info.IsDir() && name != ui.IndexFile {
+			c.Header("Cache-Control", noCacheControl)
+			http.ServeFileFS(c.Writer, c.Request, assets, name)
+			return
+		}
+
+		if strings.Contains(c.GetHeader("Accept"), "text/html") {
+			serveIndex(c)
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	})
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func newUIRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/list", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"jobs": []string{}}) })
+	DecorateRouterWithUIHandlers(router, fstest.MapFS{
+		"index.html":             {Data: []byte("<html>kja</html>")},
+		"favicon.ico":            {Data: []byte("icon")},
+		"assets/index-abc123.js": {Data: []byte("console.log('kja')")},
+	})
+	return router
+}
+
+func serve(router *gin.Engine, method, target, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestUIServesIndexWithoutCache(t *testing.T) {
+	rec := serve(newUIRouter(), http.MethodGet, "/", "text/html")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "<html>kja</html>", rec.Body.String())
+	assert.Equal(t, noCacheControl, rec.Header().Get("Cache-Control"))
+}
+
+func TestUIServesFingerprintedAssetsImmutable(t *testing.T) {
+	router := newUIRouter()
+
+	rec := serve(router, http.MethodGet, "/assets/index-abc123.js", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, immutableCacheControl, rec.Header().Get("Cache-Control"))
+
+	rec = serve(router, http.MethodGet, "/assets/../index.html", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = serve(router, http.MethodGet, "/assets/missing.js", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestUISPAFallback(t *testing.T) {
+	router := newUIRouter()
+
+	// client side route, browser navigation
+	rec := serve(router, http.MethodGet, "/jobs/billing/nightly", "text/html,application/xhtml+xml")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "<html>kja</html>", rec.Body.String())
+
+	// unknown API call
+	rec = serve(router, http.MethodGet, "/unknown", "application/json")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.JSONEq(t, `{"error":"Not found"}`, rec.Body.String())
+
+	// top level static file
+	rec = serve(router, http.MethodGet, "/favicon.ico", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "icon", rec.Body.String())
+
+	// API routes are untouched
+	rec = serve(router, http.MethodGet, "/list", "text/html")
+	assert.JSONEq(t, `{"jobs":[]}`, rec.Body.String())
+}
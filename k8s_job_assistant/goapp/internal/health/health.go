@@ -0,0 +1,80 @@
+// Package health tracks KJA liveness and readiness
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check returns an error when the checked dependency is not ready.
+type Check func(ctx context.Context) error
+
+// Checker aggregates readiness checks registered by KJA components (Kubernetes
+// API reachability, informer caches sync, ...) and the shutdown state.
+type Checker struct {
+	mu       sync.RWMutex
+	names    []string
+	checks   map[string]Check
+	draining atomic.Bool
+	timeout  time.Duration
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{checks: map[string]Check{}, timeout: timeout}
+}
+
+// AddReadinessCheck registers a check, KJA is ready only once all checks pass.
+func (c *Checker) AddReadinessCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// Drain marks KJA as not ready anymore, so no new traffic is routed to it while
+// shutting down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Ready runs all readiness checks and returns the failure of each failing one,
+// indexed by check name. An empty result means ready.
+func (c *Checker) Ready(ctx context.Context) map[string]string {
+	failures := map[string]string{}
+	if c.draining.Load() {
+		failures["shutdown"] = "shutting down"
+		return failures
+	}
+
+	c.mu.RLock()
+	names := append([]string(nil), c.names...)
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	for _, name := range names {
+		if err := checks[name](ctx); err != nil {
+			failures[name] = err.Error()
+		}
+	}
+	return failures
+}
+
+// Synced returns a check passing once hasSynced does, typically an informer HasSynced.
+func Synced(what string, hasSynced func() bool) Check {
+	return func(context.Context) error {
+		if !hasSynced() {
+			return fmt.Errorf("%s not synced yet", what)
+		}
+		return nil
+	}
+}
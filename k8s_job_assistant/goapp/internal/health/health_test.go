@@ -0,0 +1,32 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCheckerReady(t *testing.T) {
+	checker := NewChecker(time.Second)
+	synced := false
+	checker.AddReadinessCheck("kubernetes-api", func(context.Context) error { return nil })
+	checker.AddReadinessCheck("jobs-cache", Synced("jobs cache", func() bool { return synced }))
+
+	assert.Equal(t, map[string]string{"jobs-cache": "jobs cache not synced yet"}, checker.Ready(context.Background()))
+
+	synced = true
+	assert.Empty(t, checker.Ready(context.Background()))
+
+	checker.AddReadinessCheck("kubernetes-api", func(context.Context) error { return errors.New("connection refused") })
+	assert.Equal(t, map[string]string{"kubernetes-api": "connection refused"}, checker.Ready(context.Background()))
+}
+
+func TestCheckerDrain(t *testing.T) {
+	checker := NewChecker(time.Second)
+	assert.Empty(t, checker.Ready(context.Background()))
+
+	checker.Drain()
+	assert.Equal(t, map[string]string{"shutdown": "shutting down"}, checker.Ready(context.Background()))
+}
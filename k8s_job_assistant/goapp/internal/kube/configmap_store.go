@@ -0,0 +1,106 @@
+package kube
+
+import (
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// ConfigMapStore persists string values in the data of a single ConfigMap, created on the first
+// write. Concurrent writes from several replicas are retried on conflict.
+type ConfigMapStore interface {
+	List(ctx context.Context) (map[string]string, error)
+	Put(ctx context.Context, key, value string) error
+	// Delete removes a key and tells whether it was there: a single caller wins when several delete it
+	Delete(ctx context.Context, key string) (bool, error)
+}
+
+type configMapStore struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	name       string
+}
+
+func NewConfigMapStore(kubeClient *kubernetes.Clientset, namespace, name string) ConfigMapStore {
+	return &configMapStore{kubeClient: kubeClient, namespace: namespace, name: name}
+}
+
+func (s *configMapStore) get(ctx context.Context) (*corev1.ConfigMap, error) {
+	var configMap *corev1.ConfigMap
+	err := kubeCall(ctx, "get", "configmaps", s.namespace, s.name, func(ctx context.Context) (err error) {
+		configMap, err = s.kubeClient.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+		return err
+	})
+	return configMap, err
+}
+
+func (s *configMapStore) List(ctx context.Context) (map[string]string, error) {
+	configMap, err := s.get(ctx)
+	if errors.IsNotFound(err) {
+		return map[string]string{}, nil // nothing stored yet
+	}
+	if err != nil {
+		return nil, err
+	}
+	return configMap.Data, nil
+}
+
+func (s *configMapStore) Put(ctx context.Context, key, value string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := s.get(ctx)
+		if errors.IsNotFound(err) {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: s.namespace, Name: s.name},
+				Data:       map[string]string{key: value},
+			}
+			err = kubeCall(ctx, "create", "configmaps", s.namespace, s.name, func(ctx context.Context) error {
+				_, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).Create(ctx, configMap, metav1.CreateOptions{})
+				return err
+			})
+			if errors.IsAlreadyExists(err) {
+				// created by another replica meanwhile, retry as a conflict
+				return errors.NewConflict(corev1.Resource("configmaps"), s.name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[key] = value
+		return s.update(ctx, configMap)
+	})
+}
+
+func (s *configMapStore) Delete(ctx context.Context, key string) (bool, error) {
+	deleted := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deleted = false // another replica may have deleted the key since the conflicting attempt
+		configMap, err := s.get(ctx)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := configMap.Data[key]; !ok {
+			return nil
+		}
+		delete(configMap.Data, key)
+		deleted = true
+		return s.update(ctx, configMap)
+	})
+	return deleted && err == nil, err
+}
+
+func (s *configMapStore) update(ctx context.Context, configMap *corev1.ConfigMap) error {
+	return kubeCall(ctx, "update", "configmaps", s.namespace, s.name, func(ctx context.Context) error {
+		_, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+}
@@ -0,0 +1,40 @@
+package kube
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+)
+
+func TestConfigMapStoreDeleteAfterConflict(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kja", Name: "kja-schedules"},
+		Data:       map[string]string{"20250602-030000-4f2a9c": "{}"},
+	}
+	kubeClient := fake.NewClientset(configMap)
+	conflicts := 0
+	kubeClient.PrependReactor("update", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts > 0 {
+			return false, nil, nil
+		}
+		conflicts++
+		// another replica deletes the key meanwhile
+		deletedElsewhere := configMap.DeepCopy()
+		deletedElsewhere.Data = map[string]string{}
+		require.NoError(t, kubeClient.Tracker().Update(corev1.SchemeGroupVersion.WithResource("configmaps"), deletedElsewhere, "kja"))
+		return true, nil, errors.NewConflict(corev1.Resource("configmaps"), configMap.Name, nil)
+	})
+	s := &configMapStore{kubeClient: kubeClient, namespace: "kja", name: "kja-schedules"}
+
+	deleted, err := s.Delete(context.Background(), "20250602-030000-4f2a9c")
+	require.NoError(t, err)
+	assert.False(t, deleted, "the other replica deleted the key")
+	assert.Equal(t, 1, conflicts)
+}
@@ -8,3 +8,54 @@ type JobAlreadyRunningError struct {
 func (e *JobAlreadyRunningError) Error() string {
 	return fmt.Sprintf("job is already running, wait for completion or attempt to kill it")
 }
+
+type NoPodError struct {
+	Namespace string
+	JobName   string
+}
+
+func (e *NoPodError) Error() string {
+	return fmt.Sprintf("job %s/%s has no pod, it has not run yet or its pods were deleted", e.Namespace, e.JobName)
+}
+
+// InvalidRunOptionsError is returned when the RunOptions of a run are not allowed for the Job.
+type InvalidRunOptionsError struct {
+	Reason string
+}
+
+func (e *InvalidRunOptionsError) Error() string {
+	return "invalid run options: " + e.Reason
+}
+
+// HookNotConfiguredError is returned when triggering a Job which does not accept inbound webhooks.
+type HookNotConfiguredError struct {
+	Namespace string
+	JobName   string
+}
+
+func (e *HookNotConfiguredError) Error() string {
+	return fmt.Sprintf("job %s/%s does not accept webhooks, it has no hook-secret annotation", e.Namespace, e.JobName)
+}
+
+// InvalidKillOptionsError is returned when the KillOptions of a kill can not be applied.
+type InvalidKillOptionsError struct {
+	Reason string
+}
+
+func (e *InvalidKillOptionsError) Error() string {
+	return "invalid kill options: " + e.Reason
+}
+
+// OverrideForbiddenError is returned when a user not listed by the OverrideUsersAnnotation of a
+// Job overrides its fields, or when the identity of the user can not be trusted (User is empty).
+type OverrideForbiddenError struct {
+	User       string
+	Annotation string
+}
+
+func (e *OverrideForbiddenError) Error() string {
+	if e.User == "" {
+		return "overriding the job fields requires a trusted user identity: a gRPC client certificate, or a user header set by a trusted proxy"
+	}
+	return fmt.Sprintf("%s is not allowed to override the job fields, see the %s annotation", e.User, e.Annotation)
+}
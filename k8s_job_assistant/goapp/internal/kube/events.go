@@ -0,0 +1,52 @@
+package kube
+
+import (
+	"context"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sort"
+	"time"
+)
+
+// Events returns the Events of a Job and of its pods, oldest first. Kubernetes only keeps them
+// for an hour by default.
+func (j *jobManager) Events(ctx context.Context, job *batchv1.Job, pods []corev1.Pod) (events []corev1.Event, err error) {
+	ctx, span := startSpan(ctx, "JobManager.Events", job.Namespace, job.Name)
+	defer func() { endSpan(span, err) }()
+
+	involved := map[types.UID]bool{job.UID: true}
+	for _, pod := range pods {
+		involved[pod.UID] = true
+	}
+	var list *corev1.EventList
+	err = kubeCall(ctx, "list", "events", job.Namespace, job.Name, func(ctx context.Context) (err error) {
+		list, err = j.kubeClient.CoreV1().Events(job.Namespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range list.Items {
+		if involved[event.InvolvedObject.UID] {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(a, b int) bool {
+		return eventTime(&events[a]).Before(eventTime(&events[b]))
+	})
+	return events, nil
+}
+
+// eventTime is when an Event last happened, whichever API (core or events.k8s.io) recorded it
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
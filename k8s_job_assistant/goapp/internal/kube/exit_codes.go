@@ -0,0 +1,31 @@
+package kube
+
+import (
+	"context"
+	"goapp/pkg/model"
+)
+
+// ExitCodes returns how the containers of the pods of a Job terminated, oldest pod first. Running
+// containers and pods already deleted are not reported.
+func (j *jobManager) ExitCodes(ctx context.Context, namespace, jobName string) (exitCodes []model.ExitCode, err error) {
+	ctx, span := startSpan(ctx, "JobManager.ExitCodes", namespace, jobName)
+	defer func() { endSpan(span, err) }()
+
+	pods, err := j.Pods(ctx, namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if terminated := status.State.Terminated; terminated != nil {
+				exitCodes = append(exitCodes, model.ExitCode{
+					Pod:       pod.Name,
+					Container: status.Name,
+					ExitCode:  terminated.ExitCode,
+					Reason:    terminated.Reason,
+				})
+			}
+		}
+	}
+	return exitCodes, nil
+}
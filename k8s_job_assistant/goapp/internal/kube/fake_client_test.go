@@ -0,0 +1,99 @@
+package kube
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/pointer"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newFakeKubeClient returns a fake client serving objects. The fake client does not implement
+// dry runs, they are accepted without storing anything.
+func newFakeKubeClient(objects ...runtime.Object) *fake.Clientset {
+	kubeClient := fake.NewClientset(objects...)
+	kubeClient.PrependReactor("create", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		create := action.(k8stesting.CreateActionImpl)
+		return slices.Contains(create.CreateOptions.DryRun, metav1.DryRunAll), create.Object, nil
+	})
+	return kubeClient
+}
+
+// completedJob is a Job whose last run succeeded
+func completedJob() *batchv1.Job {
+	start := metav1.NewTime(time.Date(2025, 6, 2, 3, 0, 0, 0, time.UTC))
+	end := metav1.NewTime(start.Add(10 * time.Minute))
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "billing", Name: "nightly", Annotations: map[string]string{"job-assistant": "enable"}},
+		Spec: batchv1.JobSpec{
+			Suspend: pointer.Bool(false),
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "export", Image: "registry.local:5000/billing/export:1.4.2",
+			}}}},
+		},
+		Status: batchv1.JobStatus{
+			StartTime: &start, CompletionTime: &end, Succeeded: 1,
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+func TestRestartCompletedJob(t *testing.T) {
+	kubeClient := newFakeKubeClient(completedJob())
+	j := &jobManager{kubeClient: kubeClient, jobAssistAnnotation: "job-assistant"}
+	ctx := context.Background()
+
+	require.NoError(t, j.Kill(ctx, "billing", "nightly", KillOptions{Mode: KillForce}))
+	var recreated *batchv1.Job
+	require.NoError(t, j.Run(ctx, "billing", "nightly", RunOptions{KeepOverrides: true, BeforeRecreate: func(_ context.Context, job *batchv1.Job) {
+		recreated = job
+	}}))
+	require.NotNil(t, recreated)
+	assert.True(t, hasCondition(recreated, batchv1.JobComplete), "BeforeRecreate gets the Job as its last run left it")
+
+	job, err := kubeClient.BatchV1().Jobs("billing").Get(ctx, "nightly", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.False(t, *job.Spec.Suspend)
+	assert.Empty(t, job.Status.Conditions, "the completed Job is re-created, resuming it would not run it again")
+	assert.NotEmpty(t, job.Annotations["job-assistant/last-success-completion-time"])
+}
+
+func TestKillSuspendsIndexRetries(t *testing.T) {
+	failed := completedJob()
+	failed.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+	retry := func(index string, conditions ...batchv1.JobCondition) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "billing", Name: "nightly-retry-" + index, Labels: map[string]string{
+				"job-assistant/retry-of": "nightly", "job-assistant/retry-index": index,
+			}},
+			Status: batchv1.JobStatus{Active: 1, Conditions: conditions},
+		}
+	}
+	kubeClient := newFakeKubeClient(failed, retry("3"), retry("5", batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}))
+	j := &jobManager{kubeClient: kubeClient, jobAssistAnnotation: "job-assistant"}
+	ctx := context.Background()
+
+	require.NoError(t, j.Kill(ctx, "billing", "nightly", KillOptions{Mode: KillForce}))
+
+	running, err := kubeClient.BatchV1().Jobs("billing").Get(ctx, "nightly-retry-3", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.True(t, pointer.BoolDeref(running.Spec.Suspend, false), "the running retry is suspended")
+	succeeded, err := kubeClient.BatchV1().Jobs("billing").Get(ctx, "nightly-retry-5", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, succeeded.Spec.Suspend, "the finished retry is left alone")
+	var deletedPods []string
+	for _, action := range kubeClient.Actions() {
+		if deleteCollection, ok := action.(k8stesting.DeleteCollectionActionImpl); ok {
+			deletedPods = append(deletedPods, deleteCollection.ListRestrictions.Labels.String())
+		}
+	}
+	assert.Equal(t, []string{"job-name=nightly", "job-name=nightly-retry-3"}, deletedPods)
+}
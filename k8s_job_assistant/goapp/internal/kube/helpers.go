@@ -1,10 +1,15 @@
 package kube
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"log/slog"
+	"time"
 )
 
 func newTrue() *bool {
@@ -28,7 +33,7 @@ func InitKubeClient(kubeconfigPath string) *kubernetes.Clientset {
 	if kubeconfigPath != "" {
 		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
 		if err != nil {
-			fmt.Printf("Warning: Failed to load kubeconfig: %v\nFalling back to in-cluster config.\n", err)
+			slog.Warn("failed to load kubeconfig, falling back to in-cluster config", "kubeconfig", kubeconfigPath, "error", err)
 		}
 	}
 
@@ -48,3 +53,20 @@ func InitKubeClient(kubeconfigPath string) *kubernetes.Clientset {
 
 	return clientset
 }
+
+// APIServerReadyCheck returns a check failing when the Kubernetes API server can not
+// be reached or is not ready itself.
+func APIServerReadyCheck(kubeClient *kubernetes.Clientset) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return kubeCall(ctx, "get", "readyz", "", "", func(ctx context.Context) error {
+			return kubeClient.Discovery().RESTClient().Get().AbsPath("/readyz").Do(ctx).Error()
+		})
+	}
+}
+
+// NewRunID returns a new run identifier, sortable by run start, ie: 20250602-030000-4f2a9c
+func NewRunID() string {
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102-150405"), hex.EncodeToString(suffix))
+}
@@ -0,0 +1,42 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+const defaultHookSecretKey = "secret"
+
+// HookSecret returns the secret inbound webhooks of job are signed with, read from the Secret
+// referenced by its HookSecretAnnotation.
+func (j *jobManager) HookSecret(ctx context.Context, job *batchv1.Job) (secret []byte, err error) {
+	ctx, span := startSpan(ctx, "JobManager.HookSecret", job.Namespace, job.Name)
+	defer func() { endSpan(span, err) }()
+
+	ref := job.Annotations[j.AnnotationKey(HookSecretAnnotation)]
+	if ref == "" {
+		return nil, &HookNotConfiguredError{Namespace: job.Namespace, JobName: job.Name}
+	}
+	name, key, ok := strings.Cut(ref, "/")
+	if !ok {
+		key = defaultHookSecretKey
+	}
+
+	var s *corev1.Secret
+	err = kubeCall(ctx, "get", "secrets", job.Namespace, name, func(ctx context.Context) (err error) {
+		s, err = j.kubeClient.CoreV1().Secrets(job.Namespace).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the hook secret of job %s/%s: %w", job.Namespace, job.Name, err)
+	}
+	secret = s.Data[key]
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("hook secret %s/%s of job %s has no %q key", job.Namespace, name, job.Name, key)
+	}
+	return secret, nil
+}
@@ -0,0 +1,265 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"goapp/internal/logging"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Labels of the Jobs re-running the failed indexes of an Indexed Job, prefixed like the annotations
+const (
+	// RetryOfLabel is the name of the Indexed Job whose index is re-run
+	RetryOfLabel = "retry-of"
+	// RetryIndexLabel is the index re-run
+	RetryIndexLabel = "retry-index"
+)
+
+// completionIndexEnv is set by Kubernetes on the pods of Indexed Jobs, and by KJA on the index retries
+const completionIndexEnv = "JOB_COMPLETION_INDEX"
+
+// IsIndexed tells whether the pods of a Job get a completion index
+func IsIndexed(job *batchv1.Job) bool {
+	return job.Spec.CompletionMode != nil && *job.Spec.CompletionMode == batchv1.IndexedCompletion
+}
+
+// FailedIndexes returns the indexes of a failed Indexed Job which did not succeed: its
+// failedIndexes when it has a backoffLimitPerIndex, otherwise the indexes not completed.
+func FailedIndexes(job *batchv1.Job) ([]int, error) {
+	if job.Status.FailedIndexes != nil {
+		return ParseIndexes(*job.Status.FailedIndexes)
+	}
+	completed, err := ParseIndexes(job.Status.CompletedIndexes)
+	if err != nil {
+		return nil, err
+	}
+	var failed []int
+	for i := 0; i < int(pointer.Int32Deref(job.Spec.Completions, 0)); i++ {
+		if _, found := slices.BinarySearch(completed, i); !found {
+			failed = append(failed, i)
+		}
+	}
+	return failed, nil
+}
+
+// ParseIndexes parses indexes in the format of the Job status, ie: "1,3-5,7", in increasing order
+func ParseIndexes(value string) ([]int, error) {
+	var indexes []int
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(item, "-")
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid indexes %q: %w", value, err)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(last); err != nil {
+				return nil, fmt.Errorf("invalid indexes %q: %w", value, err)
+			}
+		}
+		for i := from; i <= to; i++ {
+			indexes = append(indexes, i)
+		}
+	}
+	slices.Sort(indexes)
+	return slices.Compact(indexes), nil
+}
+
+// FormatIndexes formats increasing indexes like the Job status, ie: "1,3-5,7"
+func FormatIndexes(indexes []int) string {
+	var items []string
+	for i := 0; i < len(indexes); {
+		j := i
+		for j+1 < len(indexes) && indexes[j+1] == indexes[j]+1 {
+			j++
+		}
+		if j == i {
+			items = append(items, strconv.Itoa(indexes[i]))
+		} else {
+			items = append(items, fmt.Sprintf("%d-%d", indexes[i], indexes[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(items, ",")
+}
+
+// RetryFailedIndexes re-runs the failed indexes of a failed Indexed Job, each one in a Job derived
+// from it (see indexRetryJob), and returns them. Indexes whose retry is running or succeeded are
+// not re-run again, failed retries are.
+func (j *jobManager) RetryFailedIndexes(ctx context.Context, namespace, jobName string) (retried []int, err error) {
+	ctx, span := startSpan(ctx, "JobManager.RetryFailedIndexes", namespace, jobName)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 40*time.Second)
+	defer cancel()
+
+	job, err := j.Get(ctx, namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	if !IsIndexed(job) {
+		return nil, &InvalidRunOptionsError{Reason: fmt.Sprintf("job %s/%s is not an Indexed Job", namespace, jobName)}
+	}
+	if isJobRunning(job.Status) {
+		return nil, &JobAlreadyRunningError{}
+	}
+	if !hasCondition(job, batchv1.JobFailed) {
+		return nil, &InvalidRunOptionsError{Reason: fmt.Sprintf("the last run of job %s/%s did not fail", namespace, jobName)}
+	}
+	failed, err := FailedIndexes(job)
+	if err != nil {
+		return nil, err
+	}
+
+	retries, err := j.IndexRetries(ctx, namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	previous := map[string]*batchv1.Job{}
+	for i := range retries {
+		previous[retries[i].Labels[j.AnnotationKey(RetryIndexLabel)]] = &retries[i]
+	}
+
+	logger := logging.FromContext(ctx)
+	for _, index := range failed {
+		if retry := previous[strconv.Itoa(index)]; retry != nil {
+			if !hasCondition(retry, batchv1.JobFailed) {
+				continue // running or succeeded
+			}
+			if err := deleteJobAndWaitForDeletion(ctx, j.kubeClient, namespace, retry.Name); err != nil {
+				return retried, err
+			}
+		}
+		retry := j.indexRetryJob(ctx, job, index)
+		err := kubeCall(ctx, "create", "jobs", namespace, retry.Name, func(ctx context.Context) error {
+			_, err := j.kubeClient.BatchV1().Jobs(namespace).Create(ctx, retry, metav1.CreateOptions{})
+			return err
+		})
+		if err != nil {
+			return retried, err
+		}
+		logger.Info("index retry created", "index", index, "retry_job", retry.Name)
+		retried = append(retried, index)
+	}
+	return retried, nil
+}
+
+// IndexRetries lists the Jobs re-running the failed indexes of the current run of an Indexed Job
+func (j *jobManager) IndexRetries(ctx context.Context, namespace, jobName string) ([]batchv1.Job, error) {
+	job, err := j.Get(ctx, namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	var list *batchv1.JobList
+	err = kubeCall(ctx, "list", "jobs", namespace, jobName, func(ctx context.Context) (err error) {
+		list, err = j.kubeClient.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", j.AnnotationKey(RetryOfLabel), jobName),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	runID := job.Annotations[j.AnnotationKey(RunIDAnnotation)]
+	var retries []batchv1.Job
+	for _, retry := range list.Items {
+		if retry.Annotations[j.AnnotationKey(RunIDAnnotation)] == runID {
+			retries = append(retries, retry)
+		}
+	}
+	return retries, nil
+}
+
+// indexRetryJob derives from an Indexed Job a Job running a single of its indexes: the pod gets
+// the index through JOB_COMPLETION_INDEX, like the pods of the Indexed Job. The retry is owned by
+// the Job so it is deleted along with it, and is not managed by KJA.
+func (j *jobManager) indexRetryJob(ctx context.Context, job *batchv1.Job, index int) *batchv1.Job {
+	suffix := fmt.Sprintf("-retry-%d", index)
+	name := job.Name
+	if len(name)+len(suffix) > 63 { // Job names end up in the job-name label of the pods
+		name = name[:63-len(suffix)]
+	}
+	retry := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + suffix,
+			Namespace: job.Namespace,
+			Labels: map[string]string{
+				j.AnnotationKey(RetryOfLabel):    job.Name,
+				j.AnnotationKey(RetryIndexLabel): strconv.Itoa(index),
+			},
+			Annotations: map[string]string{
+				j.AnnotationKey(RunIDAnnotation):       job.Annotations[j.AnnotationKey(RunIDAnnotation)],
+				j.AnnotationKey(TriggeredByAnnotation): logging.UserFromContext(ctx),
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: batchv1.SchemeGroupVersion.String(),
+				Kind:       "Job",
+				Name:       job.Name,
+				UID:        job.UID,
+			}},
+		},
+		Spec: *job.Spec.DeepCopy(),
+	}
+
+	spec := &retry.Spec
+	nonIndexed := batchv1.NonIndexedCompletion
+	spec.CompletionMode = &nonIndexed
+	spec.Completions = pointer.Int32(1)
+	spec.Parallelism = pointer.Int32(1)
+	if spec.BackoffLimitPerIndex != nil {
+		spec.BackoffLimit = spec.BackoffLimitPerIndex
+	}
+	spec.BackoffLimitPerIndex = nil
+	spec.MaxFailedIndexes = nil
+	spec.SuccessPolicy = nil
+	spec.Suspend = nil
+	spec.Selector = nil
+	spec.ManualSelector = nil
+	if spec.PodFailurePolicy != nil {
+		for i := range spec.PodFailurePolicy.Rules {
+			if spec.PodFailurePolicy.Rules[i].Action == batchv1.PodFailurePolicyActionFailIndex {
+				spec.PodFailurePolicy.Rules[i].Action = batchv1.PodFailurePolicyActionFailJob
+			}
+		}
+	}
+
+	// labels tying the pods to the Indexed Job are set again by Kubernetes for the retry
+	for _, label := range []string{"job-name", "controller-uid", batchv1.JobNameLabel, batchv1.ControllerUidLabel, batchv1.JobCompletionIndexAnnotation} {
+		delete(spec.Template.Labels, label)
+	}
+	podSpec := &spec.Template.Spec
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			containers[i].Env = withCompletionIndex(containers[i].Env, index)
+		}
+	}
+	return retry
+}
+
+// withCompletionIndex sets JOB_COMPLETION_INDEX to index, and replaces the variables reading the
+// index from the pod annotation or label
+func withCompletionIndex(env []corev1.EnvVar, index int) []corev1.EnvVar {
+	value := strconv.Itoa(index)
+	result := []corev1.EnvVar{{Name: completionIndexEnv, Value: value}}
+	for _, envVar := range env {
+		switch {
+		case envVar.Name == completionIndexEnv:
+		case envVar.ValueFrom != nil && envVar.ValueFrom.FieldRef != nil &&
+			strings.Contains(envVar.ValueFrom.FieldRef.FieldPath, batchv1.JobCompletionIndexAnnotation):
+			result = append(result, corev1.EnvVar{Name: envVar.Name, Value: value})
+		default:
+			result = append(result, envVar)
+		}
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package kube
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"testing"
+)
+
+func TestParseAndFormatIndexes(t *testing.T) {
+	indexes, err := ParseIndexes("7, 0-2,4,1")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 4, 7}, indexes)
+	assert.Equal(t, "0-2,4,7", FormatIndexes(indexes))
+
+	indexes, err = ParseIndexes("")
+	require.NoError(t, err)
+	assert.Empty(t, indexes)
+	assert.Equal(t, "", FormatIndexes(nil))
+
+	_, err = ParseIndexes("1-x")
+	assert.Error(t, err)
+}
+
+func TestFailedIndexes(t *testing.T) {
+	job := &batchv1.Job{
+		Spec:   batchv1.JobSpec{Completions: pointer.Int32(6)},
+		Status: batchv1.JobStatus{CompletedIndexes: "0-2,4"},
+	}
+	failed, err := FailedIndexes(job)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 5}, failed, "indexes not completed")
+
+	job.Status.FailedIndexes = pointer.String("3")
+	failed, err = FailedIndexes(job)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3}, failed, "with a backoffLimitPerIndex, the failed indexes tell")
+}
+
+func TestIndexRetryJob(t *testing.T) {
+	j := &jobManager{jobAssistAnnotation: "job-assistant"}
+	indexed := batchv1.IndexedCompletion
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "shards", Namespace: "billing", UID: "6a1f", Annotations: map[string]string{
+			"job-assistant":        "enable",
+			"job-assistant/run-id": "20250602-030000-4f2a9c",
+		}},
+		Spec: batchv1.JobSpec{
+			CompletionMode:       &indexed,
+			Completions:          pointer.Int32(8),
+			Parallelism:          pointer.Int32(4),
+			BackoffLimitPerIndex: pointer.Int32(2),
+			MaxFailedIndexes:     pointer.Int32(3),
+			Selector:             &metav1.LabelSelector{MatchLabels: map[string]string{"batch.kubernetes.io/controller-uid": "6a1f"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+					"app":                                "shards",
+					"batch.kubernetes.io/controller-uid": "6a1f",
+					"batch.kubernetes.io/job-name":       "shards",
+				}},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name: "shard",
+					Env: []corev1.EnvVar{
+						{Name: "SHARD", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{
+							FieldPath: "metadata.annotations['batch.kubernetes.io/job-completion-index']",
+						}}},
+						{Name: "DATE", Value: "2025-06-02"},
+					},
+				}}},
+			},
+		},
+	}
+
+	retry := j.indexRetryJob(context.Background(), job, 3)
+	assert.Equal(t, "shards-retry-3", retry.Name)
+	assert.Equal(t, map[string]string{"job-assistant/retry-of": "shards", "job-assistant/retry-index": "3"}, retry.Labels)
+	assert.Equal(t, "20250602-030000-4f2a9c", retry.Annotations["job-assistant/run-id"])
+	assert.NotContains(t, retry.Annotations, "job-assistant", "the retry is not managed")
+	require.Len(t, retry.OwnerReferences, 1)
+	assert.Equal(t, job.UID, retry.OwnerReferences[0].UID)
+
+	assert.Equal(t, batchv1.NonIndexedCompletion, *retry.Spec.CompletionMode)
+	assert.Equal(t, int32(1), *retry.Spec.Completions)
+	assert.Equal(t, int32(1), *retry.Spec.Parallelism)
+	assert.Equal(t, int32(2), *retry.Spec.BackoffLimit, "the backoff limit of the index applies")
+	assert.Nil(t, retry.Spec.BackoffLimitPerIndex)
+	assert.Nil(t, retry.Spec.MaxFailedIndexes)
+	assert.Nil(t, retry.Spec.Selector)
+	assert.Equal(t, map[string]string{"app": "shards"}, retry.Spec.Template.Labels)
+	assert.Equal(t, []corev1.EnvVar{
+		{Name: "JOB_COMPLETION_INDEX", Value: "3"},
+		{Name: "SHARD", Value: "3"},
+		{Name: "DATE", Value: "2025-06-02"},
+	}, retry.Spec.Template.Spec.Containers[0].Env)
+
+	assert.Equal(t, int32(8), *job.Spec.Completions, "the Indexed Job is left unchanged")
+}
@@ -0,0 +1,59 @@
+package kube
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"goapp/internal/logging"
+	"goapp/internal/metrics"
+	"goapp/internal/tracing"
+	"time"
+)
+
+var tracer = tracing.Tracer("kube")
+
+// jobAttributes are the span attributes identifying a Job.
+func jobAttributes(namespace, jobName string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("k8s.namespace.name", namespace),
+		attribute.String("k8s.job.name", jobName),
+	}
+}
+
+// startSpan starts a span for a JobManager step, to be ended with endSpan.
+func startSpan(ctx context.Context, name, namespace, jobName string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(jobAttributes(namespace, jobName)...))
+}
+
+// endSpan records err (if any) on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// kubeCall performs a single Kubernetes API call within its own span, records
+// its latency and error in the metrics and logs it at debug level.
+func kubeCall(ctx context.Context, verb, resource, namespace, name string, call func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(ctx, "kube "+verb+" "+resource,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("k8s.api.verb", verb),
+			attribute.String("k8s.api.resource", resource),
+			attribute.String("k8s.namespace.name", namespace),
+			attribute.String("k8s.object.name", name),
+		))
+
+	start := time.Now()
+	err := call(ctx)
+	metrics.ObserveKubeCall(verb, resource, start, err)
+	endSpan(span, err)
+
+	logging.FromContext(ctx).Debug("kube api call",
+		"verb", verb, "resource", resource, "object", name,
+		"duration_ms", time.Since(start).Milliseconds(), "error", err)
+	return err
+}
@@ -3,7 +3,13 @@ package kube
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"goapp/internal/logging"
+	"goapp/pkg/model"
+	"io"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
@@ -17,74 +23,259 @@ import (
 
 // JobManager provides helper methods to interact with Kubernetes Jobs.
 type jobManager struct {
-	kubeClient          *kubernetes.Clientset
+	kubeClient          kubernetes.Interface
 	jobAssistAnnotation string
 }
 
 type JobManager interface {
-	List() ([]batchv1.Job, error)
-	Run(namespace, jobName string) error
-	Kill(namespace, jobName string) error
-	Status(namespace, jobName string) (error, *batchv1.JobStatus)
+	List(ctx context.Context) ([]batchv1.Job, error)
+	Get(ctx context.Context, namespace, jobName string) (*batchv1.Job, error)
+	Run(ctx context.Context, namespace, jobName string, opts RunOptions) error
+	Kill(ctx context.Context, namespace, jobName string, opts KillOptions) error
+	Status(ctx context.Context, namespace, jobName string) (error, *batchv1.JobStatus)
+	Logs(ctx context.Context, namespace, jobName string, opts LogOptions) (io.ReadCloser, error)
+	Watch(ctx context.Context, onUpdate JobUpdateHandler) (hasSynced func() bool, err error)
+	HookSecret(ctx context.Context, job *batchv1.Job) ([]byte, error)
+	// RetryFailedIndexes re-runs the failed indexes of an Indexed Job and returns them
+	RetryFailedIndexes(ctx context.Context, namespace, jobName string) ([]int, error)
+	// IndexRetries lists the Jobs re-running indexes of the current run of an Indexed Job
+	IndexRetries(ctx context.Context, namespace, jobName string) ([]batchv1.Job, error)
+	// Pods returns the pods of a Job, oldest first
+	Pods(ctx context.Context, namespace, jobName string) ([]corev1.Pod, error)
+	// ExitCodes returns how the containers of the pods of a Job terminated
+	ExitCodes(ctx context.Context, namespace, jobName string) ([]model.ExitCode, error)
+	// Events returns the Events of a Job and of its pods, oldest first
+	Events(ctx context.Context, job *batchv1.Job, pods []corev1.Pod) ([]corev1.Event, error)
+	// SecretValues returns the values of the environment variables the pods of a Job source from Secrets
+	SecretValues(ctx context.Context, job *batchv1.Job) ([]string, error)
+	// CheckParams fails with InvalidRunOptionsError when params can not be set on the runs of job
+	CheckParams(job *batchv1.Job, params map[string]string) error
+	// AnnotationKey returns the full key of a KJA annotation, ie: 'job-assistant/run-id' for 'run-id'
+	AnnotationKey(name string) string
 }
 
+// KJA annotations set on managed Jobs, prefixed with the jobAssistAnnotation (see AnnotationKey)
+const (
+	// RunIDAnnotation identifies the current run, set each time the Job is run through KJA
+	RunIDAnnotation = "run-id"
+	// TriggeredByAnnotation is the user who ran the current run
+	TriggeredByAnnotation = "triggered-by"
+	// KilledAtAnnotation is set (RFC3339) when the current run is killed through KJA
+	KilledAtAnnotation = "killed-at"
+	// KillReasonAnnotation is why the current run was killed, when given
+	KillReasonAnnotation = "kill-reason"
+	// MaxDurationAnnotation is how long a run can last (ie: '2h'), the watchdog kills it beyond
+	MaxDurationAnnotation = "max-duration"
+	// NotifyAnnotation routes the notifications sent when a run finishes, see the notify package
+	NotifyAnnotation = "notify"
+	// ParamsAnnotation lists the params (comma separated environment variable names) a run can set
+	ParamsAnnotation = "params"
+	// RunParamsAnnotation holds the params (JSON) set on the current run
+	RunParamsAnnotation = "run-params"
+	// RunParamsRevertAnnotation holds the environment variables (JSON, by container) the params of
+	// the current run replaced
+	RunParamsRevertAnnotation = "run-params-revert"
+	// HookSecretAnnotation references the Secret ('<name>' or '<name>/<key>', key defaults to
+	// 'secret') inbound webhooks of the Job are signed with
+	HookSecretAnnotation = "hook-secret"
+	// HookParamsAnnotation maps params to fields of the inbound webhooks payloads, ie: 'FILE=file.url,DATE=date'
+	HookParamsAnnotation = "hook-params"
+	// OverridesAnnotation lists the fields (comma separated, ie: 'image-tag,resources') a run can
+	// override, see the Override* constants
+	OverridesAnnotation = "overrides"
+	// OverrideUsersAnnotation lists the users (comma separated) allowed to override the Job fields
+	OverrideUsersAnnotation = "override-users"
+	// RunOverridesAnnotation holds the overrides (JSON) of the current run
+	RunOverridesAnnotation = "run-overrides"
+	// RunOverridesRevertAnnotation holds the values (JSON) the overrides of the current run replaced
+	RunOverridesRevertAnnotation = "run-overrides-revert"
+	// LastSuccessStartTimeAnnotation and LastSuccessCompletionTimeAnnotation (RFC3339) keep the
+	// times of the last successful run once a new run replaces it
+	LastSuccessStartTimeAnnotation      = "last-success-start-time"
+	LastSuccessCompletionTimeAnnotation = "last-success-completion-time"
+	// QueueAnnotation is the number of runs queued while the Job is running, runs are rejected
+	// with JobAlreadyRunningError when not set
+	QueueAnnotation = "queue"
+)
+
 func NewJobManager(kubeClient *kubernetes.Clientset, jobAssistAnnotation string) JobManager {
 	return &jobManager{kubeClient: kubeClient, jobAssistAnnotation: jobAssistAnnotation}
 }
 
+func (j *jobManager) AnnotationKey(name string) string {
+	return j.jobAssistAnnotation + "/" + name
+}
+
 // List lists Jobs with annotation 'job-assistant' set to true on any namespace.
-func (j *jobManager) List() ([]batchv1.Job, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+func (j *jobManager) List(ctx context.Context) ([]batchv1.Job, error) {
+	ctx, span := tracer.Start(ctx, "JobManager.List")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
 	defer cancel()
 
-	jobs, err := j.kubeClient.BatchV1().Jobs("").List(ctx, metav1.ListOptions{})
+	var jobs *batchv1.JobList
+	err := kubeCall(ctx, "list", "jobs", "", "", func(ctx context.Context) (err error) {
+		jobs, err = j.kubeClient.BatchV1().Jobs("").List(ctx, metav1.ListOptions{})
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	var filtered []batchv1.Job
 	for _, job := range jobs.Items {
-		if val, ok := job.Annotations[j.jobAssistAnnotation]; ok && val == "enable" {
+		if j.isManaged(&job) {
 			filtered = append(filtered, job)
 		}
 	}
+	span.SetAttributes(attribute.Int("kja.jobs.count", len(filtered)))
 	return filtered, nil
 }
 
+// Get returns a managed Job, without any decoration. Jobs not managed by KJA are reported as not found.
+func (j *jobManager) Get(ctx context.Context, namespace, jobName string) (*batchv1.Job, error) {
+	var job *batchv1.Job
+	err := kubeCall(ctx, "get", "jobs", namespace, jobName, func(ctx context.Context) (err error) {
+		job, err = j.kubeClient.BatchV1().Jobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !j.isManaged(job) {
+		return nil, errors.NewNotFound(batchv1.Resource("jobs"), jobName)
+	}
+	return job, nil
+}
+
+// isManaged tells whether the Job delegates its lifecycle to KJA.
+func (j *jobManager) isManaged(job *batchv1.Job) bool {
+	val, ok := job.Annotations[j.jobAssistAnnotation]
+	return ok && val == "enable"
+}
+
 // Run runs a Job, fails if already running, handle Suspend:true and clean re-create when needed.
-func (j *jobManager) Run(namespace, jobName string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+func (j *jobManager) Run(ctx context.Context, namespace, jobName string, opts RunOptions) (err error) {
+	ctx, span := startSpan(ctx, "JobManager.Run", namespace, jobName)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
 	defer cancel()
 
-	job, err := j.kubeClient.BatchV1().Jobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
+	var job *batchv1.Job
+	err = kubeCall(ctx, "get", "jobs", namespace, jobName, func(ctx context.Context) (err error) {
+		job, err = j.kubeClient.BatchV1().Jobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	if isJobRunning(job.Status) {
-		return &JobAlreadyRunningError{}
+	if job.Annotations == nil {
+		job.Annotations = map[string]string{}
+	}
+	previous := job.DeepCopy() // as the last run left it, for BeforeRecreate
+	templateChanged, err := j.applyRunOptions(job, opts, logging.TrustedUserFromContext(ctx))
+	if err != nil {
+		return err
 	}
+	suspended, err := checkRunnable(ctx, job)
+	if err != nil {
+		return err
+	}
+	j.stampRun(ctx, job)
 
-	// suspended=true, set it to false for Kube to run the Job right away
-	if job.Spec.Suspend != nil && *job.Spec.Suspend {
+	// suspended=true, set it to false for Kube to run the Job right away. A Job killed once
+	// finished (ie: restarted) would not run its pods again, it is re-created below.
+	finished := hasCondition(job, batchv1.JobComplete) || hasCondition(job, batchv1.JobFailed)
+	if suspended && !templateChanged && !finished {
+		logging.FromContext(ctx).Debug("job is suspended, resuming it")
 		job.Spec.Suspend = newFalse()
-		_, err = j.kubeClient.BatchV1().Jobs(namespace).Update(ctx, job, metav1.UpdateOptions{})
-		return err
+		return kubeCall(ctx, "update", "jobs", namespace, jobName, func(ctx context.Context) error {
+			_, err := j.kubeClient.BatchV1().Jobs(namespace).Update(ctx, job, metav1.UpdateOptions{})
+			return err
+		})
 	}
 
-	// suspended=false or absent, deleteJobAndWaitForDeletion Job then recreate it
-	err = deleteJobAndWaitForDeletion(j.kubeClient, namespace, job.Name)
+	// suspended=false or absent, finished, or a new pod template: deleteJobAndWaitForDeletion Job then recreate it
+	job.Spec.Suspend = newFalse()
+	if err := j.dryRunCreate(ctx, job); err != nil {
+		return err
+	}
+	if opts.BeforeRecreate != nil {
+		opts.BeforeRecreate(ctx, previous)
+	}
+	// Once the deletion is requested, the Job must be re-created no matter what: the
+	// caller going away (client disconnect, KJA shutdown) would lose the Job definition.
+	logging.FromContext(ctx).Debug("deleting job for re-creation", "suspended", suspended, "template_changed", templateChanged, "finished", finished)
+	ctx, cancelRecreate := context.WithTimeout(context.WithoutCancel(ctx), 40*time.Second)
+	defer cancelRecreate()
+	err = deleteJobAndWaitForDeletion(ctx, j.kubeClient, namespace, job.Name)
 	if err != nil {
 		return err
 	}
 
-	_, err = j.kubeClient.BatchV1().Jobs(namespace).Create(ctx, cleanJobForRecreate(job), metav1.CreateOptions{})
+	return kubeCall(ctx, "create", "jobs", namespace, jobName, func(ctx context.Context) error {
+		_, err := j.kubeClient.BatchV1().Jobs(namespace).Create(ctx, cleanJobForRecreate(job), metav1.CreateOptions{})
+		return err
+	})
+}
+
+// dryRunCreate fails if Kubernetes would reject the re-creation of job, ie: overrides of the
+// resources or node selector it does not accept. It is checked before deleting the Job, which
+// could not be created back. The Job still exists, so the dry run generates another name.
+func (j *jobManager) dryRunCreate(ctx context.Context, job *batchv1.Job) error {
+	recreated := cleanJobForRecreate(job)
+	recreated.GenerateName = recreated.Name + "-"
+	recreated.Name = ""
+	err := kubeCall(ctx, "create", "jobs", job.Namespace, job.Name, func(ctx context.Context) error {
+		_, err := j.kubeClient.BatchV1().Jobs(job.Namespace).Create(ctx, recreated, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+		return err
+	})
+	if errors.IsInvalid(err) {
+		return &InvalidRunOptionsError{Reason: fmt.Sprintf("the job would be rejected by Kubernetes: %v", err)}
+	}
 	return err
 }
 
+// stampRun identifies the new run on the Job annotations, and clears the ones of the previous run.
+// The times of the previous run are kept when it succeeded, the new run wiping the Job status.
+func (j *jobManager) stampRun(ctx context.Context, job *batchv1.Job) {
+	if hasCondition(job, batchv1.JobComplete) && job.Status.StartTime != nil && job.Status.CompletionTime != nil {
+		job.Annotations[j.AnnotationKey(LastSuccessStartTimeAnnotation)] = job.Status.StartTime.UTC().Format(time.RFC3339)
+		job.Annotations[j.AnnotationKey(LastSuccessCompletionTimeAnnotation)] = job.Status.CompletionTime.UTC().Format(time.RFC3339)
+	}
+	runID := NewRunID()
+	job.Annotations[j.AnnotationKey(RunIDAnnotation)] = runID
+	job.Annotations[j.AnnotationKey(TriggeredByAnnotation)] = logging.UserFromContext(ctx)
+	delete(job.Annotations, j.AnnotationKey(KilledAtAnnotation))
+	delete(job.Annotations, j.AnnotationKey(KillReasonAnnotation))
+	logging.FromContext(ctx).Debug("new run", "run_id", runID)
+}
+
+// checkRunnable fails if the Job is already running, otherwise tells whether it is suspended.
+func checkRunnable(ctx context.Context, job *batchv1.Job) (suspended bool, err error) {
+	_, span := startSpan(ctx, "JobManager.checkRunnable", job.Namespace, job.Name)
+	defer func() { endSpan(span, err) }()
+
+	running := isJobRunning(job.Status)
+	suspended = job.Spec.Suspend != nil && *job.Spec.Suspend
+	span.SetAttributes(attribute.Bool("kja.job.running", running), attribute.Bool("kja.job.suspended", suspended))
+
+	if running {
+		return suspended, &JobAlreadyRunningError{}
+	}
+	return suspended, nil
+}
+
 // Status returns the full Kubernetes status of job, without any decoration.
-func (j *jobManager) Status(namespace, jobName string) (error, *batchv1.JobStatus) {
-	job, err := j.kubeClient.BatchV1().Jobs(namespace).Get(context.Background(), jobName, metav1.GetOptions{})
+func (j *jobManager) Status(ctx context.Context, namespace, jobName string) (error, *batchv1.JobStatus) {
+	var job *batchv1.Job
+	err := kubeCall(ctx, "get", "jobs", namespace, jobName, func(ctx context.Context) (err error) {
+		job, err = j.kubeClient.BatchV1().Jobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
+		return err
+	})
 	if err != nil {
 		return err, nil
 	}
@@ -93,39 +284,154 @@ func (j *jobManager) Status(namespace, jobName string) (error, *batchv1.JobStatu
 }
 
 // Kill suspends the Job and delete all of its running pod.
-func (j *jobManager) Kill(namespace, jobName string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second) //TODO configure deletion timeout
-	defer cancel()
+func (j *jobManager) Kill(ctx context.Context, namespace, jobName string, opts KillOptions) (err error) {
+	ctx, span := startSpan(ctx, "JobManager.Kill", namespace, jobName)
+	defer func() { endSpan(span, err) }()
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	mode := opts.Mode
+	if mode == "" {
+		mode = KillGraceful
+	}
+	span.SetAttributes(attribute.String("kja.kill.mode", string(mode)))
 	//Job is kept for later usage
 
 	// suspend the Job to prevent Kubernetes from recreating the pods
-	patch := []byte(`{"spec":{"suspend":true}}`)
-	_, err := j.kubeClient.BatchV1().Jobs(namespace).Patch(ctx, jobName, types.MergePatchType, patch, metav1.PatchOptions{})
+	annotations := map[string]any{
+		j.AnnotationKey(KilledAtAnnotation):   time.Now().UTC().Format(time.RFC3339),
+		j.AnnotationKey(KillReasonAnnotation): nil, // removed unless given
+	}
+	if opts.Reason != "" {
+		annotations[j.AnnotationKey(KillReasonAnnotation)] = opts.Reason
+	}
+	patch, err := json.Marshal(map[string]any{
+		"spec":     map[string]any{"suspend": true},
+		"metadata": map[string]any{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+	var job *batchv1.Job
+	patchCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+	err = kubeCall(patchCtx, "patch", "jobs", namespace, jobName, func(ctx context.Context) (err error) {
+		job, err = j.kubeClient.BatchV1().Jobs(namespace).Patch(ctx, jobName, types.MergePatchType, patch, metav1.PatchOptions{})
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	// deleteJobAndWaitForDeletion the pods by labels
-	err = j.kubeClient.CoreV1().Pods(namespace).DeleteCollection(
-		ctx,
-		metav1.DeleteOptions{},
-		metav1.ListOptions{
+	if err := j.killPods(ctx, span, job, mode, opts); err != nil {
+		return err
+	}
+	return j.killIndexRetries(ctx, span, namespace, jobName, mode, opts)
+}
+
+// killPods deletes the pods of a suspended Job according to the kill mode
+func (j *jobManager) killPods(ctx context.Context, span trace.Span, job *batchv1.Job, mode KillMode, opts KillOptions) error {
+	switch mode {
+	case KillForce:
+		return j.deletePods(ctx, job.Namespace, job.Name, pointer.Duration(0), podDeletionTimeout)
+	case KillEscalate:
+		err := j.deletePods(ctx, job.Namespace, job.Name, opts.GracePeriod, opts.escalateAfter())
+		if !errors.IsTimeout(err) || ctx.Err() != nil {
+			return err
+		}
+		logging.FromContext(ctx).Warn("pods still running, forcing the kill", "job", job.Name, "escalate_after", opts.escalateAfter().String())
+		span.SetAttributes(attribute.Bool("kja.kill.escalated", true))
+		return j.deletePods(ctx, job.Namespace, job.Name, pointer.Duration(0), podDeletionTimeout)
+	default:
+		return j.deletePods(ctx, job.Namespace, job.Name, opts.GracePeriod, opts.gracePeriod(job)+podDeletionTimeout)
+	}
+}
+
+// killIndexRetries suspends the unfinished Jobs re-running the failed indexes of a killed Job (see
+// RetryFailedIndexes) and deletes their pods, which would otherwise keep running.
+func (j *jobManager) killIndexRetries(ctx context.Context, span trace.Span, namespace, jobName string, mode KillMode, opts KillOptions) error {
+	var list *batchv1.JobList
+	err := kubeCall(ctx, "list", "jobs", namespace, jobName, func(ctx context.Context) (err error) {
+		list, err = j.kubeClient.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", j.AnnotationKey(RetryOfLabel), jobName),
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	patch := []byte(`{"spec":{"suspend":true}}`)
+	for _, retry := range list.Items {
+		if hasCondition(&retry, batchv1.JobComplete) || hasCondition(&retry, batchv1.JobFailed) {
+			continue
+		}
+		var suspended *batchv1.Job
+		err := kubeCall(ctx, "patch", "jobs", namespace, retry.Name, func(ctx context.Context) (err error) {
+			suspended, err = j.kubeClient.BatchV1().Jobs(namespace).Patch(ctx, retry.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+			return err
+		})
+		if errors.IsNotFound(err) {
+			continue // deleted meanwhile
+		}
+		if err != nil {
+			return err
+		}
+		logging.FromContext(ctx).Info("killing index retry", "retry_job", retry.Name)
+		if err := j.killPods(ctx, span, suspended, mode, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deletePods deletes the pods of a Job with a grace period (the one of the pods when nil), and
+// waits for them to disappear. It fails with a Timeout error when they are still there after wait.
+func (j *jobManager) deletePods(ctx context.Context, namespace, jobName string, gracePeriod *time.Duration, wait time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	options := metav1.DeleteOptions{}
+	if gracePeriod != nil {
+		options.GracePeriodSeconds = pointer.Int64(int64(gracePeriod.Seconds()))
+	}
+	err := kubeCall(ctx, "deletecollection", "pods", namespace, jobName, func(ctx context.Context) error {
+		return j.kubeClient.CoreV1().Pods(namespace).DeleteCollection(ctx, options, metav1.ListOptions{
 			LabelSelector: fmt.Sprintf("job-name=%s", jobName),
-		},
-	)
+		})
+	})
 	if err != nil {
 		return err
 	}
+	logging.FromContext(ctx).Info("pods deletion requested", "grace_period_seconds", options.GracePeriodSeconds, "wait", wait.String())
 
 	// wait for actual pods deletion
-	for {
-		pods, getPodErr := j.kubeClient.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
-			LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+	if err := waitForPodsDeletion(ctx, j.kubeClient, namespace, jobName); err != nil {
+		if ctx.Err() != nil {
+			return errors.NewTimeoutError(fmt.Sprintf("pods of job %s/%s still running after %s", namespace, jobName, wait), 0)
+		}
+		return err
+	}
+	return nil
+}
+
+func waitForPodsDeletion(ctx context.Context, kubeClient kubernetes.Interface, namespace, jobName string) (err error) {
+	ctx, span := startSpan(ctx, "JobManager.waitForPodsDeletion", namespace, jobName)
+	defer func() { endSpan(span, err) }()
+
+	for polls := 1; ; polls++ {
+		var pods *corev1.PodList
+		getPodErr := kubeCall(ctx, "list", "pods", namespace, jobName, func(ctx context.Context) (err error) {
+			pods, err = kubeClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+				LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+			})
+			return err
 		})
 		if getPodErr != nil {
 			return getPodErr
 		}
 		if len(pods.Items) == 0 {
+			span.SetAttributes(attribute.Int("kja.polls", polls))
+			logging.FromContext(ctx).Debug("job's pods deleted", "polls", polls)
 			break
 		}
 		if ctx.Err() != nil {
@@ -137,27 +443,50 @@ func (j *jobManager) Kill(namespace, jobName string) error {
 	return nil
 }
 
-func deleteJobAndWaitForDeletion(kubeClient *kubernetes.Clientset, namespace, jobName string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second) //TODO configure deletion timeout
+func deleteJobAndWaitForDeletion(ctx context.Context, kubeClient kubernetes.Interface, namespace, jobName string) error {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second) //TODO configure deletion timeout
 	defer cancel()
 
 	policy := metav1.DeletePropagationForeground
-	err := kubeClient.BatchV1().Jobs(namespace).Delete(ctx, jobName, metav1.DeleteOptions{
-		PropagationPolicy: &policy,
+	err := kubeCall(ctx, "delete", "jobs", namespace, jobName, func(ctx context.Context) error {
+		return kubeClient.BatchV1().Jobs(namespace).Delete(ctx, jobName, metav1.DeleteOptions{
+			PropagationPolicy: &policy,
+		})
 	})
 	if err != nil {
 		return err
 	}
 
 	// wait for actual full deletion
-	for {
-		_, err := kubeClient.BatchV1().Jobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
-		if errors.IsNotFound(err) {
+	return waitForJobDeletion(ctx, kubeClient, namespace, jobName)
+}
+
+func waitForJobDeletion(ctx context.Context, kubeClient kubernetes.Interface, namespace, jobName string) (err error) {
+	ctx, span := startSpan(ctx, "JobManager.waitForJobDeletion", namespace, jobName)
+	defer func() { endSpan(span, err) }()
+
+	for polls := 1; ; polls++ {
+		deleted := false
+		err := kubeCall(ctx, "get", "jobs", namespace, jobName, func(ctx context.Context) error {
+			_, err := kubeClient.BatchV1().Jobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				deleted = true
+				return nil // expected outcome, not an API error
+			}
+			return err
+		})
+		if deleted {
+			span.SetAttributes(attribute.Int("kja.polls", polls))
+			logging.FromContext(ctx).Debug("job deleted", "polls", polls)
 			break
 		}
 		if ctx.Err() != nil {
 			return fmt.Errorf("timed out waiting for job deletion: %v", ctx.Err())
 		}
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to get job while waiting for its deletion", "error", err)
+			trace.SpanFromContext(ctx).AddEvent("get job failed while waiting for deletion", trace.WithAttributes(attribute.String("error", err.Error())))
+		}
 		time.Sleep(200 * time.Millisecond) // polling interval
 	}
 
@@ -178,6 +507,15 @@ func isJobRunning(status batchv1.JobStatus) bool {
 	return true // Started and not complete/failed yet
 }
 
+func hasCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == conditionType && cond.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
 // cleanJobForRecreate returns a clean copy of the given Job, ready for recreation.
 func cleanJobForRecreate(original *batchv1.Job) *batchv1.Job {
 	job := original.DeepCopy()
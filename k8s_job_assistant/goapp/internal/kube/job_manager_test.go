@@ -151,7 +151,7 @@ func (s *KubeServiceIntegrationTestSuite) TestListJob() {
 	_, err := s.kubeClient.BatchV1().Jobs("default").Create(context.Background(), job1, metav1.CreateOptions{})
 	s.Require().NoError(err, "failed to create job")
 
-	jobs, err := s.jobMgr.List()
+	jobs, err := s.jobMgr.List(context.Background())
 	s.Require().NoError(err)
 
 	s.Assert().Len(jobs, 2)
@@ -168,7 +168,7 @@ func (s *KubeServiceIntegrationTestSuite) TestListJob() {
 }
 
 func (s *KubeServiceIntegrationTestSuite) TestListJobEmpty() {
-	jobs, err := s.jobMgr.List()
+	jobs, err := s.jobMgr.List(context.Background())
 	s.Require().NoError(err)
 
 	s.Assert().Len(jobs, 0)
@@ -181,7 +181,7 @@ func (s *KubeServiceIntegrationTestSuite) TestListJobStatusSuspended() {
 	job1, jobName := s.validJob("correct-job-suspended", s.TestLabels, 0)
 	s.createJob(job1, false)
 
-	jobs, err := s.jobMgr.List()
+	jobs, err := s.jobMgr.List(context.Background())
 	s.Require().NoError(err)
 
 	s.Assert().Len(jobs, 1)
@@ -196,7 +196,7 @@ func (s *KubeServiceIntegrationTestSuite) TestListJobStatusSuspended() {
 }
 
 func (s *KubeServiceIntegrationTestSuite) TestRunJobNonExisting() {
-	err := s.jobMgr.Run(s.Namespace, "non-existing")
+	err := s.jobMgr.Run(context.Background(), s.Namespace, "non-existing", RunOptions{})
 	s.Require().Error(err)
 	s.Assert().Contains(err.Error(), "jobs.batch")
 	s.Assert().Contains(err.Error(), "not found")
@@ -210,12 +210,12 @@ func (s *KubeServiceIntegrationTestSuite) TestRunJobNonExisting() {
 	_, err = s.kubeClient.BatchV1().Jobs("default").Create(context.Background(), validButUnschedulableJob, metav1.CreateOptions{})
 	s.Require().NoError(err, "failed to create job")
 
-	err := s.jobMgr.Run("default", s.BaseJobName)
+	err := s.jobMgr.Run(context.Background(), "default", s.BaseJobName, RunOptions{})
 	s.Require().NoError(err)
 
 	//this test only care that the Job scheduled at least one pod
 	s.Require().Eventually(func() bool {
-		err, jobStatus := s.jobMgr.Status(s.Namespace, s.BaseJobName)
+		err, jobStatus := s.jobMgr.Status(context.Background(), s.Namespace, s.BaseJobName)
 		s.Require().NoError(err)
 
 		if jobStatus.StartTime != nil {
@@ -231,7 +231,7 @@ func (s *KubeServiceIntegrationTestSuite) TestRunJobAfterCreate() {
 	job1, jobName := s.validJob("correct-job-run", s.TestLabels, 0)
 	s.createJob(job1, true)
 
-	err := s.jobMgr.Run(s.Namespace, jobName)
+	err := s.jobMgr.Run(context.Background(), s.Namespace, jobName, RunOptions{})
 	s.Require().NoError(err)
 
 	s.assertJobStarted(jobName)
@@ -274,7 +274,7 @@ func (s *KubeServiceIntegrationTestSuite) TestRunJobWithoutSuspend() {
 	//before running the actual test
 	s.waitForJobCompletion(s.Namespace, jobName, 20)
 
-	err := s.jobMgr.Run(s.Namespace, jobName)
+	err := s.jobMgr.Run(context.Background(), s.Namespace, jobName, RunOptions{})
 	s.Require().NoError(err)
 
 	s.assertJobStarted(jobName)
@@ -286,7 +286,7 @@ func (s *KubeServiceIntegrationTestSuite) TestRunJobAfterCompletion() {
 	s.createJob(job, true)
 
 	s.T().Logf("Run first time")
-	err := s.jobMgr.Run(s.Namespace, jobName)
+	err := s.jobMgr.Run(context.Background(), s.Namespace, jobName, RunOptions{})
 	s.Require().NoError(err)
 	s.assertJobStarted(jobName)
 	s.T().Logf("First run has started")
@@ -295,10 +295,14 @@ func (s *KubeServiceIntegrationTestSuite) TestRunJobAfterCompletion() {
 	s.T().Logf("First run has completed")
 
 	s.T().Logf("Run second time")
-	err = s.jobMgr.Run(s.Namespace, jobName)
+	err = s.jobMgr.Run(context.Background(), s.Namespace, jobName, RunOptions{})
 	s.Require().NoError(err)
 	s.assertJobStarted(jobName)
-	s.T().Logf("Second run has started, test is over")
+	s.T().Logf("Second run has started")
+
+	job, err = s.jobMgr.Get(context.Background(), s.Namespace, jobName)
+	s.Require().NoError(err)
+	s.NotEmpty(job.Annotations[s.jobMgr.AnnotationKey(LastSuccessCompletionTimeAnnotation)], "the first run is kept as the last success")
 
 }
 
@@ -307,13 +311,13 @@ func (s *KubeServiceIntegrationTestSuite) TestRunJobWhileRunning() {
 	s.createJob(job, true)
 
 	s.T().Logf("Run first time")
-	err := s.jobMgr.Run(s.Namespace, jobName)
+	err := s.jobMgr.Run(context.Background(), s.Namespace, jobName, RunOptions{})
 	s.Require().NoError(err)
 	s.assertJobStarted(jobName)
 	s.T().Logf("First run has started")
 
 	s.T().Logf("Run second time (without waiting for first completion")
-	err = s.jobMgr.Run(s.Namespace, jobName)
+	err = s.jobMgr.Run(context.Background(), s.Namespace, jobName, RunOptions{})
 	s.Require().Error(err, &JobAlreadyRunningError{})
 }
 
@@ -321,12 +325,12 @@ func (s *KubeServiceIntegrationTestSuite) TestKillJob() {
 	job, jobName := s.validJob("suspend-there-run-to-kill", s.TestLabels, 15)
 	s.createJob(job, true)
 
-	err := s.jobMgr.Run(s.Namespace, jobName)
+	err := s.jobMgr.Run(context.Background(), s.Namespace, jobName, RunOptions{})
 	s.Require().NoError(err)
 	s.assertJobStarted(jobName)
 	s.T().Logf("Run has started")
 
-	err = s.jobMgr.Kill(s.Namespace, jobName)
+	err = s.jobMgr.Kill(context.Background(), s.Namespace, jobName, KillOptions{})
 	s.Require().NoError(err)
 
 	job, err = s.kubeClient.BatchV1().Jobs(s.Namespace).Get(context.Background(), jobName, metav1.GetOptions{})
@@ -340,15 +344,15 @@ func (s *KubeServiceIntegrationTestSuite) TestRunAfterKillJob() {
 	job, jobName := s.validJob("suspend-there-run-after-kill", s.TestLabels, 15)
 	s.createJob(job, true)
 
-	err := s.jobMgr.Run(s.Namespace, jobName)
+	err := s.jobMgr.Run(context.Background(), s.Namespace, jobName, RunOptions{})
 	s.Require().NoError(err)
 	s.assertJobStarted(jobName)
 	s.T().Logf("Run has started")
 
-	err = s.jobMgr.Kill(s.Namespace, jobName)
+	err = s.jobMgr.Kill(context.Background(), s.Namespace, jobName, KillOptions{})
 	s.Require().NoError(err)
 
-	err = s.jobMgr.Run(s.Namespace, jobName)
+	err = s.jobMgr.Run(context.Background(), s.Namespace, jobName, RunOptions{})
 	s.Require().NoError(err)
 	s.assertJobStarted(jobName)
 	s.T().Logf("Run has started")
@@ -356,7 +360,7 @@ func (s *KubeServiceIntegrationTestSuite) TestRunAfterKillJob() {
 }
 
 func (s *KubeServiceIntegrationTestSuite) TestKillJobNonExisting() {
-	err := s.jobMgr.Kill(s.Namespace, "non-existing")
+	err := s.jobMgr.Kill(context.Background(), s.Namespace, "non-existing", KillOptions{})
 	s.Require().Error(err)
 	s.Assert().Contains(err.Error(), "jobs.batch")
 	s.Assert().Contains(err.Error(), "not found")
@@ -371,7 +375,7 @@ func TestKubeService(t *testing.T) {
 func (s *KubeServiceIntegrationTestSuite) assertJobStarted(jobName string) {
 	// this test only care that the Job scheduled at least one pod
 	s.Require().Eventually(func() bool {
-		err, jobStatus := s.jobMgr.Status(s.Namespace, jobName)
+		err, jobStatus := s.jobMgr.Status(context.Background(), s.Namespace, jobName)
 		s.Require().NoError(err)
 
 		if jobStatus.StartTime != nil {
@@ -391,7 +395,7 @@ func (s *KubeServiceIntegrationTestSuite) waitForJobCompletion(namespace string,
 		case <-timeout:
 			s.FailNow("timed out waiting for Job to complete")
 		case <-tick:
-			err, jobStatus := s.jobMgr.Status(namespace, jobName)
+			err, jobStatus := s.jobMgr.Status(context.Background(), namespace, jobName)
 			s.Require().NoError(err)
 
 			for _, condition := range jobStatus.Conditions {
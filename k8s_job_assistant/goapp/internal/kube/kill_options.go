@@ -0,0 +1,75 @@
+package kube
+
+import (
+	"fmt"
+	batchv1 "k8s.io/api/batch/v1"
+	"time"
+)
+
+// KillMode tells how the pods of a killed run are deleted
+type KillMode string
+
+const (
+	// KillGraceful lets the pods terminate within their grace period: terminationGracePeriodSeconds
+	// of the pod template, or KillOptions.GracePeriod
+	KillGraceful KillMode = "graceful"
+	// KillForce deletes the pods right away (grace period 0)
+	KillForce KillMode = "force"
+	// KillEscalate kills gracefully, then forces the kill of the pods still there after KillOptions.EscalateAfter
+	KillEscalate KillMode = "escalate"
+)
+
+const (
+	// defaultEscalateAfter is how long KillEscalate waits before forcing the kill when not given
+	defaultEscalateAfter = 30 * time.Second
+	// defaultGracePeriod is the grace period of pods not setting terminationGracePeriodSeconds
+	defaultGracePeriod = 30 * time.Second
+	// podDeletionTimeout is how long the pods can take to disappear once their grace period is over
+	podDeletionTimeout = 20 * time.Second
+)
+
+// KillOptions customizes how a run is killed.
+type KillOptions struct {
+	// Mode defaults to KillGraceful
+	Mode KillMode
+	// GracePeriod overrides the terminationGracePeriodSeconds of the pods, for KillGraceful and KillEscalate
+	GracePeriod *time.Duration
+	// EscalateAfter is how long KillEscalate waits for the pods to terminate before forcing it, defaults to 30s
+	EscalateAfter time.Duration
+	// Reason is recorded on the Job (KillReasonAnnotation) and shown in its status
+	Reason string
+}
+
+func (o KillOptions) validate() error {
+	switch o.Mode {
+	case "", KillGraceful, KillForce, KillEscalate:
+	default:
+		return &InvalidKillOptionsError{Reason: fmt.Sprintf("unknown mode %q, expected %s, %s or %s", o.Mode, KillGraceful, KillForce, KillEscalate)}
+	}
+	if o.GracePeriod != nil && *o.GracePeriod < 0 {
+		return &InvalidKillOptionsError{Reason: "the grace period can not be negative"}
+	}
+	if o.EscalateAfter < 0 {
+		return &InvalidKillOptionsError{Reason: "the escalation delay can not be negative"}
+	}
+	return nil
+}
+
+// gracePeriod returns the grace period the pods of the job get when killed gracefully
+func (o KillOptions) gracePeriod(job *batchv1.Job) time.Duration {
+	switch {
+	case o.GracePeriod != nil:
+		return *o.GracePeriod
+	case job.Spec.Template.Spec.TerminationGracePeriodSeconds != nil:
+		return time.Duration(*job.Spec.Template.Spec.TerminationGracePeriodSeconds) * time.Second
+	default:
+		return defaultGracePeriod
+	}
+}
+
+func (o KillOptions) escalateAfter() time.Duration {
+	if o.EscalateAfter == 0 {
+		return defaultEscalateAfter
+	}
+	return o.EscalateAfter
+}
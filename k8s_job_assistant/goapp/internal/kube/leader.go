@@ -0,0 +1,51 @@
+package kube
+
+import (
+	"context"
+	"goapp/internal/logging"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"time"
+)
+
+// RunLeaderElection competes for the Lease namespace/name with the other KJA replicas, and calls
+// onLeading each time this replica (identity) becomes the leader, with a context cancelled once
+// the leadership is lost. It returns when ctx is done, releasing the Lease.
+func RunLeaderElection(ctx context.Context, kubeClient *kubernetes.Clientset, namespace, name, identity string, onLeading func(ctx context.Context)) error {
+	logger := logging.FromContext(ctx).With("lease", namespace+"/"+name, "identity", identity)
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Client:     kubeClient.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				logger.Info("leadership acquired")
+				onLeading(logging.WithLogger(ctx, logger))
+			},
+			OnStoppedLeading: func() {
+				logger.Info("leadership lost")
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					logger.Info("following leader", "leader", leader)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	// Run returns each time the leadership is lost, compete again until ctx is done
+	for ctx.Err() == nil {
+		elector.Run(ctx)
+	}
+	return nil
+}
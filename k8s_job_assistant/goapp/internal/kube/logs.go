@@ -0,0 +1,81 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"slices"
+	"sort"
+)
+
+// LogOptions selects which logs of a Job to stream.
+type LogOptions struct {
+	// Container to stream the logs of, defaults to the first container of the pod
+	Container string
+	// Follow keeps streaming while the container is running
+	Follow bool
+	// TailLines only returns the last lines when set
+	TailLines *int64
+	// Pod to stream the logs of, defaults to the most recent pod of the Job
+	Pod string
+	// LimitBytes stops the stream after this many bytes when set
+	LimitBytes *int64
+	// SinceTime only returns the lines logged from this time when set
+	SinceTime *metav1.Time
+	// Timestamps starts each line with the RFC3339 time it was logged at
+	Timestamps bool
+}
+
+// Logs streams the logs of a pod of the Job, the most recent one unless opts.Pod is set. The
+// caller must close the returned stream.
+func (j *jobManager) Logs(ctx context.Context, namespace, jobName string, opts LogOptions) (io.ReadCloser, error) {
+	ctx, span := startSpan(ctx, "JobManager.Logs", namespace, jobName)
+	defer span.End()
+
+	pods, err := j.Pods(ctx, namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Pod != "" {
+		pods = slices.DeleteFunc(pods, func(pod corev1.Pod) bool { return pod.Name != opts.Pod })
+	}
+	if len(pods) == 0 {
+		return nil, &NoPodError{Namespace: namespace, JobName: jobName}
+	}
+	pod := pods[len(pods)-1]
+
+	var stream io.ReadCloser
+	err = kubeCall(ctx, "get", "pods/log", namespace, pod.Name, func(ctx context.Context) (err error) {
+		stream, err = j.kubeClient.CoreV1().Pods(namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+			Container:  opts.Container,
+			Follow:     opts.Follow,
+			TailLines:  opts.TailLines,
+			LimitBytes: opts.LimitBytes,
+			SinceTime:  opts.SinceTime,
+			Timestamps: opts.Timestamps,
+		}).Stream(ctx)
+		return err
+	})
+	return stream, err
+}
+
+// Pods returns the pods of the Job, oldest first.
+func (j *jobManager) Pods(ctx context.Context, namespace, jobName string) ([]corev1.Pod, error) {
+	var pods *corev1.PodList
+	err := kubeCall(ctx, "list", "pods", namespace, jobName, func(ctx context.Context) (err error) {
+		pods, err = j.kubeClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(pods.Items, func(a, b int) bool {
+		return pods.Items[a].CreationTimestamp.Before(&pods.Items[b].CreationTimestamp)
+	})
+	return pods.Items, nil
+}
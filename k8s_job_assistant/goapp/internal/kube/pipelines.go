@@ -0,0 +1,59 @@
+package kube
+
+import (
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"time"
+)
+
+// PipelineDefinitionKey is the key of the pipeline ConfigMaps holding the pipeline definition (YAML)
+const PipelineDefinitionKey = "pipeline.yaml"
+
+// PipelineManager reads the pipelines defined by ConfigMaps labelled '<jobAssistAnnotation>/pipeline: enable'.
+type PipelineManager interface {
+	List(ctx context.Context) ([]corev1.ConfigMap, error)
+	// Get returns a pipeline ConfigMap, ConfigMaps not defining a pipeline are reported as not found.
+	Get(ctx context.Context, namespace, name string) (*corev1.ConfigMap, error)
+}
+
+type pipelineManager struct {
+	kubeClient *kubernetes.Clientset
+	label      string
+}
+
+func NewPipelineManager(kubeClient *kubernetes.Clientset, jobAssistAnnotation string) PipelineManager {
+	return &pipelineManager{kubeClient: kubeClient, label: jobAssistAnnotation + "/pipeline"}
+}
+
+func (p *pipelineManager) List(ctx context.Context) ([]corev1.ConfigMap, error) {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	var configMaps *corev1.ConfigMapList
+	err := kubeCall(ctx, "list", "configmaps", "", "", func(ctx context.Context) (err error) {
+		configMaps, err = p.kubeClient.CoreV1().ConfigMaps("").List(ctx, metav1.ListOptions{LabelSelector: p.label + "=enable"})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configMaps.Items, nil
+}
+
+func (p *pipelineManager) Get(ctx context.Context, namespace, name string) (*corev1.ConfigMap, error) {
+	var configMap *corev1.ConfigMap
+	err := kubeCall(ctx, "get", "configmaps", namespace, name, func(ctx context.Context) (err error) {
+		configMap, err = p.kubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if configMap.Labels[p.label] != "enable" {
+		return nil, errors.NewNotFound(corev1.Resource("pipelines"), name)
+	}
+	return configMap, nil
+}
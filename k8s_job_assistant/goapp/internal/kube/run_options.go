@@ -0,0 +1,161 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"goapp/pkg/model"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// RunOptions customizes a single run of a Job.
+type RunOptions struct {
+	// Params are set as environment variables of every container of the run, init containers
+	// included, their names must be listed by the ParamsAnnotation of the Job
+	Params map[string]string
+	// Overrides replaces fields of the Job for this run, they must be allowed by its
+	// OverridesAnnotation and OverrideUsersAnnotation
+	Overrides *model.RunOverrides
+	// KeepOverrides keeps the overrides of the previous run instead of reverting them, Overrides is ignored
+	KeepOverrides bool
+	// NoQueue fails with JobAlreadyRunningError instead of queueing the run when the Job is
+	// running, for callers following the run they start
+	NoQueue bool
+	// BeforeRecreate is called with the Job as its last run left it when the Job is about to be
+	// deleted to be re-created for the run, ie: to archive the logs of the pods deleted with it
+	BeforeRecreate func(ctx context.Context, job *batchv1.Job)
+}
+
+// applyRunOptions customizes job for the new run of user, empty when their identity can not be
+// trusted (see logging.TrustedUserFromContext), and reverts the customization of the
+// previous run. It tells whether the Job spec changed, a suspended Job must then be re-created as
+// Kubernetes does not allow to update the pod template of a Job.
+func (j *jobManager) applyRunOptions(job *batchv1.Job, opts RunOptions, user string) (templateChanged bool, err error) {
+	if err := j.CheckParams(job, opts.Params); err != nil {
+		return false, err
+	}
+	overridden := false
+	if !opts.KeepOverrides {
+		if overridden, err = j.applyOverrides(job, opts.Overrides, user); err != nil {
+			return false, err
+		}
+	}
+
+	appliedAnnotation := j.AnnotationKey(RunParamsAnnotation)
+	revertAnnotation := j.AnnotationKey(RunParamsRevertAnnotation)
+	previous := map[string]string{}
+	if applied := job.Annotations[appliedAnnotation]; applied != "" {
+		if err := json.Unmarshal([]byte(applied), &previous); err != nil {
+			return false, fmt.Errorf("invalid %s annotation: %w", appliedAnnotation, err)
+		}
+	}
+	if len(previous) == 0 && len(opts.Params) == 0 {
+		return overridden, nil
+	}
+	original := map[string][]corev1.EnvVar{}
+	if replaced := job.Annotations[revertAnnotation]; replaced != "" {
+		if err := json.Unmarshal([]byte(replaced), &original); err != nil {
+			return false, fmt.Errorf("invalid %s annotation: %w", revertAnnotation, err)
+		}
+	}
+
+	replaced := map[string][]corev1.EnvVar{}
+	podSpec := &job.Spec.Template.Spec
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			env := revertParams(containers[i].Env, previous, original[containers[i].Name])
+			containers[i].Env, replaced[containers[i].Name] = setParams(env, opts.Params)
+			if len(replaced[containers[i].Name]) == 0 {
+				delete(replaced, containers[i].Name)
+			}
+		}
+	}
+
+	delete(job.Annotations, appliedAnnotation)
+	delete(job.Annotations, revertAnnotation)
+	if len(opts.Params) > 0 {
+		applied, err := json.Marshal(opts.Params)
+		if err != nil {
+			return false, err
+		}
+		job.Annotations[appliedAnnotation] = string(applied)
+	}
+	if len(replaced) > 0 {
+		values, err := json.Marshal(replaced)
+		if err != nil {
+			return false, err
+		}
+		job.Annotations[revertAnnotation] = string(values)
+	}
+	return true, nil
+}
+
+// revertParams removes the params of the previous run from the environment variables of a
+// container, restoring the ones they replaced in place
+func revertParams(env []corev1.EnvVar, previous map[string]string, original []corev1.EnvVar) []corev1.EnvVar {
+	reverted := env[:0]
+	for _, envVar := range env {
+		if _, ok := previous[envVar.Name]; !ok {
+			reverted = append(reverted, envVar)
+		} else if i := slices.IndexFunc(original, func(o corev1.EnvVar) bool { return o.Name == envVar.Name }); i >= 0 {
+			reverted = append(reverted, original[i])
+		}
+	}
+	return reverted
+}
+
+// setParams sets params as environment variables of a container, in place of the variables of
+// the same name, and returns the variables replaced
+func setParams(env []corev1.EnvVar, params map[string]string) (_ []corev1.EnvVar, replaced []corev1.EnvVar) {
+	set := map[string]bool{}
+	for i, envVar := range env {
+		if value, ok := params[envVar.Name]; ok && !set[envVar.Name] {
+			replaced = append(replaced, envVar)
+			env[i] = corev1.EnvVar{Name: envVar.Name, Value: value}
+			set[envVar.Name] = true
+		}
+	}
+	for _, name := range sortedKeys(params) {
+		if !set[name] {
+			env = append(env, corev1.EnvVar{Name: name, Value: params[name]})
+		}
+	}
+	return env, replaced
+}
+
+// CheckParams fails if a param is not allowed by the ParamsAnnotation of the Job
+func (j *jobManager) CheckParams(job *batchv1.Job, params map[string]string) error {
+	if len(params) == 0 {
+		return nil
+	}
+	allowed := map[string]bool{}
+	for _, name := range strings.Split(job.Annotations[j.AnnotationKey(ParamsAnnotation)], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed[name] = true
+		}
+	}
+	var rejected []string
+	for _, name := range sortedKeys(params) {
+		if !allowed[name] {
+			rejected = append(rejected, name)
+		}
+	}
+	if len(rejected) > 0 {
+		return &InvalidRunOptionsError{Reason: fmt.Sprintf("params %s are not allowed by the %s annotation",
+			strings.Join(rejected, ", "), j.AnnotationKey(ParamsAnnotation))}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
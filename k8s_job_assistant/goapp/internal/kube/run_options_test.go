@@ -0,0 +1,59 @@
+package kube
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func paramsJob() *batchv1.Job {
+	dateFromConfig := corev1.EnvVar{Name: "DATE", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "billing"}, Key: "date",
+	}}}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Annotations: map[string]string{
+			"job-assistant/params": "DATE, REGION",
+		}},
+		Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "fetch", Env: []corev1.EnvVar{dateFromConfig}}},
+			Containers: []corev1.Container{{Name: "export", Env: []corev1.EnvVar{
+				{Name: "LOG_LEVEL", Value: "info"}, dateFromConfig, {Name: "BUCKET", Value: "exports"},
+			}}},
+		}}},
+	}
+}
+
+func TestApplyRunParams(t *testing.T) {
+	j := &jobManager{jobAssistAnnotation: "job-assistant"}
+	job := paramsJob()
+
+	changed, err := j.applyRunOptions(job, RunOptions{Params: map[string]string{"DATE": "2025-06-02", "REGION": "eu"}}, "jane.doe")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []corev1.EnvVar{{Name: "DATE", Value: "2025-06-02"}, {Name: "REGION", Value: "eu"}},
+		job.Spec.Template.Spec.InitContainers[0].Env, "init containers get the params too")
+	assert.Equal(t, []corev1.EnvVar{
+		{Name: "LOG_LEVEL", Value: "info"}, {Name: "DATE", Value: "2025-06-02"}, {Name: "BUCKET", Value: "exports"}, {Name: "REGION", Value: "eu"},
+	}, job.Spec.Template.Spec.Containers[0].Env, "replaced in place")
+	assert.JSONEq(t, `{"DATE":"2025-06-02","REGION":"eu"}`, job.Annotations["job-assistant/run-params"])
+	assert.Contains(t, job.Annotations["job-assistant/run-params-revert"], `"configMapKeyRef"`)
+
+	changed, err = j.applyRunOptions(job, RunOptions{Params: map[string]string{"REGION": "us"}}, "jane.doe")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, paramsJob().Spec.Template.Spec.InitContainers[0].Env[0], job.Spec.Template.Spec.InitContainers[0].Env[0],
+		"the variable replaced by the previous run is restored")
+
+	_, err = j.applyRunOptions(job, RunOptions{}, "jane.doe")
+	require.NoError(t, err)
+	assert.Equal(t, paramsJob().Spec, job.Spec)
+	assert.NotContains(t, job.Annotations, "job-assistant/run-params")
+	assert.NotContains(t, job.Annotations, "job-assistant/run-params-revert")
+
+	_, err = j.applyRunOptions(job, RunOptions{Params: map[string]string{"BUCKET": "tmp"}}, "jane.doe")
+	var invalid *InvalidRunOptionsError
+	assert.ErrorAs(t, err, &invalid, "BUCKET is not a param")
+}
@@ -0,0 +1,277 @@
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"goapp/pkg/model"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Fields of a Job a run can override (see model.RunOverrides), listed by the OverridesAnnotation
+const (
+	OverrideImageTag     = "image-tag"
+	OverrideArgs         = "args"
+	OverrideResources    = "resources"
+	OverrideParallelism  = "parallelism"
+	OverrideCompletions  = "completions"
+	OverrideNodeSelector = "node-selector"
+)
+
+// imageTagPattern matches image tags and digests, as defined by the OCI distribution spec
+var imageTagPattern = regexp.MustCompile(`^([A-Za-z0-9_][A-Za-z0-9_.-]{0,127}|sha256:[a-f0-9]{64})$`)
+
+// overriddenValues holds the values the overrides of a run replaced, stored in the
+// RunOverridesRevertAnnotation for the next run to restore them
+type overriddenValues struct {
+	Container    string                       `json:"container,omitempty"`
+	Image        string                       `json:"image,omitempty"`
+	Args         []string                     `json:"args,omitempty"`
+	Resources    *corev1.ResourceRequirements `json:"resources,omitempty"`
+	Parallelism  *int32                       `json:"parallelism,omitempty"`
+	Completions  *int32                       `json:"completions,omitempty"`
+	NodeSelector map[string]string            `json:"nodeSelector,omitempty"`
+}
+
+// overriddenFields lists the fields set by overrides
+func overriddenFields(overrides *model.RunOverrides) []string {
+	if overrides == nil {
+		return nil
+	}
+	var fields []string
+	if overrides.ImageTag != "" {
+		fields = append(fields, OverrideImageTag)
+	}
+	if len(overrides.Args) > 0 {
+		fields = append(fields, OverrideArgs)
+	}
+	if len(overrides.Requests) > 0 || len(overrides.Limits) > 0 {
+		fields = append(fields, OverrideResources)
+	}
+	if overrides.Parallelism != nil {
+		fields = append(fields, OverrideParallelism)
+	}
+	if overrides.Completions != nil {
+		fields = append(fields, OverrideCompletions)
+	}
+	if len(overrides.NodeSelector) > 0 {
+		fields = append(fields, OverrideNodeSelector)
+	}
+	return fields
+}
+
+// applyOverrides reverts the overrides of the previous run, then applies the ones of the new run
+// on behalf of user. It tells whether the Job changed.
+func (j *jobManager) applyOverrides(job *batchv1.Job, overrides *model.RunOverrides, user string) (changed bool, err error) {
+	fields := overriddenFields(overrides)
+	if len(fields) > 0 {
+		if err := j.checkOverrides(job, overrides, fields, user); err != nil {
+			return false, err
+		}
+	}
+
+	changed, err = j.revertOverrides(job)
+	if err != nil || len(fields) == 0 {
+		return changed, err
+	}
+
+	container := &job.Spec.Template.Spec.Containers[containerIndex(job, overrides.Container)]
+	original := overriddenValues{Container: container.Name}
+	for _, field := range fields {
+		switch field {
+		case OverrideImageTag:
+			original.Image = container.Image
+			container.Image = replaceImageTag(container.Image, overrides.ImageTag)
+		case OverrideArgs:
+			original.Args = container.Args
+			container.Args = overrides.Args
+		case OverrideResources:
+			original.Resources = container.Resources.DeepCopy()
+			container.Resources.Requests = overrideResources(container.Resources.Requests, overrides.Requests)
+			container.Resources.Limits = overrideResources(container.Resources.Limits, overrides.Limits)
+			if err := checkRequestsWithinLimits(container.Resources); err != nil {
+				return false, err
+			}
+		case OverrideParallelism:
+			original.Parallelism = job.Spec.Parallelism
+			job.Spec.Parallelism = overrides.Parallelism
+		case OverrideCompletions:
+			original.Completions = job.Spec.Completions
+			job.Spec.Completions = overrides.Completions
+		case OverrideNodeSelector:
+			original.NodeSelector = job.Spec.Template.Spec.NodeSelector
+			job.Spec.Template.Spec.NodeSelector = overrides.NodeSelector
+		}
+	}
+
+	applied, err := json.Marshal(overrides)
+	if err != nil {
+		return false, err
+	}
+	revert, err := json.Marshal(original)
+	if err != nil {
+		return false, err
+	}
+	job.Annotations[j.AnnotationKey(RunOverridesAnnotation)] = string(applied)
+	job.Annotations[j.AnnotationKey(RunOverridesRevertAnnotation)] = string(revert)
+	return true, nil
+}
+
+// checkOverrides fails if user may not override the Job, or if a field is not allowed by its
+// OverridesAnnotation or has an invalid value. A user whose identity can not be trusted is empty.
+func (j *jobManager) checkOverrides(job *batchv1.Job, overrides *model.RunOverrides, fields []string, user string) error {
+	if user == "" || !slices.Contains(splitList(job.Annotations[j.AnnotationKey(OverrideUsersAnnotation)]), user) {
+		return &OverrideForbiddenError{User: user, Annotation: j.AnnotationKey(OverrideUsersAnnotation)}
+	}
+	allowed := splitList(job.Annotations[j.AnnotationKey(OverridesAnnotation)])
+	var rejected []string
+	for _, field := range fields {
+		if !slices.Contains(allowed, field) {
+			rejected = append(rejected, field)
+		}
+	}
+	if len(rejected) > 0 {
+		return &InvalidRunOptionsError{Reason: fmt.Sprintf("overrides of %s are not allowed by the %s annotation",
+			strings.Join(rejected, ", "), j.AnnotationKey(OverridesAnnotation))}
+	}
+
+	if containerIndex(job, overrides.Container) < 0 {
+		return &InvalidRunOptionsError{Reason: fmt.Sprintf("the job has no container %q", overrides.Container)}
+	}
+	if overrides.ImageTag != "" && !imageTagPattern.MatchString(overrides.ImageTag) {
+		return &InvalidRunOptionsError{Reason: fmt.Sprintf("invalid image tag %q", overrides.ImageTag)}
+	}
+	for kind, resources := range map[string]map[string]string{"request": overrides.Requests, "limit": overrides.Limits} {
+		for name, value := range resources {
+			if name != string(corev1.ResourceCPU) && name != string(corev1.ResourceMemory) {
+				return &InvalidRunOptionsError{Reason: fmt.Sprintf("only cpu and memory can be overridden, not the %s %q", kind, name)}
+			}
+			if _, err := resource.ParseQuantity(value); err != nil {
+				return &InvalidRunOptionsError{Reason: fmt.Sprintf("invalid %s %s %q: %v", name, kind, value, err)}
+			}
+		}
+	}
+	for key, value := range overrides.NodeSelector {
+		if errs := append(validation.IsQualifiedName(key), validation.IsValidLabelValue(value)...); len(errs) > 0 {
+			return &InvalidRunOptionsError{Reason: fmt.Sprintf("invalid node selector %s=%s: %s", key, value, strings.Join(errs, ", "))}
+		}
+	}
+	if overrides.Parallelism != nil && *overrides.Parallelism < 0 {
+		return &InvalidRunOptionsError{Reason: "parallelism can not be negative"}
+	}
+	if overrides.Completions != nil && *overrides.Completions < 1 {
+		return &InvalidRunOptionsError{Reason: "completions must be at least 1"}
+	}
+	return nil
+}
+
+// revertOverrides restores the values replaced by the overrides of the previous run, and tells
+// whether there were any
+func (j *jobManager) revertOverrides(job *batchv1.Job) (bool, error) {
+	appliedAnnotation := j.AnnotationKey(RunOverridesAnnotation)
+	revertAnnotation := j.AnnotationKey(RunOverridesRevertAnnotation)
+	if job.Annotations[appliedAnnotation] == "" {
+		return false, nil
+	}
+	var applied model.RunOverrides
+	if err := json.Unmarshal([]byte(job.Annotations[appliedAnnotation]), &applied); err != nil {
+		return false, fmt.Errorf("invalid %s annotation: %w", appliedAnnotation, err)
+	}
+	var original overriddenValues
+	if err := json.Unmarshal([]byte(job.Annotations[revertAnnotation]), &original); err != nil {
+		return false, fmt.Errorf("invalid %s annotation: %w", revertAnnotation, err)
+	}
+
+	var container *corev1.Container
+	if i := containerIndex(job, original.Container); i >= 0 {
+		container = &job.Spec.Template.Spec.Containers[i]
+	}
+	for _, field := range overriddenFields(&applied) {
+		switch {
+		case container == nil && (field == OverrideImageTag || field == OverrideArgs || field == OverrideResources):
+			// the container is gone from the Job, nothing to revert
+		case field == OverrideImageTag:
+			container.Image = original.Image
+		case field == OverrideArgs:
+			container.Args = original.Args
+		case field == OverrideResources:
+			container.Resources = corev1.ResourceRequirements{}
+			if original.Resources != nil {
+				container.Resources = *original.Resources
+			}
+		case field == OverrideParallelism:
+			job.Spec.Parallelism = original.Parallelism
+		case field == OverrideCompletions:
+			job.Spec.Completions = original.Completions
+		case field == OverrideNodeSelector:
+			job.Spec.Template.Spec.NodeSelector = original.NodeSelector
+		}
+	}
+	delete(job.Annotations, appliedAnnotation)
+	delete(job.Annotations, revertAnnotation)
+	return true, nil
+}
+
+// containerIndex returns the index of the named container of the Job, the first one when name
+// is empty, or -1
+func containerIndex(job *batchv1.Job, name string) int {
+	containers := job.Spec.Template.Spec.Containers
+	if name == "" && len(containers) > 0 {
+		return 0
+	}
+	return slices.IndexFunc(containers, func(c corev1.Container) bool { return c.Name == name })
+}
+
+// replaceImageTag replaces the tag or digest of an image, ie: 'registry:5000/app:1.2' becomes 'registry:5000/app:1.3'
+func replaceImageTag(image, tag string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	if strings.HasPrefix(tag, "sha256:") {
+		return image + "@" + tag
+	}
+	return image + ":" + tag
+}
+
+// overrideResources returns a copy of resources with the overridden quantities, checked by checkOverrides
+func overrideResources(resources corev1.ResourceList, overrides map[string]string) corev1.ResourceList {
+	if len(overrides) == 0 {
+		return resources
+	}
+	result := resources.DeepCopy()
+	if result == nil {
+		result = corev1.ResourceList{}
+	}
+	for name, value := range overrides {
+		result[corev1.ResourceName(name)] = resource.MustParse(value)
+	}
+	return result
+}
+
+// checkRequestsWithinLimits fails if a request is above the limit of the same resource, which
+// Kubernetes rejects
+func checkRequestsWithinLimits(resources corev1.ResourceRequirements) error {
+	for name, request := range resources.Requests {
+		if limit, ok := resources.Limits[name]; ok && request.Cmp(limit) > 0 {
+			return &InvalidRunOptionsError{Reason: fmt.Sprintf("the %s request %s is above its limit %s", name, request.String(), limit.String())}
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma separated annotation, ignoring blanks
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,130 @@
+package kube
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/logging"
+	"goapp/pkg/model"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/pointer"
+	"slices"
+	"testing"
+)
+
+func overridableJob() *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Annotations: map[string]string{
+			"job-assistant/overrides":      "image-tag, resources, parallelism, node-selector",
+			"job-assistant/override-users": "jane.doe",
+		}},
+		Spec: batchv1.JobSpec{
+			Parallelism: pointer.Int32(1),
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:  "export",
+				Image: "registry.local:5000/billing/export:1.4.2",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi"), corev1.ResourceCPU: resource.MustParse("500m")},
+				},
+			}}}},
+		},
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	j := &jobManager{jobAssistAnnotation: "job-assistant"}
+	job := overridableJob()
+
+	changed, err := j.applyOverrides(job, &model.RunOverrides{
+		ImageTag:    "1.4.3-debug",
+		Requests:    map[string]string{"memory": "4Gi"},
+		Parallelism: pointer.Int32(4),
+	}, "jane.doe")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	container := job.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "registry.local:5000/billing/export:1.4.3-debug", container.Image)
+	assert.Equal(t, "4Gi", container.Resources.Requests.Memory().String())
+	assert.Equal(t, "500m", container.Resources.Requests.Cpu().String(), "resources not overridden are kept")
+	assert.Equal(t, int32(4), *job.Spec.Parallelism)
+	assert.JSONEq(t, `{"imageTag":"1.4.3-debug","requests":{"memory":"4Gi"},"parallelism":4}`, job.Annotations["job-assistant/run-overrides"])
+
+	changed, err = j.applyOverrides(job, nil, "john.smith")
+	require.NoError(t, err)
+	assert.True(t, changed, "the next run reverts the overrides")
+	assert.Equal(t, overridableJob().Spec, job.Spec)
+	assert.NotContains(t, job.Annotations, "job-assistant/run-overrides")
+	assert.NotContains(t, job.Annotations, "job-assistant/run-overrides-revert")
+
+	changed, err = j.applyOverrides(job, nil, "john.smith")
+	require.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestApplyOverridesIsRestricted(t *testing.T) {
+	j := &jobManager{jobAssistAnnotation: "job-assistant"}
+
+	_, err := j.applyOverrides(overridableJob(), &model.RunOverrides{ImageTag: "1.5.0"}, "john.smith")
+	var forbidden *OverrideForbiddenError
+	assert.ErrorAs(t, err, &forbidden)
+	_, err = j.applyOverrides(overridableJob(), &model.RunOverrides{ImageTag: "1.5.0"}, "")
+	assert.ErrorAs(t, err, &forbidden, "the user can not be trusted")
+
+	for name, overrides := range map[string]*model.RunOverrides{
+		"field not allowed":   {Args: []string{"--dry-run"}},
+		"unknown container":   {Container: "sidecar", ImageTag: "1.5.0"},
+		"invalid tag":         {ImageTag: "1.5.0 && rm -rf"},
+		"invalid resource":    {Limits: map[string]string{"nvidia.com/gpu": "1"}},
+		"invalid quantity":    {Requests: map[string]string{"memory": "lots"}},
+		"invalid parallelism": {Parallelism: pointer.Int32(-1)},
+		"request above limit": {Limits: map[string]string{"memory": "512Mi"}},
+		"invalid node label":  {NodeSelector: map[string]string{"pool": "spot instances"}},
+	} {
+		_, err := j.applyOverrides(overridableJob(), overrides, "jane.doe")
+		var invalid *InvalidRunOptionsError
+		assert.ErrorAs(t, err, &invalid, name)
+	}
+}
+
+func TestReplaceImageTag(t *testing.T) {
+	for image, expected := range map[string]string{
+		"export":                          "export:2.0",
+		"billing/export:1.4":              "billing/export:2.0",
+		"registry.local:5000/export":      "registry.local:5000/export:2.0",
+		"registry.local:5000/export:1.4":  "registry.local:5000/export:2.0",
+		"export:1.4@sha256:0123456789abc": "export:2.0",
+	} {
+		assert.Equal(t, expected, replaceImageTag(image, "2.0"), image)
+	}
+}
+
+func TestRunWithRejectedOverridesKeepsTheJob(t *testing.T) {
+	job := overridableJob()
+	job.Namespace = "billing"
+	kubeClient := newFakeKubeClient(job)
+	kubeClient.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if slices.Contains(action.(k8stesting.CreateActionImpl).CreateOptions.DryRun, metav1.DryRunAll) {
+			return true, nil, errors.NewInvalid(batchv1.SchemeGroupVersion.WithKind("Job").GroupKind(), job.Name, nil)
+		}
+		return false, nil, nil
+	})
+	j := &jobManager{kubeClient: kubeClient, jobAssistAnnotation: "job-assistant"}
+
+	ctx := logging.WithUser(context.Background(), "jane.doe")
+	err := j.Run(ctx, "billing", "nightly", RunOptions{Overrides: &model.RunOverrides{ImageTag: "1.5.0"}})
+	var invalid *InvalidRunOptionsError
+	assert.ErrorAs(t, err, &invalid)
+
+	kept, err := kubeClient.BatchV1().Jobs("billing").Get(ctx, "nightly", metav1.GetOptions{})
+	require.NoError(t, err, "the job is not deleted")
+	assert.Equal(t, job.Spec.Template.Spec.Containers[0].Image, kept.Spec.Template.Spec.Containers[0].Image)
+	for _, action := range kubeClient.Actions() {
+		assert.NotEqual(t, "delete", action.GetVerb())
+	}
+}
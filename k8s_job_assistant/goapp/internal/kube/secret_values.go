@@ -0,0 +1,66 @@
+package kube
+
+import (
+	"context"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"slices"
+)
+
+// SecretValues returns the values of the environment variables the pods of a Job source from
+// Secrets (env valueFrom.secretKeyRef and envFrom.secretRef), for them to be redacted. Secrets
+// which do not exist are skipped, the pods cannot start without them.
+func (j *jobManager) SecretValues(ctx context.Context, job *batchv1.Job) (values []string, err error) {
+	ctx, span := startSpan(ctx, "JobManager.SecretValues", job.Namespace, job.Name)
+	defer func() { endSpan(span, err) }()
+
+	secrets := map[string]*corev1.Secret{}
+	secret := func(name string) (*corev1.Secret, error) {
+		if s, ok := secrets[name]; ok {
+			return s, nil
+		}
+		var s *corev1.Secret
+		err := kubeCall(ctx, "get", "secrets", job.Namespace, name, func(ctx context.Context) (err error) {
+			s, err = j.kubeClient.CoreV1().Secrets(job.Namespace).Get(ctx, name, metav1.GetOptions{})
+			return err
+		})
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		secrets[name] = s // nil when not found
+		return s, nil
+	}
+
+	spec := &job.Spec.Template.Spec
+	for _, container := range slices.Concat(spec.InitContainers, spec.Containers) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef == nil {
+				continue
+			}
+			s, err := secret(envFrom.SecretRef.Name)
+			if err != nil {
+				return nil, err
+			}
+			if s != nil {
+				for _, value := range s.Data {
+					values = append(values, string(value))
+				}
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil || env.ValueFrom.SecretKeyRef == nil {
+				continue
+			}
+			s, err := secret(env.ValueFrom.SecretKeyRef.Name)
+			if err != nil {
+				return nil, err
+			}
+			if s != nil && len(s.Data[env.ValueFrom.SecretKeyRef.Key]) > 0 {
+				values = append(values, string(s.Data[env.ValueFrom.SecretKeyRef.Key]))
+			}
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,40 @@
+package kube
+
+import (
+	"context"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"time"
+)
+
+// JobUpdateHandler is called with the previous and the current version of a managed Job
+// each time it changes.
+type JobUpdateHandler func(oldJob, newJob *batchv1.Job)
+
+// Watch starts an informer on the Jobs of all namespaces, calling onUpdate for managed Jobs
+// until ctx is done. The returned hasSynced reports when the initial listing is complete,
+// Jobs found by that listing are not reported.
+func (j *jobManager) Watch(ctx context.Context, onUpdate JobUpdateHandler) (hasSynced func() bool, err error) {
+	factory := informers.NewSharedInformerFactory(j.kubeClient, 10*time.Minute)
+	informer := factory.Batch().V1().Jobs().Informer()
+	registration, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj any) {
+			oldJob, ok := oldObj.(*batchv1.Job)
+			if !ok {
+				return
+			}
+			newJob, ok := newObj.(*batchv1.Job)
+			if !ok || !j.isManaged(newJob) {
+				return
+			}
+			onUpdate(oldJob, newJob)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	factory.Start(ctx.Done())
+	return registration.HasSynced, nil
+}
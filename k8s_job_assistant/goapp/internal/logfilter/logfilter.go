@@ -0,0 +1,120 @@
+// Package logfilter filters logs line by line while they are streamed, so that searching
+// multi-GB logs does not require downloading them.
+package logfilter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Options of Filter, the zero value lets every line through
+type Options struct {
+	// Grep keeps the lines containing this substring
+	Grep string
+	// Regex keeps the lines matching this expression
+	Regex *regexp.Regexp
+	// Levels keeps the JSON lines whose level field is one of these, case insensitive. Other
+	// lines are dropped.
+	Levels []string
+	// Since and Until keep the lines logged within this window when set, lines must be Timestamped
+	Since, Until time.Time
+	// Timestamped tells the lines start with the timestamp added by Kubernetes, ie:
+	// '2025-06-02T03:00:00.123456789Z exporting'
+	Timestamped bool
+	// KeepTimestamps keeps the timestamps of Timestamped lines, they are stripped otherwise
+	KeepTimestamps bool
+}
+
+// Filters tells whether some lines may be dropped
+func (o Options) Filters() bool {
+	return o.Grep != "" || o.Regex != nil || len(o.Levels) > 0 || !o.Since.IsZero() || !o.Until.IsZero()
+}
+
+// Filter returns the lines of src kept by opts. Lines are expected in chronological order: the
+// stream ends at the first line logged after opts.Until, even when following the logs.
+func Filter(src io.ReadCloser, opts Options) io.ReadCloser {
+	return &filterReader{src: src, lines: bufio.NewReaderSize(src, 64*1024), opts: opts}
+}
+
+type filterReader struct {
+	src     io.ReadCloser
+	lines   *bufio.Reader
+	opts    Options
+	pending []byte
+	err     error
+}
+
+func (f *filterReader) Read(p []byte) (int, error) {
+	for len(f.pending) == 0 {
+		if f.err != nil {
+			return 0, f.err
+		}
+		line, err := f.lines.ReadBytes('\n')
+		f.err = err
+		if len(line) > 0 {
+			var past bool
+			f.pending, past = f.filter(line)
+			if past {
+				f.err = io.EOF
+			}
+		}
+	}
+	n := copy(p, f.pending)
+	f.pending = f.pending[n:]
+	return n, nil
+}
+
+func (f *filterReader) Close() error {
+	return f.src.Close()
+}
+
+// filter returns what is kept of a line, nil if it is dropped, and whether it was logged after Until
+func (f *filterReader) filter(line []byte) (kept []byte, past bool) {
+	message := line
+	if f.opts.Timestamped {
+		timestamp, rest, found := bytes.Cut(line, []byte(" "))
+		if loggedAt, err := time.Parse(time.RFC3339Nano, string(timestamp)); found && err == nil {
+			if !f.opts.Until.IsZero() && loggedAt.After(f.opts.Until) {
+				return nil, true
+			}
+			if !f.opts.Since.IsZero() && loggedAt.Before(f.opts.Since) {
+				return nil, false
+			}
+			message = rest
+		}
+	}
+	if f.opts.Grep != "" && !bytes.Contains(message, []byte(f.opts.Grep)) {
+		return nil, false
+	}
+	if f.opts.Regex != nil && !f.opts.Regex.Match(bytes.TrimSuffix(message, []byte("\n"))) {
+		return nil, false
+	}
+	if len(f.opts.Levels) > 0 && !slices.ContainsFunc(f.opts.Levels, func(level string) bool { return strings.EqualFold(level, jsonLevel(message)) }) {
+		return nil, false
+	}
+	if f.opts.KeepTimestamps {
+		return line, false
+	}
+	return message, false
+}
+
+// jsonLevel returns the level field of a JSON line, "" for other lines
+func jsonLevel(line []byte) string {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return ""
+	}
+	var entry struct {
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return ""
+	}
+	return entry.Level
+}
@@ -0,0 +1,93 @@
+package logfilter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+const testLogs = `2025-06-02T03:00:00.000000001Z {"level":"info","msg":"exporting invoices"}
+2025-06-02T03:00:10Z {"level":"ERROR","msg":"invoice 1234 rejected"}
+2025-06-02T03:00:20Z plain line about invoice 1235
+2025-06-02T03:00:30Z {"level":"warn","msg":"retrying"}
+2025-06-02T03:00:40Z {"level":"info","msg":"exported 1200 invoices"}
+`
+
+func filter(t *testing.T, logs string, opts Options) string {
+	t.Helper()
+	filtered, err := io.ReadAll(iotest.OneByteReader(Filter(io.NopCloser(strings.NewReader(logs)), opts)))
+	require.NoError(t, err)
+	return string(filtered)
+}
+
+func TestFilter(t *testing.T) {
+	at := func(seconds int) time.Time { return time.Date(2025, 6, 2, 3, 0, seconds, 0, time.UTC) }
+	for name, test := range map[string]struct {
+		opts     Options
+		expected string
+	}{
+		"timestamps stripped": {Options{Timestamped: true}, `{"level":"info","msg":"exporting invoices"}
+{"level":"ERROR","msg":"invoice 1234 rejected"}
+plain line about invoice 1235
+{"level":"warn","msg":"retrying"}
+{"level":"info","msg":"exported 1200 invoices"}
+`},
+		"grep": {Options{Grep: "invoice 123", Timestamped: true}, `{"level":"ERROR","msg":"invoice 1234 rejected"}
+plain line about invoice 1235
+`},
+		"grep does not match timestamps": {Options{Grep: "03:00", Timestamped: true}, ""},
+		"regex": {Options{Regex: regexp.MustCompile(`^plain|\d+ invoices"`), Timestamped: true, KeepTimestamps: true}, `2025-06-02T03:00:20Z plain line about invoice 1235
+2025-06-02T03:00:40Z {"level":"info","msg":"exported 1200 invoices"}
+`},
+		"levels": {Options{Levels: []string{"error", "warn"}, Timestamped: true}, `{"level":"ERROR","msg":"invoice 1234 rejected"}
+{"level":"warn","msg":"retrying"}
+`},
+		"time window": {Options{Since: at(10), Until: at(30), Timestamped: true, KeepTimestamps: true}, `2025-06-02T03:00:10Z {"level":"ERROR","msg":"invoice 1234 rejected"}
+2025-06-02T03:00:20Z plain line about invoice 1235
+2025-06-02T03:00:30Z {"level":"warn","msg":"retrying"}
+`},
+		"combined": {Options{Grep: "invoice", Levels: []string{"info"}, Since: at(1), Timestamped: true}, `{"level":"info","msg":"exported 1200 invoices"}
+`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, filter(t, testLogs, test.opts))
+		})
+	}
+}
+
+func TestFilterWithoutTimestamps(t *testing.T) {
+	assert.Equal(t, "2 failed\n", filter(t, "1 done\n2 failed\n3 done", Options{Grep: "failed"}))
+	assert.Equal(t, "3 done", filter(t, "1 done\n2 failed\n3 done", Options{Regex: regexp.MustCompile("3")}), "the last line may not end with a newline")
+}
+
+func TestFilterStopsAfterUntil(t *testing.T) {
+	src := &endlessLogs{}
+	filtered, err := io.ReadAll(Filter(src, Options{Until: time.Date(2025, 6, 2, 3, 0, 2, 0, time.UTC), Timestamped: true}))
+	require.NoError(t, err)
+	assert.Equal(t, "line 0\nline 1\nline 2\n", string(filtered), "a followed stream ends once past the window")
+}
+
+// endlessLogs logs a line per second from 2025-06-02T03:00:00Z, as a followed stream never ending
+type endlessLogs struct {
+	line    int
+	pending string
+}
+
+func (e *endlessLogs) Read(p []byte) (int, error) {
+	if e.pending == "" {
+		e.pending = time.Date(2025, 6, 2, 3, 0, e.line, 0, time.UTC).Format(time.RFC3339Nano) + " line " + string(rune('0'+e.line%10)) + "\n"
+		e.line++
+	}
+	n := copy(p, e.pending)
+	e.pending = e.pending[n:]
+	return n, nil
+}
+
+func (e *endlessLogs) Close() error {
+	return nil
+}
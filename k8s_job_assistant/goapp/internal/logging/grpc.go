@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// UnaryServerInterceptor is the gRPC counterpart of GinMiddleware, see grpcContext for
+// how the request ID and the user are found.
+func UnaryServerInterceptor(base *slog.Logger, userHeader string, trustedProxies []netip.Prefix) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx, logger := grpcContext(ctx, base, userHeader, trustedProxies)
+		resp, err := handler(ctx, req)
+		logCall(ctx, logger, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func StreamServerInterceptor(base *slog.Logger, userHeader string, trustedProxies []netip.Prefix) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, logger := grpcContext(ss.Context(), base, userHeader, trustedProxies)
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, logger, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// grpcContext reads the request ID from the metadata (or generates one) and identifies the
+// user by the common name of its mTLS client certificate, falling back to the userHeader
+// metadata set by a proxy in front of KJA, only trusted when the call comes from one of trustedProxies.
+func grpcContext(ctx context.Context, base *slog.Logger, userHeader string, trustedProxies []netip.Prefix) (context.Context, *slog.Logger) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	requestID := firstValue(md, RequestIDHeader)
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, requestID))
+
+	user := clientCertificateName(ctx)
+	trusted := user != ""
+	if user == "" {
+		user = firstValue(md, userHeader)
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			trusted = user != "" && fromTrustedProxy(p.Addr.String(), trustedProxies)
+		}
+	}
+
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+	ctx = withUser(ctx, user, trusted)
+
+	attrs := []any{"request_id", requestID, "user", UserFromContext(ctx)}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		attrs = append(attrs, "trace_id", spanContext.TraceID().String())
+	}
+	logger := base.With(attrs...)
+	return WithLogger(ctx, logger), logger
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if code == codes.Internal || code == codes.Unknown {
+		level = slog.LevelError
+	}
+	logger.Log(ctx, level, "request handled",
+		"method", method,
+		"code", code.String(),
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+}
+
+func clientCertificateName(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(strings.ToLower(key)); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// contextStream overrides the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RequestIDHeader is read from incoming requests (when set by a proxy) and always
+// returned in responses to correlate a request with its log lines.
+const RequestIDHeader = "X-Request-ID"
+
+type userKey struct{}
+
+type trustedUserKey struct{}
+
+type requestIDKey struct{}
+
+// UserFromContext returns the user who performed the request, or "anonymous".
+func UserFromContext(ctx context.Context) string {
+	if user, ok := ctx.Value(userKey{}).(string); ok && user != "" {
+		return user
+	}
+	return "anonymous"
+}
+
+// WithUser returns a copy of ctx carrying user, for requests not coming through the HTTP API.
+// KJA vouches for user, see TrustedUserFromContext.
+func WithUser(ctx context.Context, user string) context.Context {
+	return withUser(ctx, user, true)
+}
+
+func withUser(ctx context.Context, user string, trusted bool) context.Context {
+	ctx = context.WithValue(ctx, userKey{}, user)
+	return context.WithValue(ctx, trustedUserKey{}, trusted)
+}
+
+// TrustedUserFromContext returns the user who performed the request when their identity can be
+// trusted: the common name of a gRPC client certificate, a user header set by a trusted proxy, or
+// a user set by KJA itself (WithUser). It is empty otherwise, anyone reaching KJA can send the header.
+func TrustedUserFromContext(ctx context.Context) string {
+	if trusted, _ := ctx.Value(trustedUserKey{}).(bool); !trusted {
+		return ""
+	}
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// ParseTrustedProxies reads a comma separated list of addresses and CIDRs, ie: 10.0.0.0/8,192.168.1.4
+func ParseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, proxy := range strings.Split(value, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q, expected an address or a CIDR", proxy)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// fromTrustedProxy tells whether a request comes from one of trustedProxies, addr being the
+// address of its peer (ie: 10.0.3.12:41536)
+func fromTrustedProxy(addr string, trustedProxies []netip.Prefix) bool {
+	addrPort, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := addrPort.Addr().Unmap()
+	return slices.ContainsFunc(trustedProxies, func(proxy netip.Prefix) bool { return proxy.Contains(ip) })
+}
+
+// RequestIDFromContext returns the ID of the request being handled, empty if none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// GinMiddleware assigns a request ID to each request, identifies the user through
+// userHeader (set by the authenticating proxy in front of KJA, ie: X-Forwarded-User)
+// and stores a logger carrying both in the request context. One access log line
+// is written once the request is handled. The user is only trusted when the request
+// comes from one of trustedProxies, see TrustedUserFromContext.
+func GinMiddleware(base *slog.Logger, userHeader string, trustedProxies []netip.Prefix) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, requestID)
+		user := c.GetHeader(userHeader)
+
+		ctx := c.Request.Context()
+		ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+		ctx = withUser(ctx, user, user != "" && fromTrustedProxy(c.Request.RemoteAddr, trustedProxies))
+
+		attrs := []any{"request_id", requestID, "user", UserFromContext(ctx)}
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+			attrs = append(attrs, "trace_id", spanContext.TraceID().String())
+		}
+		logger := base.With(attrs...)
+		c.Request = c.Request.WithContext(WithLogger(ctx, logger))
+
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+		logger.Log(ctx, level, "request handled",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
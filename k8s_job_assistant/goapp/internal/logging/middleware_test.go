@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGinMiddlewareCarriesRequestIDAndUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var out bytes.Buffer
+	logger, err := NewLogger(&out, "debug", FormatJSON)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(GinMiddleware(logger, "X-Forwarded-User", nil))
+	router.GET("/run/:namespace/:name", func(c *gin.Context) {
+		_, l := With(c.Request.Context(), "operation", "run", "namespace", c.Param("namespace"), "name", c.Param("name"))
+		l.Info("running job")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/run/billing/nightly", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	req.Header.Set("X-Forwarded-User", "alice")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, "req-42", rec.Header().Get(RequestIDHeader))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var jobLine, accessLine map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &jobLine))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &accessLine))
+
+	assert.Equal(t, "running job", jobLine["msg"])
+	assert.Equal(t, "req-42", jobLine["request_id"])
+	assert.Equal(t, "alice", jobLine["user"])
+	assert.Equal(t, "billing", jobLine["namespace"])
+	assert.Equal(t, "nightly", jobLine["name"])
+	assert.Equal(t, "run", jobLine["operation"])
+
+	assert.Equal(t, "request handled", accessLine["msg"])
+	assert.Equal(t, "req-42", accessLine["request_id"])
+	assert.Equal(t, "/run/:namespace/:name", accessLine["route"])
+	assert.EqualValues(t, http.StatusOK, accessLine["status"])
+}
+
+func TestGinMiddlewareGeneratesRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger, err := NewLogger(&bytes.Buffer{}, "info", FormatText)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(GinMiddleware(logger, "X-Forwarded-User", nil))
+	var user, requestID string
+	router.GET("/list", func(c *gin.Context) {
+		user = UserFromContext(c.Request.Context())
+		requestID = RequestIDFromContext(c.Request.Context())
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/list", nil))
+
+	assert.NotEmpty(t, requestID)
+	assert.Equal(t, requestID, rec.Header().Get(RequestIDHeader))
+	assert.Equal(t, "anonymous", user)
+}
+
+func TestGinMiddlewareTrustsUserFromTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger, err := NewLogger(&bytes.Buffer{}, "info", FormatText)
+	require.NoError(t, err)
+	trustedProxies, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.1")
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(GinMiddleware(logger, "X-Forwarded-User", trustedProxies))
+	var user, trustedUser string
+	router.GET("/list", func(c *gin.Context) {
+		user = UserFromContext(c.Request.Context())
+		trustedUser = TrustedUserFromContext(c.Request.Context())
+	})
+
+	for remoteAddr, trusted := range map[string]string{"192.0.2.1:41536": "alice", "10.0.3.12:41536": "alice", "203.0.113.7:41536": ""} {
+		req := httptest.NewRequest(http.MethodGet, "/list", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-User", "alice")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, "alice", user, remoteAddr)
+		assert.Equal(t, trusted, trustedUser, remoteAddr)
+	}
+
+	_, err = ParseTrustedProxies("proxy.local")
+	assert.Error(t, err)
+}
+
+func TestNewLoggerRejectsInvalidConfiguration(t *testing.T) {
+	_, err := NewLogger(&bytes.Buffer{}, "verbose", FormatJSON)
+	assert.Error(t, err)
+
+	_, err = NewLogger(&bytes.Buffer{}, "info", "xml")
+	assert.Error(t, err)
+}
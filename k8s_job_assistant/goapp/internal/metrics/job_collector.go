@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"goapp/pkg/model"
+	"sync"
+	"time"
+)
+
+var (
+	jobStateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "job", "state"),
+		"Current state of a managed Job, always 1, the state is carried by the 'state' label.",
+		[]string{"namespace", "name", "state"}, nil)
+
+	jobLastSuccessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "job", "last_success_timestamp_seconds"),
+		"Unix timestamp of the last successful completion of a managed Job seen by KJA.",
+		[]string{"namespace", "name"}, nil)
+
+	jobLastDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "job", "last_duration_seconds"),
+		"Duration of the last finished run of a managed Job seen by KJA.",
+		[]string{"namespace", "name"}, nil)
+
+	jobListUpDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "job", "list_up"),
+		"1 if managed Jobs could be listed during the last scrape, 0 otherwise.",
+		nil, nil)
+)
+
+// JobLister lists the managed Jobs with their decorated status.
+type JobLister func(ctx context.Context) ([]model.DecoratedJob, error)
+
+type lastRun struct {
+	success  time.Time
+	duration time.Duration
+}
+
+// JobCollector computes per managed Job gauges at scrape time.
+//
+// Because a run deletes and recreates the Job, the status of a previous run is
+// lost as soon as a new one starts. The collector keeps the last seen values in
+// memory so the gauges survive re-runs, the last success is also kept by the Job
+// annotations for runs started through KJA.
+type JobCollector struct {
+	list JobLister
+
+	mu       sync.Mutex
+	lastRuns map[string]lastRun
+}
+
+func NewJobCollector(list JobLister) *JobCollector {
+	return &JobCollector{list: list, lastRuns: map[string]lastRun{}}
+}
+
+func (c *JobCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- jobStateDesc
+	ch <- jobLastSuccessDesc
+	ch <- jobLastDurationDesc
+	ch <- jobListUpDesc
+}
+
+func (c *JobCollector) Collect(ch chan<- prometheus.Metric) {
+	jobs, err := c.list(context.Background())
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(jobListUpDesc, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(jobListUpDesc, prometheus.GaugeValue, 1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	listed := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		state := job.LastStatus.Type
+		if state == "" {
+			state = "Unknown"
+		}
+		ch <- prometheus.MustNewConstMetric(jobStateDesc, prometheus.GaugeValue, 1, job.Namespace, job.Name, state)
+
+		key := job.Namespace + "/" + job.Name
+		listed[key] = true
+		last := c.lastRuns[key]
+		if job.LastSuccessfullyRunStarTime != nil && job.LastSuccessfullyRunCompletionTime != nil {
+			last.duration = job.LastSuccessfullyRunCompletionTime.Sub(job.LastSuccessfullyRunStarTime.Time)
+		}
+		if job.LastSuccess != nil && job.LastSuccess.CompletionTime != nil && job.LastSuccess.CompletionTime.After(last.success) {
+			last.success = job.LastSuccess.CompletionTime.Time
+		}
+		c.lastRuns[key] = last
+
+		if !last.success.IsZero() {
+			ch <- prometheus.MustNewConstMetric(jobLastSuccessDesc, prometheus.GaugeValue, float64(last.success.Unix()), job.Namespace, job.Name)
+		}
+		if last.duration > 0 {
+			ch <- prometheus.MustNewConstMetric(jobLastDurationDesc, prometheus.GaugeValue, last.duration.Seconds(), job.Namespace, job.Name)
+		}
+	}
+
+	// forget the Jobs deleted or no longer managed
+	for key := range c.lastRuns {
+		if !listed[key] {
+			delete(c.lastRuns, key)
+		}
+	}
+}
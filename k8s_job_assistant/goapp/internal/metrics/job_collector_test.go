@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/pkg/model"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobCollectorKeepsLastSuccessAcrossReRun(t *testing.T) {
+	start := metav1.NewTime(time.Unix(1700000000, 0))
+	completion := metav1.NewTime(start.Add(90 * time.Second))
+
+	jobs := []model.DecoratedJob{{
+		Namespace:                         "billing",
+		Name:                              "nightly",
+		LastStatus:                        model.LastStatus{Type: "Complete"},
+		LastSuccessfullyRunStarTime:       &start,
+		LastSuccessfullyRunCompletionTime: &completion,
+		LastSuccess:                       &model.SuccessfulRun{StartTime: &start, CompletionTime: &completion},
+	}}
+	collector := NewJobCollector(func(context.Context) ([]model.DecoratedJob, error) { return jobs, nil })
+
+	expected := `
+# HELP kja_job_last_duration_seconds Duration of the last finished run of a managed Job seen by KJA.
+# TYPE kja_job_last_duration_seconds gauge
+kja_job_last_duration_seconds{name="nightly",namespace="billing"} 90
+# HELP kja_job_last_success_timestamp_seconds Unix timestamp of the last successful completion of a managed Job seen by KJA.
+# TYPE kja_job_last_success_timestamp_seconds gauge
+kja_job_last_success_timestamp_seconds{name="nightly",namespace="billing"} 1.70000009e+09
+# HELP kja_job_state Current state of a managed Job, always 1, the state is carried by the 'state' label.
+# TYPE kja_job_state gauge
+kja_job_state{name="nightly",namespace="billing",state="Complete"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"kja_job_state", "kja_job_last_success_timestamp_seconds", "kja_job_last_duration_seconds"))
+
+	// a new run starts: the Job has been re-created and lost its previous status
+	newStart := metav1.NewTime(completion.Add(time.Hour))
+	jobs = []model.DecoratedJob{{
+		Namespace:                   "billing",
+		Name:                        "nightly",
+		LastStatus:                  model.LastStatus{Type: "Running"},
+		LastSuccessfullyRunStarTime: &newStart,
+	}}
+
+	expected = `
+# HELP kja_job_last_success_timestamp_seconds Unix timestamp of the last successful completion of a managed Job seen by KJA.
+# TYPE kja_job_last_success_timestamp_seconds gauge
+kja_job_last_success_timestamp_seconds{name="nightly",namespace="billing"} 1.70000009e+09
+# HELP kja_job_state Current state of a managed Job, always 1, the state is carried by the 'state' label.
+# TYPE kja_job_state gauge
+kja_job_state{name="nightly",namespace="billing",state="Running"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"kja_job_state", "kja_job_last_success_timestamp_seconds"))
+}
+
+func TestJobCollectorListError(t *testing.T) {
+	collector := NewJobCollector(func(context.Context) ([]model.DecoratedJob, error) { return nil, assert.AnError })
+
+	expected := `
+# HELP kja_job_list_up 1 if managed Jobs could be listed during the last scrape, 0 otherwise.
+# TYPE kja_job_list_up gauge
+kja_job_list_up 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "kja_job_list_up"))
+}
+
+func TestJobCollectorForgetsDeletedJobs(t *testing.T) {
+	completion := metav1.NewTime(time.Unix(1700000090, 0))
+	jobs := []model.DecoratedJob{
+		{Namespace: "billing", Name: "nightly", LastSuccess: &model.SuccessfulRun{CompletionTime: &completion}},
+		{Namespace: "billing", Name: "weekly", LastSuccess: &model.SuccessfulRun{CompletionTime: &completion}},
+	}
+	collector := NewJobCollector(func(context.Context) ([]model.DecoratedJob, error) { return jobs, nil })
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "kja_job_last_success_timestamp_seconds"))
+
+	jobs = jobs[:1]
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "kja_job_last_success_timestamp_seconds"))
+	assert.Len(t, collector.lastRuns, 1, "the deleted Job is forgotten")
+}
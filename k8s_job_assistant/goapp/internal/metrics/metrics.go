@@ -0,0 +1,123 @@
+// Package metrics exposes KJA and managed Jobs metrics in the Prometheus format
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"strconv"
+	"time"
+)
+
+const namespace = "kja"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method and route.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 20, 30},
+	}, []string{"method", "route"})
+
+	kubeRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kube_api_request_duration_seconds",
+		Help:      "Kubernetes API call latency, by verb and resource.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"verb", "resource"})
+
+	kubeRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kube_api_request_errors_total",
+		Help:      "Number of failed Kubernetes API calls, by verb and resource.",
+	}, []string{"verb", "resource"})
+
+	jobActions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_actions_total",
+		Help:      "Number of actions performed on managed Jobs, by action and outcome.",
+	}, []string{"action", "outcome"})
+
+	watchdogWarnings = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watchdog_warnings_total",
+		Help:      "Number of runs getting close to the max duration of their Job, by Job.",
+	}, []string{"namespace", "name"})
+
+	notifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_total",
+		Help:      "Number of run notifications sent, by channel type and outcome.",
+	}, []string{"type", "outcome"})
+
+	auditEntriesDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_entries_dropped_total",
+		Help:      "Number of audit entries dropped because the audit log was full or closed.",
+	})
+)
+
+// Action outcomes used as 'outcome' label of kja_job_actions_total
+const (
+	OutcomeSuccess        = "success"
+	OutcomeAlreadyRunning = "already_running"
+	OutcomeQueueFull      = "queue_full"
+	OutcomeError          = "error"
+)
+
+// GinMiddleware records count and latency of every HTTP request handled by the router.
+// Requests are labelled with the route pattern (ie: /run/:namespace/:name) to keep cardinality low.
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the metrics of the default Prometheus registry.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// ObserveKubeCall records latency and error of a Kubernetes API call started at 'start'.
+func ObserveKubeCall(verb, resource string, start time.Time, err error) {
+	kubeRequestDuration.WithLabelValues(verb, resource).Observe(time.Since(start).Seconds())
+	if err != nil {
+		kubeRequestErrors.WithLabelValues(verb, resource).Inc()
+	}
+}
+
+// IncJobAction counts a run/kill action with its outcome.
+func IncJobAction(action, outcome string) {
+	jobActions.WithLabelValues(action, outcome).Inc()
+}
+
+// IncWatchdogWarning counts a run of a Job getting close to its max duration.
+func IncWatchdogWarning(jobNamespace, jobName string) {
+	watchdogWarnings.WithLabelValues(jobNamespace, jobName).Inc()
+}
+
+// IncNotification counts a notification sent through a channel of the given type, outcome is
+// OutcomeSuccess or OutcomeError.
+func IncNotification(channelType, outcome string) {
+	notifications.WithLabelValues(channelType, outcome).Inc()
+}
+
+// IncAuditEntryDropped counts an audit entry which could not be recorded.
+func IncAuditEntryDropped() {
+	auditEntriesDropped.Inc()
+}
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"sigs.k8s.io/yaml"
+)
+
+// Channel types
+const (
+	TypeWebhook = "webhook"
+	TypeSlack   = "slack"
+	TypeEmail   = "email"
+)
+
+// Config declares the channels notifications can be routed to, it holds webhook URLs and
+// secrets so it is expected to be mounted from a Secret.
+//
+//	kjaURL: https://kja.your.company.com
+//	smtp:
+//	  addr: smtp.your.company.com:587
+//	  from: kja@your.company.com
+//	  username: kja
+//	  password: ...
+//	channels:
+//	  billing-slack:
+//	    type: slack
+//	    url: https://hooks.slack.com/services/...
+//	  data-platform:
+//	    type: webhook
+//	    url: https://data-platform.your.company.com/kja-events
+//	    secret: ...
+//	  ops-email:
+//	    type: email
+//	    to: [ops@your.company.com]
+type Config struct {
+	// KJAURL is linked from the notifications when set
+	KJAURL   string                   `json:"kjaURL,omitempty"`
+	SMTP     *SMTPConfig              `json:"smtp,omitempty"`
+	Channels map[string]ChannelConfig `json:"channels"`
+}
+
+type SMTPConfig struct {
+	// Addr is the host:port of the SMTP server, STARTTLS is used when the server supports it
+	Addr     string `json:"addr"`
+	From     string `json:"from"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+type ChannelConfig struct {
+	Type string `json:"type"`
+	// URL of the webhook, for webhook and slack channels
+	URL string `json:"url,omitempty"`
+	// Secret signs webhook payloads with HMAC-SHA256, see the signing package
+	Secret string `json:"secret,omitempty"`
+	// To are the recipients of email channels
+	To []string `json:"to,omitempty"`
+}
+
+// LoadConfig reads and validates the YAML configuration at path.
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read notifications config: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return config, fmt.Errorf("invalid notifications config %s: %w", path, err)
+	}
+	return config, config.validate()
+}
+
+func (c Config) validate() error {
+	for name, channel := range c.Channels {
+		switch channel.Type {
+		case TypeWebhook, TypeSlack:
+			if channel.URL == "" {
+				return fmt.Errorf("notification channel %q: url is required", name)
+			}
+		case TypeEmail:
+			if len(channel.To) == 0 {
+				return fmt.Errorf("notification channel %q: to is required", name)
+			}
+			if c.SMTP == nil {
+				return fmt.Errorf("notification channel %q: smtp must be configured to send emails", name)
+			}
+		default:
+			return fmt.Errorf("notification channel %q: unknown type %q, expected webhook, slack or email", name, channel.Type)
+		}
+	}
+	if c.SMTP != nil && (c.SMTP.Addr == "" || c.SMTP.From == "") {
+		return fmt.Errorf("smtp: addr and from are required")
+	}
+	return nil
+}
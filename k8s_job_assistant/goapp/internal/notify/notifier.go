@@ -0,0 +1,159 @@
+// Package notify sends notifications when runs started through KJA finish.
+//
+// Each managed Job routes its notifications with the notify annotation (see parseRoutes) to
+// channels declared in the Config: generic JSON webhooks signed with HMAC, Slack-compatible
+// incoming webhooks or emails.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"goapp/internal/logging"
+	"goapp/internal/metrics"
+	"goapp/internal/service"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxAttempts  = 3
+	retryBackoff = 2 * time.Second
+)
+
+// Notifier routes finished runs to the configured channels, sending them in the background.
+type Notifier struct {
+	kjaURL   string
+	smtp     *SMTPConfig
+	channels map[string]channel
+	// sendMail is smtp.SendMail, replaced in tests
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+	wg sync.WaitGroup
+}
+
+type channel struct {
+	kind   string
+	sender sender
+}
+
+// New returns a Notifier sending to the channels of config.
+func New(config Config) (*Notifier, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	n := &Notifier{kjaURL: config.KJAURL, smtp: config.SMTP, channels: map[string]channel{}, sendMail: smtp.SendMail}
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	for name, c := range config.Channels {
+		switch c.Type {
+		case TypeWebhook:
+			n.channels[name] = channel{kind: c.Type, sender: &webhookSender{url: c.URL, secret: []byte(c.Secret), httpClient: httpClient}}
+		case TypeSlack:
+			n.channels[name] = channel{kind: c.Type, sender: &slackSender{url: c.URL, httpClient: httpClient}}
+		case TypeEmail:
+			n.channels[name] = channel{kind: c.Type, sender: n.emailSender(c.To)}
+		}
+	}
+	return n, nil
+}
+
+func (n *Notifier) emailSender(to []string) *emailSender {
+	return &emailSender{smtp: *n.smtp, to: to, sendMail: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		return n.sendMail(addr, a, from, to, msg)
+	}}
+}
+
+// RunFinished sends the notifications of a finished run to the channels its Job routes them to.
+func (n *Notifier) RunFinished(ctx context.Context, run service.FinishedRun) {
+	if run.Notify == "" {
+		return
+	}
+	logger := logging.FromContext(ctx).With("namespace", run.Job.Namespace, "name", run.Job.Name, "run_id", run.Run.ID)
+	routes, err := parseRoutes(run.Notify)
+	if err != nil {
+		logger.Warn("invalid notify annotation, no notification sent", "error", err)
+		return
+	}
+
+	event := Event{
+		Type:      "run.finished",
+		Namespace: run.Job.Namespace,
+		Name:      run.Job.Name,
+		Run:       run.Run,
+		Status:    run.Job.LastStatus,
+		URL:       n.kjaURL,
+	}
+	// pending notifications are still sent once the watch stops, Close waits for them
+	sendCtx := context.WithoutCancel(ctx)
+	for _, r := range routes {
+		if !r.matches(run.Run.State) {
+			continue
+		}
+		c, err := n.channel(r.channel)
+		if err != nil {
+			logger.Warn("notification not sent", "channel", r.channel, "error", err)
+			continue
+		}
+
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			err := sendWithRetries(sendCtx, c.sender, event)
+			if err != nil {
+				metrics.IncNotification(c.kind, metrics.OutcomeError)
+				logger.Error("failed to send notification", "channel", r.channel, "error", err)
+				return
+			}
+			metrics.IncNotification(c.kind, metrics.OutcomeSuccess)
+			logger.Info("notification sent", "channel", r.channel, "state", run.Run.State)
+		}()
+	}
+}
+
+// channel returns a configured channel, or an email channel for 'mailto:' routes
+func (n *Notifier) channel(name string) (channel, error) {
+	if address, ok := strings.CutPrefix(name, mailtoPrefix); ok {
+		if n.smtp == nil {
+			return channel{}, fmt.Errorf("smtp is not configured")
+		}
+		return channel{kind: TypeEmail, sender: n.emailSender([]string{address})}, nil
+	}
+	c, ok := n.channels[name]
+	if !ok {
+		return channel{}, fmt.Errorf("unknown channel %q", name)
+	}
+	return c, nil
+}
+
+func sendWithRetries(ctx context.Context, s sender, event Event) error {
+	backoff := retryBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = s.send(ctx, event); err == nil || attempt == maxAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+// Close waits for the notifications being sent, up to ctx deadline.
+func (n *Notifier) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("notifications still being sent: %w", ctx.Err())
+	}
+}
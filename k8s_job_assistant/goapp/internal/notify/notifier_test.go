@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/service"
+	"goapp/internal/signing"
+	"goapp/pkg/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := parseRoutes(" billing-slack, ops-email=failed|Killed ,mailto:jane@company.com=succeeded")
+	require.NoError(t, err)
+	require.Len(t, routes, 3)
+	assert.Equal(t, "billing-slack", routes[0].channel)
+	assert.True(t, routes[0].matches(model.RunSucceeded))
+	assert.True(t, routes[1].matches(model.RunKilled))
+	assert.False(t, routes[1].matches(model.RunSucceeded))
+	assert.Equal(t, "mailto:jane@company.com", routes[2].channel)
+
+	_, err = parseRoutes("ops-email=done")
+	assert.Error(t, err)
+	_, err = parseRoutes("=failed")
+	assert.Error(t, err)
+}
+
+func TestConfigValidation(t *testing.T) {
+	_, err := New(Config{Channels: map[string]ChannelConfig{"ops": {Type: TypeEmail, To: []string{"ops@company.com"}}}})
+	assert.ErrorContains(t, err, "smtp must be configured")
+
+	_, err = New(Config{Channels: map[string]ChannelConfig{"teams": {Type: "teams", URL: "https://teams"}}})
+	assert.ErrorContains(t, err, "unknown type")
+}
+
+// recorder records the requests received by a fake webhook
+type recorder struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *recorder) server(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func finishedRun(state model.RunState, notify string) service.FinishedRun {
+	return service.FinishedRun{
+		Job:    model.DecoratedJob{Namespace: "billing", Name: "nightly", LastStatus: model.LastStatus{Type: "Failed", Message: "BackoffLimitExceeded"}},
+		Run:    model.Run{ID: "run-1", TriggeredBy: "jane.doe", State: state},
+		Notify: notify,
+	}
+}
+
+func TestRunFinishedRoutesToChannels(t *testing.T) {
+	webhook, slack := &recorder{}, &recorder{}
+	var mails []string
+	n, err := New(Config{
+		KJAURL: "https://kja.company.com",
+		SMTP:   &SMTPConfig{Addr: "smtp.company.com:25", From: "kja@company.com"},
+		Channels: map[string]ChannelConfig{
+			"data-platform": {Type: TypeWebhook, URL: webhook.server(t).URL, Secret: "s3cr3t"},
+			"billing-slack": {Type: TypeSlack, URL: slack.server(t).URL},
+		},
+	})
+	require.NoError(t, err)
+	var mu sync.Mutex
+	n.sendMail = func(_ string, _ smtp.Auth, _ string, to []string, msg []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		mails = append(mails, to[0])
+		return nil
+	}
+
+	n.RunFinished(context.Background(), finishedRun(model.RunFailed, "data-platform, billing-slack=succeeded, mailto:jane@company.com=failed, unknown"))
+	require.NoError(t, n.Close(context.Background()))
+
+	require.Len(t, webhook.requests, 1)
+	req, body := webhook.requests[0], webhook.bodies[0]
+	assert.NoError(t, signing.Verify([]byte("s3cr3t"), req.Header.Get(signing.TimestampHeader), req.Header.Get(signing.SignatureHeader), body, time.Minute, time.Now()))
+	var event Event
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, "run.finished", event.Type)
+	assert.Equal(t, model.RunFailed, event.Run.State)
+	assert.Equal(t, "https://kja.company.com", event.URL)
+
+	assert.Empty(t, slack.requests, "slack only routes succeeded runs")
+	assert.Equal(t, []string{"jane@company.com"}, mails)
+}
+
+func TestSlackPayload(t *testing.T) {
+	slack := &recorder{}
+	n, err := New(Config{Channels: map[string]ChannelConfig{"billing-slack": {Type: TypeSlack, URL: slack.server(t).URL}}})
+	require.NoError(t, err)
+
+	n.RunFinished(context.Background(), finishedRun(model.RunKilled, "billing-slack"))
+	require.NoError(t, n.Close(context.Background()))
+
+	require.Len(t, slack.bodies, 1)
+	var payload map[string]string
+	require.NoError(t, json.Unmarshal(slack.bodies[0], &payload))
+	assert.Equal(t, ":octagonal_sign: *billing/nightly* run run-1 killed (triggered by jane.doe)", payload["text"])
+}
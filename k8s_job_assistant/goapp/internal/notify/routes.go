@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"fmt"
+	"goapp/pkg/model"
+	"strings"
+)
+
+// mailtoPrefix routes to an email address directly, without declaring an email channel
+const mailtoPrefix = "mailto:"
+
+// route sends the notifications of some run states to a channel
+type route struct {
+	channel string
+	// states the route applies to, all final states when empty
+	states map[model.RunState]bool
+}
+
+func (r route) matches(state model.RunState) bool {
+	return len(r.states) == 0 || r.states[state]
+}
+
+// parseRoutes parses the notify annotation of a Job, a comma separated list of
+// '<channel>[=<state>|<state>...]', ie:
+//
+//	billing-slack, ops-email=failed|killed, mailto:jane.doe@your.company.com=succeeded
+func parseRoutes(annotation string) ([]route, error) {
+	var routes []route
+	for _, part := range strings.Split(annotation, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		channel, states, hasStates := strings.Cut(part, "=")
+		r := route{channel: strings.TrimSpace(channel)}
+		if r.channel == "" {
+			return nil, fmt.Errorf("invalid notify route %q: channel is missing", part)
+		}
+		if hasStates {
+			r.states = map[model.RunState]bool{}
+			for _, state := range strings.Split(states, "|") {
+				runState, err := parseState(strings.TrimSpace(state))
+				if err != nil {
+					return nil, fmt.Errorf("invalid notify route %q: %w", part, err)
+				}
+				r.states[runState] = true
+			}
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+func parseState(state string) (model.RunState, error) {
+	for _, runState := range []model.RunState{model.RunSucceeded, model.RunFailed, model.RunKilled} {
+		if strings.EqualFold(state, string(runState)) {
+			return runState, nil
+		}
+	}
+	return "", fmt.Errorf("unknown state %q, expected succeeded, failed or killed", state)
+}
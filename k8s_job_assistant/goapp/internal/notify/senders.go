@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"goapp/internal/signing"
+	"goapp/pkg/model"
+	"io"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is the JSON payload of webhook notifications.
+type Event struct {
+	// Type is always 'run.finished'
+	Type      string           `json:"type"`
+	Namespace string           `json:"namespace"`
+	Name      string           `json:"name"`
+	Run       model.Run        `json:"run"`
+	Status    model.LastStatus `json:"status"`
+	// URL links to KJA when configured
+	URL string `json:"url,omitempty"`
+}
+
+// sender delivers an event to one channel
+type sender interface {
+	send(ctx context.Context, event Event) error
+}
+
+type webhookSender struct {
+	url        string
+	secret     []byte
+	httpClient *http.Client
+}
+
+func (s *webhookSender) send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{}
+	if len(s.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		headers[signing.TimestampHeader] = timestamp
+		headers[signing.SignatureHeader] = signing.Sign(s.secret, timestamp, body)
+	}
+	return postJSON(ctx, s.httpClient, s.url, body, headers)
+}
+
+// slackSender posts to Slack (or any compatible, ie: Mattermost) incoming webhooks
+type slackSender struct {
+	url        string
+	httpClient *http.Client
+}
+
+func (s *slackSender) send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(map[string]string{"text": slackText(event)})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.httpClient, s.url, body, nil)
+}
+
+func slackText(event Event) string {
+	job := fmt.Sprintf("*%s/%s*", event.Namespace, event.Name)
+	if event.URL != "" {
+		job = fmt.Sprintf("<%s|%s/%s>", event.URL, event.Namespace, event.Name)
+	}
+	emoji := map[model.RunState]string{
+		model.RunSucceeded: ":white_check_mark:",
+		model.RunFailed:    ":x:",
+		model.RunKilled:    ":octagonal_sign:",
+	}[event.Run.State]
+	return fmt.Sprintf("%s %s %s", emoji, job, summary(event))
+}
+
+type emailSender struct {
+	smtp SMTPConfig
+	to   []string
+	// sendMail is smtp.SendMail, replaced in tests
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func (s *emailSender) send(_ context.Context, event Event) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.smtp.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&msg, "Subject: [KJA] %s/%s %s\r\n", event.Namespace, event.Name, strings.ToLower(string(event.Run.State)))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s/%s %s\r\n", event.Namespace, event.Name, summary(event))
+	if event.Status.Message != "" {
+		fmt.Fprintf(&msg, "\r\n%s\r\n", event.Status.Message)
+	}
+	if event.URL != "" {
+		fmt.Fprintf(&msg, "\r\n%s\r\n", event.URL)
+	}
+
+	var auth smtp.Auth
+	if s.smtp.Username != "" {
+		host, _, _ := strings.Cut(s.smtp.Addr, ":")
+		auth = smtp.PlainAuth("", s.smtp.Username, s.smtp.Password, host)
+	}
+	return s.sendMail(s.smtp.Addr, auth, s.smtp.From, s.to, msg.Bytes())
+}
+
+// summary describes the run in plain text, ie: 'run 20250602-030000-4f2a9c succeeded in 12m40s (triggered by jane.doe)'
+func summary(event Event) string {
+	text := fmt.Sprintf("run %s %s", event.Run.ID, strings.ToLower(string(event.Run.State)))
+	if event.Run.StartTime != nil {
+		end := time.Now()
+		switch {
+		case event.Run.CompletionTime != nil:
+			end = event.Run.CompletionTime.Time
+		case event.Run.KilledAt != nil:
+			end = event.Run.KilledAt.Time
+		}
+		text += " after " + end.Sub(event.Run.StartTime.Time).Round(time.Second).String()
+	}
+	if event.Run.TriggeredBy != "" {
+		text += fmt.Sprintf(" (triggered by %s)", event.Run.TriggeredBy)
+	}
+	return text
+}
+
+func postJSON(ctx context.Context, httpClient *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kube-job-assistant")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
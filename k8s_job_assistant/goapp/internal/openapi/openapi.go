@@ -0,0 +1,654 @@
+// Package openapi describes the KJA HTTP API as an OpenAPI 3 document.
+//
+// Schemas are generated from the goapp/pkg/model types, operations are declared in
+// the operations table below which must follow the routes of the handler package.
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+	"goapp/internal/bundle"
+	"goapp/pkg/model"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DocsPage renders the document served at /openapi.json with Redoc, loaded from /docs/redoc.standalone.js
+//
+//go:embed docs.html
+var DocsPage []byte
+
+// RedocBundleFile is the Redoc bundle the Dockerfile (or `make redoc`) copies into the redoc directory
+const RedocBundleFile = "redoc.standalone.js"
+
+//go:embed all:redoc
+var redoc embed.FS
+
+// RedocBundle returns the Redoc bundle embedded in the binary, served with the docs page rather
+// than loaded from a CDN. ok is false when the binary was built without it.
+func RedocBundle() (bundle []byte, ok bool) {
+	bundle, err := redoc.ReadFile("redoc/" + RedocBundleFile)
+	return bundle, err == nil
+}
+
+const errorSchema = "Error"
+
+// operation is one route of the API
+type operation struct {
+	method      string
+	path        string // gin syntax, ie: /status/:namespace/:name
+	id          string
+	summary     string
+	description string
+	query       []*openapi3.Parameter
+	headers     []*openapi3.Parameter
+	// request is the schema of the JSON body, nil for none
+	request        *openapi3.Schema
+	requestExample any
+	// status of the answer on success, defaults to 200
+	status int
+	// response is the name of the schema of the 200 answer, "" for an empty answer
+	response string
+	example  any
+	// text tells the 200 answer is plain text instead of JSON
+	text bool
+	// binary lists the media types of a file answer instead of JSON, ie: application/zip
+	binary []string
+	// queued tells the run may be queued, answered with 202 and the QueuedRun
+	queued bool
+	errors []int
+}
+
+var exampleTime = metav1.NewTime(time.Date(2025, 6, 2, 3, 0, 0, 0, time.UTC))
+var exampleCompletionTime = metav1.NewTime(time.Date(2025, 6, 2, 3, 12, 40, 0, time.UTC))
+
+var exampleJob = model.DecoratedJob{
+	Namespace:                         "kja-demo",
+	Name:                              "dummy-jobs-30s",
+	RunID:                             "20250602-030000-4f2a9c",
+	LastSuccessfullyRunStarTime:       &exampleTime,
+	LastStatus:                        model.LastStatus{Type: "Complete"},
+	LastSuccessfullyRunCompletionTime: &exampleCompletionTime,
+	State:                             model.RunSucceeded,
+	CurrentRun: &model.RunStatus{
+		StartTime:      &exampleTime,
+		CompletionTime: &exampleCompletionTime,
+		Duration:       &metav1.Duration{Duration: 12*time.Minute + 40*time.Second},
+		Succeeded:      1,
+		BackoffLimit:   6,
+	},
+	LastSuccess: &model.SuccessfulRun{
+		StartTime:      &exampleTime,
+		CompletionTime: &exampleCompletionTime,
+		Duration:       &metav1.Duration{Duration: 12*time.Minute + 40*time.Second},
+	},
+}
+
+var exampleRun = model.Run{
+	ID:             "20250602-030000-4f2a9c",
+	TriggeredBy:    "jane.doe",
+	State:          model.RunSucceeded,
+	StartTime:      &exampleTime,
+	CompletionTime: &exampleCompletionTime,
+	Params:         map[string]string{"DATE": "2025-06-02"},
+}
+
+var exampleArchivedLog = model.ArchivedLog{Pod: "dummy-jobs-30s-x2x4d", Container: "main", Size: 35}
+
+var exampleAuditEntry = model.AuditEntry{
+	Time:      exampleTime.Time,
+	RequestID: "0b7c8f5e-59d1-4b8e-9a61-2f3c1d0e4a77",
+	User:      "jane.doe",
+	Action:    "run",
+	Namespace: "kja-demo",
+	Name:      "dummy-jobs-30s",
+	Outcome:   "success",
+}
+
+var exampleQueuedRun = model.QueuedRun{
+	ID:          "20250602-030512-93c0d4",
+	Namespace:   "kja-demo",
+	Name:        "dummy-jobs-30s",
+	TriggeredBy: "jane.doe",
+	QueuedAt:    &exampleTime,
+	Position:    1,
+}
+
+var exampleScheduledRun = model.ScheduledRun{
+	ID:          "20250602-160512-5e1b07",
+	Namespace:   "kja-demo",
+	Name:        "dummy-jobs-30s",
+	At:          &exampleTime,
+	TimeZone:    "Europe/Paris",
+	ScheduledBy: "jane.doe",
+	CreatedAt:   &exampleTime,
+}
+
+var examplePipeline = model.Pipeline{
+	Namespace: "billing",
+	Name:      "monthly-close",
+	Steps: []model.PipelineStep{
+		{Job: "billing/extract"},
+		{Job: "billing/invoices", After: []string{"billing/extract"}},
+		{Job: "billing/ledger", After: []string{"billing/extract"}, ContinueOnError: true},
+	},
+}
+
+var examplePipelineRun = model.PipelineRun{
+	ID:          "20250602-030000-8b1d3e",
+	Namespace:   "billing",
+	Name:        "monthly-close",
+	TriggeredBy: "jane.doe",
+	State:       model.PipelineRunning,
+	StartTime:   &exampleTime,
+	Steps: []model.PipelineStepRun{
+		{Job: "billing/extract", RunID: "20250602-030000-4f2a9c", State: model.PipelineSucceeded, StartTime: &exampleTime, CompletionTime: &exampleCompletionTime},
+		{Job: "billing/invoices", RunID: "20250602-031250-0c7e21", State: model.PipelineRunning, StartTime: &exampleCompletionTime},
+		{Job: "billing/ledger", State: model.PipelinePending},
+	},
+}
+
+// overridesQuery overrides the fields of a Job for a run, see model.RunOverrides
+var overridesQuery = []*openapi3.Parameter{
+	openapi3.NewQueryParameter("container").WithDescription("container imageTag, arg and the resources apply to, defaults to the first one").
+		WithSchema(openapi3.NewStringSchema()),
+	openapi3.NewQueryParameter("imageTag").WithDescription("tag (or sha256 digest) replacing the one of the container image").
+		WithSchema(openapi3.NewStringSchema()),
+	openapi3.NewQueryParameter("arg").WithDescription("args replacing the ones of the container, repeated for each arg").
+		WithSchema(openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema())),
+	openapi3.NewQueryParameter("cpuRequest").WithDescription("cpu request of the container, ie: 500m").
+		WithSchema(openapi3.NewStringSchema()),
+	openapi3.NewQueryParameter("cpuLimit").WithDescription("cpu limit of the container").
+		WithSchema(openapi3.NewStringSchema()),
+	openapi3.NewQueryParameter("memoryRequest").WithDescription("memory request of the container, ie: 4Gi").
+		WithSchema(openapi3.NewStringSchema()),
+	openapi3.NewQueryParameter("memoryLimit").WithDescription("memory limit of the container").
+		WithSchema(openapi3.NewStringSchema()),
+	openapi3.NewQueryParameter("parallelism").WithDescription("parallelism of the Job").
+		WithSchema(openapi3.NewInt32Schema().WithMin(0)),
+	openapi3.NewQueryParameter("completions").WithDescription("completions of the Job").
+		WithSchema(openapi3.NewInt32Schema().WithMin(1)),
+	openapi3.NewQueryParameter("nodeSelector").WithDescription("node selector of the pods, key=value repeated for each label").
+		WithSchema(openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema())),
+}
+
+// killQuery tells how a run is killed, for /kill and /restart
+var killQuery = []*openapi3.Parameter{
+	openapi3.NewQueryParameter("mode").WithDescription("graceful lets the pods terminate within their grace period, force deletes them right away, escalate forces the kill after escalateAfter").
+		WithSchema(openapi3.NewStringSchema().WithEnum("graceful", "force", "escalate")),
+	openapi3.NewQueryParameter("grace").WithDescription("grace period of the pods (ie: 10s), defaults to their terminationGracePeriodSeconds").
+		WithSchema(openapi3.NewStringSchema()),
+	openapi3.NewQueryParameter("escalateAfter").WithDescription("how long escalate waits before forcing the kill, defaults to 30s").
+		WithSchema(openapi3.NewStringSchema()),
+	openapi3.NewQueryParameter("reason").WithDescription("reason shown in the status of the Job").
+		WithSchema(openapi3.NewStringSchema()),
+}
+
+var operations = []operation{
+	{
+		method:   http.MethodGet,
+		path:     "/list",
+		id:       "listJobs",
+		summary:  "List the Jobs managed by KJA",
+		response: "ListJobs",
+		example:  model.ListJobs{Jobs: []model.DecoratedJob{exampleJob}, Count: 1},
+		errors:   []int{http.StatusInternalServerError},
+	},
+	{
+		method:   http.MethodGet,
+		path:     "/status/:namespace/:name",
+		id:       "getJobStatus",
+		summary:  "Get the status of a managed Job",
+		response: "DecoratedJob",
+		example:  exampleJob,
+		errors:   []int{http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		method:      http.MethodGet,
+		path:        "/runs/:namespace/:name",
+		id:          "listJobRuns",
+		summary:     "List the runs of a managed Job",
+		description: "Most recent first.",
+		response:    "ListRuns",
+		example:     model.ListRuns{Runs: []model.Run{exampleRun}, Count: 1},
+		errors:      []int{http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		method:      http.MethodGet,
+		path:        "/runs/:namespace/:name/:run/logs",
+		id:          "listArchivedLogs",
+		summary:     "List the archived logs of a run",
+		description: "Logs of each container of the pods of the run, captured once it finished and before its pods were deleted. 404 when log archival is disabled.",
+		response:    "ListArchivedLogs",
+		example:     model.ListArchivedLogs{Logs: []model.ArchivedLog{exampleArchivedLog}, Count: 1},
+		errors:      []int{http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		method:      http.MethodGet,
+		path:        "/runs/:namespace/:name/:run/logs/:pod/:container",
+		id:          "getArchivedLog",
+		summary:     "Get the archived logs of a container of a run",
+		description: "Logs captured up to the -archive-max-bytes of KJA, which ends truncated logs with a notice. Filtered logs are answered without Content-Length.",
+		query: append([]*openapi3.Parameter{
+			openapi3.NewQueryParameter("limitBytes").WithDescription("only return the first bytes, once filtered").
+				WithSchema(openapi3.NewInt64Schema().WithMin(0)),
+			openapi3.NewQueryParameter("download").WithDescription("answer as an attachment, for browsers to save it").
+				WithSchema(openapi3.NewBoolSchema()),
+		}, logFilterParameters()...),
+		text:    true,
+		example: "starting export\nexported 1234 rows\n",
+		errors:  []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/runs/:namespace/:name/:run/bundle",
+		id:      "getRunBundle",
+		summary: "Download the bundle of a run",
+		description: "Archive holding the logs of each container of the pods of the run, the Job and pod manifests as they ran, the related Events and the run history entry. " +
+			"The current run is read from the cluster, past runs from the log archive. 404 for past runs when log archival is disabled.",
+		query: []*openapi3.Parameter{
+			openapi3.NewQueryParameter("format").WithDescription("archive format, defaults to zip").
+				WithSchema(openapi3.NewStringSchema().WithEnum(bundle.FormatZip, bundle.FormatTarGz)),
+		},
+		binary: []string{"application/zip", "application/gzip"},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		method:      http.MethodGet,
+		path:        "/logs/:namespace/:name",
+		id:          "getJobLogs",
+		summary:     "Stream the logs of the current run",
+		description: "Logs of the most recent pod of the Job, streamed as plain text. Filters are applied while streaming, after tail.",
+		query: append([]*openapi3.Parameter{
+			openapi3.NewQueryParameter("follow").WithDescription("keep streaming while the run is going on").
+				WithSchema(openapi3.NewBoolSchema()),
+			openapi3.NewQueryParameter("container").WithDescription("container to get the logs of, defaults to the first one").
+				WithSchema(openapi3.NewStringSchema()),
+			openapi3.NewQueryParameter("pod").WithDescription("pod to get the logs of, defaults to the most recent one").
+				WithSchema(openapi3.NewStringSchema()),
+			openapi3.NewQueryParameter("tail").WithDescription("only return the last lines").
+				WithSchema(openapi3.NewInt64Schema().WithMin(0)),
+		}, logFilterParameters()...),
+		text:    true,
+		example: "starting export\nexported 1234 rows\n",
+		errors:  []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/run/:namespace/:name",
+		id:      "runJob",
+		summary: "Run a managed Job",
+		description: "Unsuspends a suspended Job, re-creates a finished one. Fails with 409 while the Job is running, " +
+			"unless the Job has the job-assistant/queue annotation: the run is then queued (202), 409 once the queue is full. " +
+			"The query overrides fields of the Job for this run, the Job must allow them with its job-assistant/overrides " +
+			"annotation and the user must be listed by its job-assistant/override-users one (403 otherwise).",
+		query:  overridesQuery,
+		queued: true,
+		errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/hooks/:namespace/:name",
+		id:      "runJobFromHook",
+		summary: "Run a managed Job from a signed webhook",
+		description: "The Job must reference the Secret holding its webhook secret with the job-assistant/hook-secret annotation. " +
+			"X-KJA-Signature is 'sha256=' followed by the hex HMAC-SHA256 of '<X-KJA-Timestamp>.<body>', the timestamp (Unix seconds) " +
+			"must be within 5 minutes, and each signed request is accepted once. A Job which does not exist or has no webhook secret " +
+			"is answered with a 401, like a bad signature. Params are read from the 'params' object of the payload, or from the " +
+			"fields mapped by the job-assistant/hook-params annotation.",
+		headers: []*openapi3.Parameter{
+			openapi3.NewHeaderParameter("X-KJA-Timestamp").WithRequired(true).WithSchema(openapi3.NewStringSchema()),
+			openapi3.NewHeaderParameter("X-KJA-Signature").WithRequired(true).WithSchema(openapi3.NewStringSchema()),
+		},
+		request:        openapi3.NewObjectSchema().WithProperty("params", openapi3.NewObjectSchema().WithAdditionalProperties(openapi3.NewStringSchema())),
+		requestExample: map[string]any{"params": map[string]any{"SOURCE_FILE": "s3://vendor/2025-06-02.csv"}},
+		status:         http.StatusAccepted,
+		queued:         true,
+		errors:         []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusInternalServerError},
+	},
+	{
+		method:      http.MethodGet,
+		path:        "/kill/:namespace/:name",
+		id:          "killJob",
+		summary:     "Kill the running Job",
+		description: "Suspends the Job and deletes its pods. The answer is sent once the pods are gone.",
+		query:       killQuery,
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/restart/:namespace/:name",
+		id:      "restartJob",
+		summary: "Kill the current run and run the Job again",
+		description: "Kills the current run like /kill, then runs the Job again with the same params. " +
+			"Runs and kills of the Job are rejected with a 409 until the restart is done.",
+		query:  killQuery,
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/retry/:namespace/:name",
+		id:      "retryFailedIndexes",
+		summary: "Re-run the failed indexes of an Indexed Job",
+		description: "Each failed index of the last run is re-run by a Job of its own, '<name>-retry-<index>', whose pod gets " +
+			"the index in JOB_COMPLETION_INDEX. Indexes whose retry is running or succeeded are left alone. Fails with 400 " +
+			"when the Job is not Indexed or its last run did not fail, 409 while it runs.",
+		response: "RetriedIndexes",
+		example:  model.RetriedIndexes{Indexes: []int{3, 7}, Count: 2},
+		errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	},
+	{
+		method:      http.MethodGet,
+		path:        "/queue",
+		id:          "listQueuedRuns",
+		summary:     "List the queued runs",
+		description: "Runs requested while their Job was running, by Job then position. The queue is kept in KJA memory.",
+		response:    "ListQueuedRuns",
+		example:     model.ListQueuedRuns{Runs: []model.QueuedRun{exampleQueuedRun}, Count: 1},
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/queue/:namespace/:name/:id/cancel",
+		id:      "cancelQueuedRun",
+		summary: "Remove a run from the queue of its Job",
+		errors:  []int{http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		method:      http.MethodGet,
+		path:        "/audit",
+		id:          "listAuditEntries",
+		summary:     "List the audit entries kept in the store",
+		description: "Most recent first, the oldest ones are removed beyond the -store-audit-entries retention.",
+		query: []*openapi3.Parameter{
+			openapi3.NewQueryParameter("limit").WithDescription("only return the most recent entries").
+				WithSchema(openapi3.NewIntegerSchema().WithMin(0)),
+		},
+		response: "ListAuditEntries",
+		example:  model.ListAuditEntries{Entries: []model.AuditEntry{exampleAuditEntry}, Count: 1},
+		errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	{
+		method:      http.MethodGet,
+		path:        "/schedule",
+		id:          "listScheduledRuns",
+		summary:     "List the scheduled runs",
+		description: "Next first.",
+		response:    "ListScheduledRuns",
+		example:     model.ListScheduledRuns{Runs: []model.ScheduledRun{exampleScheduledRun}, Count: 1},
+		errors:      []int{http.StatusInternalServerError},
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/schedule/:namespace/:name",
+		id:      "scheduleRun",
+		summary: "Schedule a run of a managed Job",
+		description: "'at' is an RFC3339 time, or a local time such as 2025-06-03T02:00 of 'timeZone' (IANA name). " +
+			"The run is started at that time like from /run, by the user who scheduled it. " +
+			"Params must be allowed by the params annotation of the Job.",
+		request: openapi3.NewObjectSchema().
+			WithProperty("at", openapi3.NewStringSchema()).
+			WithProperty("timeZone", openapi3.NewStringSchema()).
+			WithProperty("params", openapi3.NewObjectSchema().WithAdditionalProperties(openapi3.NewStringSchema())),
+		requestExample: map[string]any{"at": "2025-06-03T02:00", "timeZone": "Europe/Paris"},
+		status:         http.StatusCreated,
+		response:       "ScheduledRun",
+		example:        exampleScheduledRun,
+		errors:         []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/schedule/:namespace/:name/:id/cancel",
+		id:      "cancelScheduledRun",
+		summary: "Cancel a scheduled run",
+		errors:  []int{http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		method:      http.MethodGet,
+		path:        "/pipelines",
+		id:          "listPipelines",
+		summary:     "List the pipelines",
+		description: "Pipelines are defined by ConfigMaps labelled 'job-assistant/pipeline: enable', invalid ones are left out.",
+		response:    "ListPipelines",
+		example:     model.ListPipelines{Pipelines: []model.Pipeline{examplePipeline}, Count: 1},
+		errors:      []int{http.StatusInternalServerError},
+	},
+	{
+		method:      http.MethodPost,
+		path:        "/pipelines/:namespace/:name/runs",
+		id:          "startPipeline",
+		summary:     "Start a run of a pipeline",
+		description: "Steps run once the steps they come after are done. Fails with 409 while the previous run is not finished.",
+		status:      http.StatusAccepted,
+		response:    "PipelineRun",
+		example:     examplePipelineRun,
+		errors:      []int{http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusInternalServerError},
+	},
+	{
+		method:      http.MethodGet,
+		path:        "/pipelines/:namespace/:name/runs",
+		id:          "listPipelineRuns",
+		summary:     "List the runs of a pipeline",
+		description: "Most recent first, runs are kept in KJA memory.",
+		response:    "ListPipelineRuns",
+		example:     model.ListPipelineRuns{Runs: []model.PipelineRun{examplePipelineRun}, Count: 1},
+		errors:      []int{http.StatusInternalServerError},
+	},
+	{
+		method:   http.MethodGet,
+		path:     "/pipelines/:namespace/:name/runs/:run",
+		id:       "getPipelineRun",
+		summary:  "Get a run of a pipeline",
+		response: "PipelineRun",
+		example:  examplePipelineRun,
+		errors:   []int{http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		method:      http.MethodPost,
+		path:        "/pipelines/:namespace/:name/runs/:run/cancel",
+		id:          "cancelPipelineRun",
+		summary:     "Cancel a run of a pipeline",
+		description: "Kills the running steps, the pending ones are not started.",
+		status:      http.StatusAccepted,
+		errors:      []int{http.StatusNotFound, http.StatusInternalServerError},
+	},
+}
+
+var errorExamples = map[int]string{
+	http.StatusBadRequest:            "invalid run options: params DATE are not allowed by the job-assistant/params annotation",
+	http.StatusUnauthorized:          "invalid webhook: invalid signature",
+	http.StatusForbidden:             "john.smith is not allowed to override the job fields, see the job-assistant/override-users annotation",
+	http.StatusRequestEntityTooLarge: "Payload too large",
+	http.StatusNotFound:              `jobs.batch "dummy-jobs-30s" not found`,
+	http.StatusConflict:              "job kja-demo/dummy-jobs-30s is already running",
+	http.StatusUnprocessableEntity:   "invalid pipeline: steps depend on each other: billing/invoices, billing/ledger",
+	http.StatusInternalServerError:   "failed to reach the Kubernetes API",
+}
+
+// logFilterParameters are the query parameters filtering the lines of the logs, see the logfilter package
+func logFilterParameters() []*openapi3.Parameter {
+	return []*openapi3.Parameter{
+		openapi3.NewQueryParameter("grep").WithDescription("only return the lines containing this text").
+			WithSchema(openapi3.NewStringSchema()),
+		openapi3.NewQueryParameter("regex").WithDescription("only return the lines matching this RE2 regular expression, ie: (?i)timeout|refused").
+			WithSchema(openapi3.NewStringSchema()),
+		openapi3.NewQueryParameter("level").WithDescription("only return the JSON lines whose level field is one of these (comma separated, case insensitive), ie: error,warn").
+			WithSchema(openapi3.NewStringSchema()),
+		openapi3.NewQueryParameter("since").WithDescription("only return the lines logged from this RFC3339 time, or this duration ago, ie: 15m").
+			WithSchema(openapi3.NewStringSchema()),
+		openapi3.NewQueryParameter("until").WithDescription("only return the lines logged up to this RFC3339 time, or this duration ago. A followed stream ends past it.").
+			WithSchema(openapi3.NewStringSchema()),
+		openapi3.NewQueryParameter("timestamps").WithDescription("start each line with the time it was logged at").
+			WithSchema(openapi3.NewBoolSchema()),
+	}
+}
+
+var ginParam = regexp.MustCompile(`:(\w+)`)
+
+// Path converts a gin route path to an OpenAPI one, ie: /status/:namespace/:name to /status/{namespace}/{name}
+func Path(ginPath string) string {
+	return ginParam.ReplaceAllString(ginPath, "{$1}")
+}
+
+// Spec returns the OpenAPI document of the KJA API.
+func Spec() (*openapi3.T, error) {
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:       "Kubernetes Job Assistant",
+			Description: "Run, kill and follow Kubernetes Jobs annotated with 'job-assistant: enable'.",
+			Version:     "1.0.0",
+		},
+		Paths:      openapi3.NewPaths(),
+		Components: &openapi3.Components{Schemas: openapi3.Schemas{}},
+	}
+
+	for name, value := range map[string]any{
+		"DecoratedJob":      model.DecoratedJob{},
+		"ListJobs":          model.ListJobs{},
+		"Run":               model.Run{},
+		"ListRuns":          model.ListRuns{},
+		"ListArchivedLogs":  model.ListArchivedLogs{},
+		"RetriedIndexes":    model.RetriedIndexes{},
+		"QueuedRun":         model.QueuedRun{},
+		"ListQueuedRuns":    model.ListQueuedRuns{},
+		"ScheduledRun":      model.ScheduledRun{},
+		"ListScheduledRuns": model.ListScheduledRuns{},
+		"ListAuditEntries":  model.ListAuditEntries{},
+		"Pipeline":          model.Pipeline{},
+		"ListPipelines":     model.ListPipelines{},
+		"PipelineRun":       model.PipelineRun{},
+		"ListPipelineRuns":  model.ListPipelineRuns{},
+		errorSchema: struct {
+			Error string `json:"error"`
+		}{},
+	} {
+		schema, err := openapi3gen.NewSchemaRefForValue(value, nil, openapi3gen.SchemaCustomizer(customizeSchema))
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate the %s schema: %w", name, err)
+		}
+		doc.Components.Schemas[name] = schema
+	}
+
+	for _, op := range operations {
+		item := doc.Paths.Value(Path(op.path))
+		if item == nil {
+			item = &openapi3.PathItem{}
+			doc.Paths.Set(Path(op.path), item)
+		}
+		item.SetOperation(op.method, op.openAPI(doc.Components.Schemas))
+	}
+	return doc, nil
+}
+
+func (op operation) openAPI(schemas openapi3.Schemas) *openapi3.Operation {
+	o := openapi3.NewOperation()
+	o.OperationID = op.id
+	o.Summary = op.summary
+	o.Description = op.description
+	for _, name := range ginParam.FindAllStringSubmatch(op.path, -1) {
+		o.AddParameter(openapi3.NewPathParameter(name[1]).WithSchema(openapi3.NewStringSchema()))
+	}
+	for _, param := range op.query {
+		o.AddParameter(param)
+	}
+	for _, param := range op.headers {
+		o.AddParameter(param)
+	}
+	if op.request != nil {
+		body := openapi3.NewRequestBody().WithJSONSchema(op.request)
+		body.Content.Get("application/json").Example = op.requestExample
+		o.RequestBody = &openapi3.RequestBodyRef{Value: body}
+	}
+
+	status := op.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	ok := openapi3.NewResponse().WithDescription(http.StatusText(status))
+	switch {
+	case op.text:
+		ok.Content = openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/plain"})
+		ok.Content.Get("text/plain").Example = op.example
+	case len(op.binary) > 0:
+		ok.Content = openapi3.NewContentWithSchema(openapi3.NewStringSchema().WithFormat("binary"), op.binary)
+	case op.response != "":
+		ok.Content = openapi3.NewContentWithJSONSchemaRef(schemaRef(schemas, op.response))
+		ok.Content.Get("application/json").Example = jsonValue(op.example)
+	}
+	o.AddResponse(status, ok)
+	if op.queued {
+		queued := openapi3.NewResponse().WithDescription("The Job is running, the run was queued")
+		queued.Content = openapi3.NewContentWithJSONSchemaRef(schemaRef(schemas, "QueuedRun"))
+		queued.Content.Get("application/json").Example = jsonValue(exampleQueuedRun)
+		o.AddResponse(http.StatusAccepted, queued)
+	}
+
+	for _, status := range op.errors {
+		response := openapi3.NewResponse().WithDescription(http.StatusText(status))
+		response.Content = openapi3.NewContentWithJSONSchemaRef(schemaRef(schemas, errorSchema))
+		response.Content.Get("application/json").Example = map[string]any{"error": errorExamples[status]}
+		o.AddResponse(status, response)
+	}
+	return o
+}
+
+// jsonValue converts an example to its JSON representation, as the document is validated against it
+func jsonValue(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("invalid OpenAPI example: %v", err))
+	}
+	var value any
+	_ = json.Unmarshal(data, &value)
+	return value
+}
+
+// schemaRef references a schema of the components
+func schemaRef(schemas openapi3.Schemas, name string) *openapi3.SchemaRef {
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, schemas[name].Value)
+}
+
+var metav1TimeType = reflect.TypeOf(metav1.Time{})
+var metav1DurationType = reflect.TypeOf(metav1.Duration{})
+var runStateType = reflect.TypeOf(model.RunState(""))
+var pipelineStateType = reflect.TypeOf(model.PipelineState(""))
+
+// customizeSchema fixes what reflection can not guess: Kubernetes timestamps, enums and required fields
+func customizeSchema(_ string, t reflect.Type, _ reflect.StructTag, schema *openapi3.Schema) error {
+	switch {
+	case t == metav1TimeType:
+		nullable := schema.Nullable
+		*schema = *openapi3.NewDateTimeSchema()
+		schema.Nullable = nullable
+	case t == metav1DurationType:
+		nullable := schema.Nullable
+		*schema = *openapi3.NewStringSchema()
+		schema.Description = "Go duration, ie: 12m40s"
+		schema.Nullable = nullable
+	case t == runStateType:
+		for _, state := range []model.RunState{model.RunNeverRun, model.RunPending, model.RunRunning, model.RunSucceeded,
+			model.RunFailed, model.RunKilled, model.RunSuspended} {
+			schema.Enum = append(schema.Enum, string(state))
+		}
+	case t == pipelineStateType:
+		for _, state := range []model.PipelineState{model.PipelinePending, model.PipelineRunning, model.PipelineSucceeded,
+			model.PipelineFailed, model.PipelineCancelled, model.PipelineSkipped} {
+			schema.Enum = append(schema.Enum, string(state))
+		}
+	case t.Kind() == reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			name, options, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name != "" && name != "-" && !strings.Contains(options, "omitempty") {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+// Package redact masks credentials in the logs and manifests KJA returns: the values of the
+// environment variables sourced from Secrets, the inline values of environment variables whose
+// name looks sensitive (ie: DB_PASSWORD), and the values matching configurable patterns.
+package redact
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Mask replaces the redacted values
+const Mask = "[REDACTED]"
+
+// minValueLength is the length below which values are not masked: masking short values such as
+// 'true' or 'prod' everywhere would make logs unreadable while hiding little.
+const minValueLength = 6
+
+// DefaultPatterns match common credentials. When a pattern has capture groups only the groups
+// are masked, ie: the value of 'password=...'.
+var DefaultPatterns = []string{
+	// AWS access key IDs
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	// AWS secret access keys, next to their name
+	`(?i)aws_?secret_?access_?key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`,
+	// passwords, tokens, secrets and API keys assigned in text, JSON or YAML
+	`(?i)(?:password|passwd|secret|token|api_?key)["']?\s*[=:]\s*["']?([^\s"',;&]+)`,
+	// bearer tokens of Authorization headers
+	`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`,
+	// JSON Web Tokens
+	`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`,
+	// GitHub tokens
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	// private keys in PEM
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----`,
+}
+
+// DefaultNames match the names of sensitive environment variables, case insensitive
+var DefaultNames = []string{"*PASSWORD*", "*PASSWD*", "*SECRET*", "*TOKEN*", "*API_KEY*", "*APIKEY*", "*ACCESS_KEY*", "*PRIVATE_KEY*", "*CREDENTIAL*"}
+
+// Redactor holds the redaction configuration, a nil Redactor masks nothing
+type Redactor struct {
+	patterns []*regexp.Regexp
+	names    []string
+}
+
+// New returns a Redactor masking the values matching patterns (regular expressions) and the
+// inline values of the environment variables whose name matches one of names (globs, ie: '*_PASSWORD')
+func New(patterns, names []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	for _, name := range names {
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, fmt.Errorf("invalid redacted name %q: %w", name, err)
+		}
+		r.names = append(r.names, strings.ToUpper(name))
+	}
+	return r, nil
+}
+
+// SensitiveName tells whether the value of an environment variable is redacted because of its name
+func (r *Redactor) SensitiveName(name string) bool {
+	if r == nil {
+		return false
+	}
+	name = strings.ToUpper(name)
+	return slices.ContainsFunc(r.names, func(pattern string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	})
+}
+
+// InlineValues returns the values of the environment variables of a pod whose name is sensitive
+func (r *Redactor) InlineValues(spec *corev1.PodSpec) []string {
+	var values []string
+	for _, container := range slices.Concat(spec.InitContainers, spec.Containers) {
+		for _, env := range container.Env {
+			if env.Value != "" && r.SensitiveName(env.Name) {
+				values = append(values, env.Value)
+			}
+		}
+	}
+	return values
+}
+
+// PodSpec masks in place the values of the environment variables of a pod whose name is sensitive
+func (r *Redactor) PodSpec(spec *corev1.PodSpec) {
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			for j, env := range containers[i].Env {
+				if env.Value != "" && r.SensitiveName(env.Name) {
+					containers[i].Env[j].Value = Mask
+				}
+			}
+		}
+	}
+}
+
+// Redaction masks known values, ie: those of the Secrets of a Job, along with the patterns of
+// its Redactor. A nil Redaction masks nothing.
+type Redaction struct {
+	redactor *Redactor
+	// values are sorted longest first, for a value containing another one to be masked whole
+	values [][]byte
+}
+
+// Redaction returns a Redaction masking values (each of their lines) and the patterns of r
+func (r *Redactor) Redaction(values []string) *Redaction {
+	if r == nil {
+		return nil
+	}
+	x := &Redaction{redactor: r}
+	for _, value := range values {
+		for _, line := range strings.Split(value, "\n") {
+			if line = strings.TrimSpace(line); len(line) >= minValueLength && !slices.ContainsFunc(x.values, func(v []byte) bool { return string(v) == line }) {
+				x.values = append(x.values, []byte(line))
+			}
+		}
+	}
+	slices.SortFunc(x.values, func(a, b []byte) int { return len(b) - len(a) })
+	return x
+}
+
+// Bytes returns text with the values and patterns masked
+func (x *Redaction) Bytes(text []byte) []byte {
+	if x == nil {
+		return text
+	}
+	for _, value := range x.values {
+		text = bytes.ReplaceAll(text, value, []byte(Mask))
+	}
+	for _, pattern := range x.redactor.patterns {
+		text = maskMatches(pattern, text)
+	}
+	return text
+}
+
+// maskMatches masks the matches of pattern in text, only their groups when it has some
+func maskMatches(pattern *regexp.Regexp, text []byte) []byte {
+	matches := pattern.FindAllSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+	var masked bytes.Buffer
+	last := 0
+	for _, match := range matches {
+		spans := [][2]int{{match[0], match[1]}}
+		if len(match) > 2 {
+			spans = spans[:0]
+			for group := 2; group < len(match); group += 2 {
+				if match[group] >= 0 {
+					spans = append(spans, [2]int{match[group], match[group+1]})
+				}
+			}
+		}
+		for _, span := range spans {
+			if span[0] < last {
+				continue // nested groups
+			}
+			masked.Write(text[last:span[0]])
+			masked.WriteString(Mask)
+			last = span[1]
+		}
+	}
+	masked.Write(text[last:])
+	return masked.Bytes()
+}
+
+// Reader masks the values and patterns in a stream, line by line
+func (x *Redaction) Reader(src io.ReadCloser) io.ReadCloser {
+	if x == nil {
+		return src
+	}
+	return &redactReader{src: src, lines: bufio.NewReaderSize(src, 64*1024), redaction: x}
+}
+
+type redactReader struct {
+	src       io.ReadCloser
+	lines     *bufio.Reader
+	redaction *Redaction
+	pending   []byte
+	err       error
+}
+
+func (r *redactReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		var line []byte
+		line, r.err = r.lines.ReadBytes('\n')
+		r.pending = r.redaction.Bytes(line)
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *redactReader) Close() error {
+	return r.src.Close()
+}
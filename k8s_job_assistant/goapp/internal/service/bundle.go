@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"goapp/internal/archive"
+	"goapp/internal/bundle"
+	"goapp/internal/kube"
+	"goapp/pkg/model"
+	"io"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+	"slices"
+	"strings"
+)
+
+// Bundle returns the files describing a run of a managed Job: 'run.yaml' (its history entry),
+// 'job.yaml', 'events.yaml', and '<pod>/pod.yaml' and '<pod>/<container>.log' for each pod. The
+// current run is read from the cluster, completed by what was archived (ie: the logs of the pods
+// deleted by a kill). Past runs are read from the archive. Credentials are redacted.
+func (s *jobService) Bundle(ctx context.Context, namespace, jobName, runID string) ([]bundle.File, error) {
+	job, err := s.jobManager.Get(ctx, namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	redaction, err := s.redaction(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	var files []bundle.File
+	if runID == job.Annotations[s.jobManager.AnnotationKey(kube.RunIDAnnotation)] {
+		if files, err = s.runFiles(ctx, job); err != nil {
+			return nil, err
+		}
+	} else if s.logArchive == nil {
+		return nil, &ArchiveDisabledError{}
+	}
+
+	archived, err := s.archivedFiles(ctx, namespace, jobName, runID)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range archived {
+		if !slices.ContainsFunc(files, func(f bundle.File) bool { return f.Name == file.Name }) {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil, k8serrors.NewNotFound(schema.GroupResource{Resource: "runs"}, runID)
+	}
+
+	runs, err := s.Runs(ctx, namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	if i := slices.IndexFunc(runs, func(run model.Run) bool { return run.ID == runID }); i >= 0 {
+		files = slices.Insert(files, 0, bundle.File{Name: "run.yaml", Content: yamlContent(runs[i])})
+	}
+	redactFiles(files, redaction)
+	return files, nil
+}
+
+// runFiles lists the files of the bundle of the current run of a Job, as found in the cluster.
+// The logs are only read with the content of their file. The sensitive environment variables of
+// the manifests are masked, the caller redacts the rest of the files.
+func (s *jobService) runFiles(ctx context.Context, job *batchv1.Job) ([]bundle.File, error) {
+	pods, err := s.jobManager.Pods(ctx, job.Namespace, job.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := job.DeepCopy()
+	manifest.TypeMeta = metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"}
+	manifest.ManagedFields = nil
+	delete(manifest.Annotations, corev1.LastAppliedConfigAnnotation)                         // a copy of the spec, unredacted
+	delete(manifest.Annotations, s.jobManager.AnnotationKey(kube.RunParamsRevertAnnotation)) // environment variables, unredacted
+	s.redactor.PodSpec(&manifest.Spec.Template.Spec)
+	files := []bundle.File{
+		{Name: "job.yaml", Content: yamlContent(manifest)},
+		{Name: "events.yaml", Content: func(ctx context.Context) ([]byte, error) {
+			events, err := s.jobManager.Events(ctx, job, pods)
+			if err != nil {
+				return nil, err
+			}
+			return yaml.Marshal(corev1.EventList{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "EventList"}, Items: events})
+		}},
+	}
+	for _, pod := range pods {
+		manifest := pod.DeepCopy()
+		manifest.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"}
+		manifest.ManagedFields = nil
+		s.redactor.PodSpec(&manifest.Spec)
+		files = append(files, bundle.File{Name: pod.Name + "/pod.yaml", Content: yamlContent(manifest)})
+		for _, container := range slices.Concat(pod.Spec.InitContainers, pod.Spec.Containers) {
+			if !containerStarted(&pod, container.Name) {
+				continue
+			}
+			files = append(files, bundle.File{Name: pod.Name + "/" + container.Name + ".log", Content: func(ctx context.Context) ([]byte, error) {
+				return s.containerLogs(ctx, job, pod.Name, container.Name)
+			}})
+		}
+	}
+	return files, nil
+}
+
+// archivedFiles lists the archived files of a run, none when log archival is disabled
+func (s *jobService) archivedFiles(ctx context.Context, namespace, jobName, runID string) ([]bundle.File, error) {
+	if s.logArchive == nil {
+		return nil, nil
+	}
+	prefix, err := archive.RunPrefix(namespace, jobName, runID)
+	if err != nil {
+		return nil, k8serrors.NewNotFound(schema.GroupResource{Resource: "runs"}, runID)
+	}
+	objects, err := s.logArchive.Backend.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]bundle.File, 0, len(objects))
+	for _, object := range objects {
+		files = append(files, bundle.File{Name: strings.TrimPrefix(object.Key, prefix), Content: func(ctx context.Context) ([]byte, error) {
+			content, _, err := s.logArchive.Backend.Get(ctx, object.Key)
+			if err != nil {
+				return nil, err
+			}
+			defer content.Close()
+			return io.ReadAll(content)
+		}})
+	}
+	return files, nil
+}
+
+func yamlContent(v any) func(context.Context) ([]byte, error) {
+	return func(context.Context) ([]byte, error) {
+		return yaml.Marshal(v)
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"goapp/internal/signing"
+	"goapp/pkg/model"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"strings"
+)
+
+// HookUser is the user recorded for runs triggered by inbound webhooks
+const HookUser = "webhook"
+
+// InvalidHookError is returned when an inbound webhook is rejected: bad signature or payload. A Job
+// which does not exist or can not verify webhooks is reported as a bad signature, not to tell
+// unsigned requests which Jobs exist.
+type InvalidHookError struct {
+	Err error
+}
+
+func (e *InvalidHookError) Error() string {
+	return "invalid webhook: " + e.Err.Error()
+}
+
+func (e *InvalidHookError) Unwrap() error {
+	return e.Err
+}
+
+func (s *jobService) RunFromHook(ctx context.Context, namespace, jobName string, payload []byte, verify func(secret []byte) error) (*model.QueuedRun, error) {
+	logger := logging.FromContext(ctx).With("namespace", namespace, "name", jobName)
+	job, err := s.jobManager.Get(ctx, namespace, jobName)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+	var secret []byte
+	if err == nil {
+		secret, err = s.jobManager.HookSecret(ctx, job)
+	}
+	if err != nil {
+		logger.Warn("webhook rejected, its secret is not available", "error", err)
+		return nil, &InvalidHookError{Err: signing.ErrInvalidSignature}
+	}
+	if err := verify(secret); err != nil {
+		logger.Warn("webhook rejected", "error", err)
+		return nil, &InvalidHookError{Err: err}
+	}
+
+	params, err := hookParams(payload, job.Annotations[s.jobManager.AnnotationKey(kube.HookParamsAnnotation)])
+	if err != nil {
+		return nil, &InvalidHookError{Err: err}
+	}
+	return s.Run(logging.WithUser(ctx, HookUser), namespace, jobName, kube.RunOptions{Params: params})
+}
+
+// hookParams extracts the run params from a webhook payload. Without mapping, they are read
+// from the 'params' object of the payload. With a mapping such as 'FILE=file.url,DATE=date',
+// they are read from the fields at these (dot separated) paths, missing fields are ignored.
+func hookParams(payload []byte, mapping string) (map[string]string, error) {
+	if len(strings.TrimSpace(string(payload))) == 0 {
+		return nil, nil
+	}
+	var body map[string]any
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, fmt.Errorf("payload is not a JSON object: %w", err)
+	}
+
+	if mapping == "" {
+		raw, ok := body["params"].(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		params := map[string]string{}
+		for name, value := range raw {
+			params[name] = paramValue(value)
+		}
+		return params, nil
+	}
+
+	params := map[string]string{}
+	for _, entry := range strings.Split(mapping, ",") {
+		name, path, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" || path == "" {
+			return nil, fmt.Errorf("invalid hook-params entry %q, expected PARAM=path.to.field", entry)
+		}
+		if value, found := lookup(body, strings.Split(path, ".")); found {
+			params[name] = paramValue(value)
+		}
+	}
+	return params, nil
+}
+
+func lookup(value any, path []string) (any, bool) {
+	for _, field := range path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[field]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// paramValue formats a JSON value as an environment variable value
+func paramValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
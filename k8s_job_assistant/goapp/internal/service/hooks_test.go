@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/signing"
+	"goapp/internal/store"
+	batchv1 "k8s.io/api/batch/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+// hookJobManager serves the nightly Job and its hook secret, other Jobs do not exist. Other
+// JobManager methods are not used.
+type hookJobManager struct {
+	annotationsJobManager
+}
+
+func (j *hookJobManager) Get(_ context.Context, namespace, name string) (*batchv1.Job, error) {
+	if name != "nightly" {
+		return nil, k8serrors.NewNotFound(batchv1.Resource("jobs"), name)
+	}
+	return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}, nil
+}
+
+func (j *hookJobManager) HookSecret(context.Context, *batchv1.Job) ([]byte, error) {
+	return []byte("s3cr3t"), nil
+}
+
+func TestHookParams(t *testing.T) {
+	params, err := hookParams([]byte(`{"params": {"SOURCE_FILE": "s3://vendor/export.csv", "ROWS": 1234}}`), "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"SOURCE_FILE": "s3://vendor/export.csv", "ROWS": "1234"}, params)
+
+	payload := []byte(`{"event": "file_ready", "file": {"url": "s3://vendor/export.csv"}, "date": "2025-06-02"}`)
+	params, err = hookParams(payload, "SOURCE_FILE=file.url, DELIVERY_DATE=date, CHECKSUM=file.sha256")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"SOURCE_FILE": "s3://vendor/export.csv", "DELIVERY_DATE": "2025-06-02"}, params)
+
+	params, err = hookParams(nil, "")
+	require.NoError(t, err)
+	assert.Empty(t, params)
+
+	_, err = hookParams([]byte(`not json`), "")
+	assert.Error(t, err)
+	_, err = hookParams(payload, "SOURCE_FILE")
+	assert.Error(t, err)
+}
+
+func TestHookDoesNotTellWhichJobsExist(t *testing.T) {
+	svc := NewJobService(&hookJobManager{}, nopAuditLogger{}, store.NewMemory(store.DefaultRetention), nil, nil)
+	verify := func(secret []byte) error {
+		if string(secret) != "s3cr3t" {
+			t.Errorf("verified with %q", secret)
+		}
+		return signing.ErrInvalidSignature
+	}
+
+	_, badSignature := svc.RunFromHook(context.Background(), "billing", "nightly", nil, verify)
+	_, missingJob := svc.RunFromHook(context.Background(), "billing", "missing", nil, verify)
+	var invalid *InvalidHookError
+	require.ErrorAs(t, missingJob, &invalid)
+	assert.ErrorIs(t, missingJob, signing.ErrInvalidSignature)
+	assert.Equal(t, badSignature.Error(), missingJob.Error())
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"goapp/pkg/model"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/utils/pointer"
+	"strconv"
+)
+
+// RetryFailedIndexes re-runs the failed indexes of the last run of an Indexed Job, each one in a
+// Job of its own, and returns them.
+func (s *jobService) RetryFailedIndexes(ctx context.Context, namespace, jobName string) ([]int, error) {
+	unlock, err := s.locks.share(namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ctx, span := startActionSpan(ctx, "JobService.RetryFailedIndexes", namespace, jobName)
+	ctx, logger := logging.With(ctx, "operation", "retry_indexes", "namespace", namespace, "name", jobName)
+	logger.Info("retrying failed indexes")
+
+	retried, err := s.jobManager.RetryFailedIndexes(ctx, namespace, jobName)
+	if len(retried) > 0 {
+		logger.Info("indexes retried", "indexes", kube.FormatIndexes(retried))
+	}
+	s.endAction(ctx, span, "retry_indexes", namespace, jobName, err)
+	return retried, err
+}
+
+// indexes returns the per-index status of an Indexed Job, nil for other Jobs
+func indexes(job *batchv1.Job) *model.Indexes {
+	if !kube.IsIndexed(job) {
+		return nil
+	}
+	result := &model.Indexes{
+		Completions: pointer.Int32Deref(job.Spec.Completions, 0),
+		Succeeded:   job.Status.CompletedIndexes,
+	}
+	if succeeded, err := kube.ParseIndexes(job.Status.CompletedIndexes); err == nil {
+		result.SucceededCount = len(succeeded)
+	}
+	// with a backoffLimitPerIndex, indexes fail while the run goes on
+	if job.Status.FailedIndexes != nil || hasCondition(job, batchv1.JobFailed) {
+		if failed, err := kube.FailedIndexes(job); err == nil {
+			result.Failed = kube.FormatIndexes(failed)
+			result.FailedCount = len(failed)
+		}
+	}
+	return result
+}
+
+// addIndexRetries adds to the decorated Job the retries of its failed indexes. Only failed Indexed
+// Jobs are looked up, retries being only possible for them.
+func (s *jobService) addIndexRetries(ctx context.Context, job *batchv1.Job, decoratedJob *model.DecoratedJob) {
+	if decoratedJob.Indexes == nil || !hasCondition(job, batchv1.JobFailed) {
+		return
+	}
+	retries, err := s.jobManager.IndexRetries(ctx, job.Namespace, job.Name)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to list index retries", "namespace", job.Namespace, "name", job.Name, "error", err)
+		return
+	}
+	for _, retry := range retries {
+		index, err := strconv.Atoi(retry.Labels[s.jobManager.AnnotationKey(kube.RetryIndexLabel)])
+		if err != nil {
+			continue
+		}
+		decoratedJob.Indexes.Retries = append(decoratedJob.Indexes.Retries, model.IndexRetry{
+			Index: index,
+			Job:   retry.Name,
+			State: retryState(&retry),
+		})
+	}
+}
+
+func retryState(retry *batchv1.Job) model.RunState {
+	switch {
+	case hasCondition(retry, batchv1.JobComplete):
+		return model.RunSucceeded
+	case hasCondition(retry, batchv1.JobFailed):
+		return model.RunFailed
+	case retry.Status.Active > 0:
+		return model.RunRunning
+	}
+	return model.RunPending
+}
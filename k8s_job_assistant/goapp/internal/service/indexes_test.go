@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/kube"
+	"goapp/internal/store"
+	"goapp/pkg/model"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"testing"
+)
+
+// indexedJobManager holds a failed Indexed Job, index 3 being retried
+type indexedJobManager struct {
+	kube.JobManager
+}
+
+func (j *indexedJobManager) AnnotationKey(name string) string {
+	return "job-assistant/" + name
+}
+
+func (j *indexedJobManager) Get(_ context.Context, namespace, name string) (*batchv1.Job, error) {
+	indexed := batchv1.IndexedCompletion
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       batchv1.JobSpec{CompletionMode: &indexed, Completions: pointer.Int32(8)},
+		Status: batchv1.JobStatus{
+			CompletedIndexes: "0-2,4-6",
+			Conditions:       []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+		},
+	}, nil
+}
+
+func (j *indexedJobManager) IndexRetries(_ context.Context, _, name string) ([]batchv1.Job, error) {
+	return []batchv1.Job{{
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-retry-3", Labels: map[string]string{"job-assistant/retry-index": "3"}},
+		Status:     batchv1.JobStatus{Active: 1},
+	}}, nil
+}
+
+func TestStatusOfIndexedJob(t *testing.T) {
+	svc := NewJobService(&indexedJobManager{}, nopAuditLogger{}, store.NewMemory(store.DefaultRetention), nil, nil)
+
+	job, err := svc.Status(context.Background(), "billing", "shards")
+	require.NoError(t, err)
+	assert.Equal(t, &model.Indexes{
+		Completions:    8,
+		Succeeded:      "0-2,4-6",
+		SucceededCount: 6,
+		Failed:         "3,7",
+		FailedCount:    2,
+		Retries:        []model.IndexRetry{{Index: 3, Job: "shards-retry-3", State: model.RunRunning}},
+	}, job.Indexes)
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+)
+
+// JobBusyError is returned when an operation can not start because another one holds the Job,
+// ie: running a Job while it is being restarted.
+type JobBusyError struct {
+	Namespace string
+	JobName   string
+	// Operation is the one in progress
+	Operation string
+}
+
+func (e *JobBusyError) Error() string {
+	return fmt.Sprintf("job %s/%s is busy, %s in progress", e.Namespace, e.JobName, e.Operation)
+}
+
+// jobLocks keeps the operations of a Job from interleaving. Runs and kills share the lock of
+// their Job, a restart holds it alone so no run or kill gets between its kill and its run.
+// Locks are never waited for: the operation fails with JobBusyError instead.
+type jobLocks struct {
+	mu sync.Mutex
+	// locks of each Job ('<namespace>/<name>'), kept once created
+	locks map[string]*sync.RWMutex
+}
+
+func newJobLocks() *jobLocks {
+	return &jobLocks{locks: map[string]*sync.RWMutex{}}
+}
+
+func (l *jobLocks) get(namespace, jobName string) *sync.RWMutex {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := namespace + "/" + jobName
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &sync.RWMutex{}
+		l.locks[key] = lock
+	}
+	return lock
+}
+
+// share locks a Job for a run or a kill, unless it is being restarted
+func (l *jobLocks) share(namespace, jobName string) (unlock func(), err error) {
+	lock := l.get(namespace, jobName)
+	if !lock.TryRLock() {
+		return nil, &JobBusyError{Namespace: namespace, JobName: jobName, Operation: "restart"}
+	}
+	return lock.RUnlock, nil
+}
+
+// exclusive locks a Job for a restart, unless a run, kill or restart is in progress
+func (l *jobLocks) exclusive(namespace, jobName string) (unlock func(), err error) {
+	lock := l.get(namespace, jobName)
+	if !lock.TryLock() {
+		return nil, &JobBusyError{Namespace: namespace, JobName: jobName, Operation: "another operation"}
+	}
+	return lock.Unlock, nil
+}
@@ -1,27 +1,91 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"goapp/internal/audit"
+	"goapp/internal/bundle"
 	"goapp/internal/kube"
-	"goapp/internal/model"
+	"goapp/internal/logging"
+	"goapp/internal/metrics"
+	"goapp/internal/redact"
+	"goapp/internal/store"
+	"goapp/internal/tracing"
+	"goapp/pkg/model"
+	"io"
+	batchv1 "k8s.io/api/batch/v1"
+	"sync"
+	"time"
 )
 
+var tracer = tracing.Tracer("service")
+
 type JobService interface {
-	ListDecoratedJobs() ([]model.DecoratedJob, error)
-	Run(namespace, jobName string) error
-	Kill(namespace, jobName string) error
+	ListDecoratedJobs(ctx context.Context) ([]model.DecoratedJob, error)
+	Status(ctx context.Context, namespace, jobName string) (*model.DecoratedJob, error)
+	Runs(ctx context.Context, namespace, jobName string) ([]model.Run, error)
+	// CheckParams fails with kube.InvalidRunOptionsError when params can not be set on the runs of a Job
+	CheckParams(ctx context.Context, namespace, jobName string, params map[string]string) error
+	Logs(ctx context.Context, namespace, jobName string, opts kube.LogOptions) (io.ReadCloser, error)
+	// ArchivedLogs lists the archived logs of a run, see LogArchive
+	ArchivedLogs(ctx context.Context, namespace, jobName, runID string) ([]model.ArchivedLog, error)
+	// ArchivedLog returns the archived logs of a container of a run and their size
+	ArchivedLog(ctx context.Context, namespace, jobName, runID, pod, container string) (io.ReadCloser, int64, error)
+	// Bundle returns the files describing a run: its logs, manifests and events, see bundle.Write
+	Bundle(ctx context.Context, namespace, jobName, runID string) ([]bundle.File, error)
+	// Run runs a Job. When the Job is running and its queue annotation allows it, the run is
+	// queued instead of failing with JobAlreadyRunningError, and returned.
+	Run(ctx context.Context, namespace, jobName string, opts kube.RunOptions) (*model.QueuedRun, error)
+	// RunFromHook runs a Job on an inbound webhook, once verify accepted the secret of the Job
+	RunFromHook(ctx context.Context, namespace, jobName string, payload []byte, verify func(secret []byte) error) (*model.QueuedRun, error)
+	Kill(ctx context.Context, namespace, jobName string, opts kube.KillOptions) error
+	// Restart kills the current run of a Job then runs it again with the same params and overrides, failing
+	// with JobBusyError while another run or kill of the Job is in progress
+	Restart(ctx context.Context, namespace, jobName string, opts kube.KillOptions) error
+	// RetryFailedIndexes re-runs the failed indexes of the last run of an Indexed Job, and returns them
+	RetryFailedIndexes(ctx context.Context, namespace, jobName string) ([]int, error)
+	// WatchFinishedRuns records the runs in the run history and reports the finished ones to onFinished
+	// while leading tells this replica is the leader
+	WatchFinishedRuns(ctx context.Context, leading func() bool, onFinished func(context.Context, FinishedRun)) (hasSynced func() bool, err error)
+	Queue(ctx context.Context) []model.QueuedRun
+	CancelQueuedRun(ctx context.Context, namespace, jobName, id string) error
+	// ProcessQueue starts the queued runs as their Job finishes, until ctx is done
+	ProcessQueue(ctx context.Context, interval time.Duration)
+	// Watchdog kills the runs lasting longer than the max duration of their Job, until ctx is done
+	Watchdog(ctx context.Context, interval time.Duration, warnRatio float64)
 }
 
 type jobService struct {
-	jobManager kube.JobManager
+	jobManager  kube.JobManager
+	auditLogger audit.Logger
+	store       store.Store
+	logArchive  *LogArchive
+	redactor    *redact.Redactor
+	queue       *runQueue
+	locks       *jobLocks
+	// restartPoll is how often Restart retries the run while the killed Job is seen running
+	restartPoll time.Duration
+	now         func() time.Time
+	// recorded is the last run ID and state recorded in the store per Job, see recordRun
+	recorded sync.Map
 }
 
-func NewJobService(j kube.JobManager) JobService {
-	return &jobService{jobManager: j}
+// NewJobService returns a JobService keeping the history of the runs in st, and their logs in
+// logArchive unless it is nil.
+func NewJobService(j kube.JobManager, a audit.Logger, st store.Store, logArchive *LogArchive, redactor *redact.Redactor) JobService {
+	return &jobService{jobManager: j, auditLogger: a, store: st, logArchive: logArchive, redactor: redactor, queue: newRunQueue(), locks: newJobLocks(), restartPoll: time.Second, now: time.Now}
 }
 
-func (s *jobService) ListDecoratedJobs() ([]model.DecoratedJob, error) {
-	jobs, err := s.jobManager.List()
+func (s *jobService) ListDecoratedJobs(ctx context.Context) ([]model.DecoratedJob, error) {
+	ctx, span := tracer.Start(ctx, "JobService.ListDecoratedJobs")
+	defer span.End()
+
+	jobs, err := s.jobManager.List(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -29,43 +93,230 @@ func (s *jobService) ListDecoratedJobs() ([]model.DecoratedJob, error) {
 	// Transform into decorated format
 	result := make([]model.DecoratedJob, 0, len(jobs))
 	for _, job := range jobs {
-		decoratedJob := model.DecoratedJob{
-			Namespace: job.Namespace,
-			Name:      job.Name,
+		decoratedJob := s.decorate(job)
+		s.addIndexRetries(ctx, &job, &decoratedJob)
+		result = append(result, decoratedJob)
+	}
+	return result, nil
+}
+
+// Status returns the decorated status of a single managed Job.
+func (s *jobService) Status(ctx context.Context, namespace, jobName string) (*model.DecoratedJob, error) {
+	job, err := s.jobManager.Get(ctx, namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	decoratedJob := s.decorate(*job)
+	s.addIndexRetries(ctx, job, &decoratedJob)
+	return &decoratedJob, nil
+}
+
+func (s *jobService) CheckParams(ctx context.Context, namespace, jobName string, params map[string]string) error {
+	job, err := s.jobManager.Get(ctx, namespace, jobName)
+	if err != nil {
+		return err
+	}
+	return s.jobManager.CheckParams(job, params)
+}
+
+// Runs returns the runs of a managed Job, most recent first: the current run followed by the
+// previous ones kept in the store.
+func (s *jobService) Runs(ctx context.Context, namespace, jobName string) ([]model.Run, error) {
+	job, err := s.jobManager.Get(ctx, namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.store.Runs(ctx, namespace, jobName)
+	if err != nil {
+		logging.FromContext(ctx).Warn("run history unavailable, only the current run is returned", "namespace", namespace, "name", jobName, "error", err)
+	}
+	runs := []model.Run{}
+	if job.Status.StartTime != nil || job.Annotations[s.jobManager.AnnotationKey(kube.RunIDAnnotation)] != "" {
+		runs = append(runs, s.currentRun(job))
+	}
+	for _, run := range history {
+		if len(runs) > 0 && run.ID == runs[0].ID {
+			runs[0].ExitCodes = run.ExitCodes // the pods may be gone
+			continue
 		}
+		runs = append(runs, run)
+	}
+	for i := range runs {
+		runs[i].Params = redactParams(s.redactor, runs[i].Params)
+	}
+	return runs, nil
+}
 
-		if job.Status.Active > 0 {
-			decoratedJob.LastStatus = model.LastStatus{
-				Type:    "Running",
-				Message: fmt.Sprintf("%d pod(s)", job.Status.Active),
-			}
-		} else {
-			if len(job.Status.Conditions) > 0 {
-				latest := &job.Status.Conditions[0]
-				for i := range job.Status.Conditions {
-					if job.Status.Conditions[i].LastTransitionTime.After(latest.LastTransitionTime.Time) {
-						latest = &job.Status.Conditions[i]
-					}
-				}
-				decoratedJob.LastStatus = model.LastStatus{
-					Type:    string(latest.Type),
-					Message: latest.Message,
+// Logs streams the logs of the current run of a managed Job, credentials redacted.
+func (s *jobService) Logs(ctx context.Context, namespace, jobName string, opts kube.LogOptions) (io.ReadCloser, error) {
+	job, err := s.jobManager.Get(ctx, namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	redaction, err := s.redaction(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := s.jobManager.Logs(ctx, namespace, jobName, opts)
+	if err != nil {
+		return nil, err
+	}
+	return redaction.Reader(logs), nil
+}
+
+// decorate transforms a Job into its decorated format
+func (s *jobService) decorate(job batchv1.Job) model.DecoratedJob {
+	decoratedJob := model.DecoratedJob{
+		Namespace: job.Namespace,
+		Name:      job.Name,
+		RunID:     job.Annotations[s.jobManager.AnnotationKey(kube.RunIDAnnotation)],
+	}
+
+	if job.Status.Active > 0 {
+		decoratedJob.LastStatus = model.LastStatus{
+			Type:    "Running",
+			Message: fmt.Sprintf("%d pod(s)", job.Status.Active),
+		}
+	} else {
+		if len(job.Status.Conditions) > 0 {
+			latest := &job.Status.Conditions[0]
+			for i := range job.Status.Conditions {
+				if job.Status.Conditions[i].LastTransitionTime.After(latest.LastTransitionTime.Time) {
+					latest = &job.Status.Conditions[i]
 				}
 			}
+			decoratedJob.LastStatus = model.LastStatus{
+				Type:    string(latest.Type),
+				Message: latest.Message,
+			}
 		}
+	}
 
-		decoratedJob.LastSuccessfullyRunStarTime = job.Status.StartTime
-		decoratedJob.LastSuccessfullyRunCompletionTime = job.Status.CompletionTime
+	// the status of a killed Job only tells it is suspended, show why it was killed instead
+	if reason := job.Annotations[s.jobManager.AnnotationKey(kube.KillReasonAnnotation)]; reason != "" && job.Status.Active == 0 {
+		decoratedJob.LastStatus.Message = reason
+	}
 
-		result = append(result, decoratedJob)
+	// kept for compatibility, they are the times of the current run whatever its outcome
+	decoratedJob.LastSuccessfullyRunStarTime = job.Status.StartTime
+	decoratedJob.LastSuccessfullyRunCompletionTime = job.Status.CompletionTime
+
+	decoratedJob.State = s.runState(&job)
+	decoratedJob.CurrentRun = s.runStatus(&job, decoratedJob.State)
+	decoratedJob.LastSuccess = s.lastSuccess(&job)
+	decoratedJob.Indexes = indexes(&job)
+
+	return decoratedJob
+}
+
+// currentRun describes the run the Job currently holds
+func (s *jobService) currentRun(job *batchv1.Job) model.Run {
+	run := model.Run{
+		ID:             job.Annotations[s.jobManager.AnnotationKey(kube.RunIDAnnotation)],
+		TriggeredBy:    job.Annotations[s.jobManager.AnnotationKey(kube.TriggeredByAnnotation)],
+		State:          s.runState(job),
+		StartTime:      job.Status.StartTime,
+		CompletionTime: job.Status.CompletionTime,
+		KilledAt:       s.killedAt(job),
 	}
-	return result, nil
+	if run.KilledAt != nil {
+		run.KillReason = job.Annotations[s.jobManager.AnnotationKey(kube.KillReasonAnnotation)]
+	}
+	if failed := jobCondition(job, batchv1.JobFailed); failed != nil {
+		run.FailureReason = failed.Reason
+	}
+	// invalid annotations are ignored, the run history is informative
+	if params := job.Annotations[s.jobManager.AnnotationKey(kube.RunParamsAnnotation)]; params != "" {
+		_ = json.Unmarshal([]byte(params), &run.Params)
+	}
+	if overrides := job.Annotations[s.jobManager.AnnotationKey(kube.RunOverridesAnnotation)]; overrides != "" {
+		_ = json.Unmarshal([]byte(overrides), &run.Overrides)
+	}
+	return run
 }
 
-func (s *jobService) Run(namespace, jobName string) error {
-	return s.jobManager.Run(namespace, jobName)
+func (s *jobService) Run(ctx context.Context, namespace, jobName string, opts kube.RunOptions) (*model.QueuedRun, error) {
+	unlock, err := s.locks.share(namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ctx, span := startActionSpan(ctx, "JobService.Run", namespace, jobName)
+	ctx, logger := logging.With(ctx, "operation", "run", "namespace", namespace, "name", jobName)
+	logger.Info("running job", "params", len(opts.Params), "overrides", opts.Overrides)
+
+	opts.BeforeRecreate = s.archiveFinishedRun
+	err = s.jobManager.Run(ctx, namespace, jobName, opts)
+	var alreadyRunning *kube.JobAlreadyRunningError
+	if errors.As(err, &alreadyRunning) {
+		queued, queues, queueErr := s.enqueue(ctx, namespace, jobName, opts)
+		if queues || queueErr != nil {
+			s.endAction(ctx, span, "queue", namespace, jobName, queueErr)
+			return queued, queueErr
+		}
+	}
+	s.endAction(ctx, span, "run", namespace, jobName, err)
+	return nil, err
+}
+
+func (s *jobService) Kill(ctx context.Context, namespace, jobName string, opts kube.KillOptions) error {
+	unlock, err := s.locks.share(namespace, jobName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if opts.Reason != "" {
+		ctx = audit.WithReason(ctx, opts.Reason)
+	}
+	ctx, span := startActionSpan(ctx, "JobService.Kill", namespace, jobName)
+	ctx, logger := logging.With(ctx, "operation", "kill", "namespace", namespace, "name", jobName)
+	logger.Info("killing job", "mode", opts.Mode, "reason", opts.Reason)
+
+	s.archiveBeforeKill(ctx, namespace, jobName)
+	err = s.jobManager.Kill(ctx, namespace, jobName, opts)
+	s.endAction(ctx, span, "kill", namespace, jobName, err)
+	return err
 }
 
-func (s *jobService) Kill(namespace, jobName string) error {
-	return s.jobManager.Kill(namespace, jobName)
+func startActionSpan(ctx context.Context, name, namespace, jobName string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("k8s.namespace.name", namespace),
+		attribute.String("k8s.job.name", jobName),
+	))
+}
+
+// endAction ends the span of a run/kill action, counts, logs and audits its outcome.
+func (s *jobService) endAction(ctx context.Context, span trace.Span, action, namespace, jobName string, err error) {
+	outcome := actionOutcome(err)
+	metrics.IncJobAction(action, outcome)
+	s.auditLogger.Record(ctx, action, namespace, jobName, outcome, err)
+	if err != nil {
+		logging.FromContext(ctx).Warn(action+" failed", "outcome", outcome, "error", err)
+	} else {
+		logging.FromContext(ctx).Info(action+" succeeded", "outcome", outcome)
+	}
+	span.SetAttributes(attribute.String("kja.action.outcome", outcome))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// actionOutcome maps the result of a run/kill action to its metrics outcome label.
+func actionOutcome(err error) string {
+	var alreadyRunning *kube.JobAlreadyRunningError
+	var queueFull *RunQueueFullError
+	switch {
+	case err == nil:
+		return metrics.OutcomeSuccess
+	case errors.As(err, &alreadyRunning):
+		return metrics.OutcomeAlreadyRunning
+	case errors.As(err, &queueFull):
+		return metrics.OutcomeQueueFull
+	default:
+		return metrics.OutcomeError
+	}
 }
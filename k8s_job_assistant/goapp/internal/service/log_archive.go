@@ -0,0 +1,204 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"goapp/internal/archive"
+	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"goapp/pkg/model"
+	"io"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"strings"
+	"time"
+)
+
+const (
+	// archiveTimeout bounds the capture of a run
+	archiveTimeout = 2 * time.Minute
+	// defaultMaxLogBytes is how much of the logs of each container are bundled when log
+	// archival is disabled
+	defaultMaxLogBytes = 10 << 20
+)
+
+// LogArchive keeps the logs of each container of the pods of the runs in a Backend, up to
+// MaxBytes per container.
+type LogArchive struct {
+	Backend  archive.Backend
+	MaxBytes int64
+}
+
+// ArchiveDisabledError is returned when reading archived logs while log archival is not configured
+type ArchiveDisabledError struct{}
+
+func (e *ArchiveDisabledError) Error() string {
+	return "log archival is disabled, see the -archive flag"
+}
+
+// ArchivedLogs lists the archived logs of a run of a managed Job
+func (s *jobService) ArchivedLogs(ctx context.Context, namespace, jobName, runID string) ([]model.ArchivedLog, error) {
+	if s.logArchive == nil {
+		return nil, &ArchiveDisabledError{}
+	}
+	if _, err := s.jobManager.Get(ctx, namespace, jobName); err != nil {
+		return nil, err
+	}
+	prefix, err := archive.RunPrefix(namespace, jobName, runID)
+	if err != nil {
+		return nil, notArchived(runID)
+	}
+	objects, err := s.logArchive.Backend.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]model.ArchivedLog, 0, len(objects))
+	for _, object := range objects {
+		if pod, container, ok := archive.ParseLogKey(strings.TrimPrefix(object.Key, prefix)); ok {
+			logs = append(logs, model.ArchivedLog{Pod: pod, Container: container, Size: object.Size})
+		}
+	}
+	return logs, nil
+}
+
+// ArchivedLog returns the archived logs of a container of a run and their size, -1 when they are
+// redacted. The caller must close them.
+func (s *jobService) ArchivedLog(ctx context.Context, namespace, jobName, runID, pod, container string) (io.ReadCloser, int64, error) {
+	if s.logArchive == nil {
+		return nil, 0, &ArchiveDisabledError{}
+	}
+	job, err := s.jobManager.Get(ctx, namespace, jobName)
+	if err != nil {
+		return nil, 0, err
+	}
+	// redacted when archived, again with the current Secrets and patterns
+	redaction, err := s.redaction(ctx, job)
+	if err != nil {
+		return nil, 0, err
+	}
+	key, err := archive.LogKey(namespace, jobName, runID, pod, container)
+	if err != nil {
+		return nil, 0, notArchived(runID + "/" + pod + "/" + container)
+	}
+	logs, size, err := s.logArchive.Backend.Get(ctx, key)
+	if errors.Is(err, archive.ErrNotFound) {
+		return nil, 0, notArchived(runID + "/" + pod + "/" + container)
+	}
+	if err != nil || redaction == nil {
+		return logs, size, err
+	}
+	return redaction.Reader(logs), -1, nil
+}
+
+func notArchived(name string) error {
+	return k8serrors.NewNotFound(schema.GroupResource{Resource: "archivedlogs"}, name)
+}
+
+// archiveBeforeKill captures the current run of a Job before the kill deletes its pods. Runs
+// capture the previous run through kube.RunOptions.BeforeRecreate, only when the Job is re-created.
+func (s *jobService) archiveBeforeKill(ctx context.Context, namespace, jobName string) {
+	if s.logArchive == nil {
+		return
+	}
+	job, err := s.jobManager.Get(ctx, namespace, jobName)
+	if err != nil {
+		return // the action fails the same way
+	}
+	s.archiveRun(ctx, job)
+}
+
+// archiveFinishedRun captures the finished run of a Job, unless it is archived already
+func (s *jobService) archiveFinishedRun(ctx context.Context, job *batchv1.Job) {
+	if s.logArchive == nil {
+		return
+	}
+	prefix, err := archive.RunPrefix(job.Namespace, job.Name, job.Annotations[s.jobManager.AnnotationKey(kube.RunIDAnnotation)])
+	if err != nil {
+		return // not started through KJA
+	}
+	if archived, err := s.logArchive.Backend.List(ctx, prefix); err == nil && len(archived) > 0 {
+		return
+	}
+	s.archiveRun(ctx, job)
+}
+
+// archiveRun captures the logs of each container of the pods of the current run of a Job, along
+// with the manifests and events of its bundle, credentials redacted, replacing those captured
+// before. Failures are logged, they never fail the action on the Job.
+func (s *jobService) archiveRun(ctx context.Context, job *batchv1.Job) {
+	runID := job.Annotations[s.jobManager.AnnotationKey(kube.RunIDAnnotation)]
+	prefix, err := archive.RunPrefix(job.Namespace, job.Name, runID)
+	if s.logArchive == nil || err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), archiveTimeout)
+	defer cancel()
+	logger := logging.FromContext(ctx).With("namespace", job.Namespace, "name", job.Name, "run_id", runID)
+
+	files, err := s.runFiles(ctx, job)
+	if err != nil {
+		logger.Warn("failed to list the pods to archive their logs", "error", err)
+		return
+	}
+	redaction, err := s.redaction(ctx, job)
+	if err != nil {
+		logger.Warn("archiving the run with only the patterns and inline values redacted", "error", err)
+	}
+	redactFiles(files, redaction)
+	archived := 0
+	for _, file := range files {
+		content, err := file.Content(ctx)
+		if err == nil {
+			err = s.logArchive.Backend.Put(ctx, prefix+file.Name, bytes.NewReader(content), int64(len(content)))
+		}
+		if err != nil {
+			logger.Warn("failed to archive run file", "file", file.Name, "error", err)
+			continue
+		}
+		archived++
+	}
+	logger.Info("run archived", "files", archived)
+}
+
+// containerLogs reads the logs of a container of a pod of a Job, up to maxLogBytes. Lines start
+// with their timestamp, for the archived logs to be filtered by time.
+func (s *jobService) containerLogs(ctx context.Context, job *batchv1.Job, pod, container string) ([]byte, error) {
+	limit := s.maxLogBytes()
+	stream, err := s.jobManager.Logs(ctx, job.Namespace, job.Name, kube.LogOptions{Pod: pod, Container: container, LimitBytes: &limit, Timestamps: true})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	var logs bytes.Buffer
+	if _, err := io.Copy(&logs, io.LimitReader(stream, limit)); err != nil {
+		return nil, err
+	}
+	if int64(logs.Len()) >= limit {
+		fmt.Fprintf(&logs, "\n[truncated by KJA after %d bytes]\n", limit)
+	}
+	return logs.Bytes(), nil
+}
+
+// maxLogBytes is how much of the logs of each container are archived or bundled
+func (s *jobService) maxLogBytes() int64 {
+	if s.logArchive != nil && s.logArchive.MaxBytes > 0 {
+		return s.logArchive.MaxBytes
+	}
+	return defaultMaxLogBytes
+}
+
+// containerStarted tells whether a container of a pod ran, so it may have logs
+func containerStarted(pod *corev1.Pod, container string) bool {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if status.Name == container {
+				return status.State.Waiting == nil || status.RestartCount > 0
+			}
+		}
+	}
+	return false
+}
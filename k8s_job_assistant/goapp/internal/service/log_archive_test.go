@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/archive"
+	"goapp/internal/bundle"
+	"goapp/internal/kube"
+	"goapp/internal/redact"
+	"goapp/internal/store"
+	"goapp/pkg/model"
+	"io"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+)
+
+// archiveJobManager serves a finished run whose pod ran an init container and a main container,
+// the sidecar never started. Other JobManager methods are not used.
+type archiveJobManager struct {
+	kube.JobManager
+	logs   map[string]string
+	killed bool
+	// running rejects the runs, the Job is not re-created
+	running   bool
+	podsCalls int
+}
+
+func (j *archiveJobManager) AnnotationKey(name string) string {
+	return "job-assistant/" + name
+}
+
+func (j *archiveJobManager) Get(_ context.Context, namespace, name string) (*batchv1.Job, error) {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name,
+			Annotations: map[string]string{
+				"job-assistant/" + kube.RunIDAnnotation: "20250602-030000-4f2a9c",
+				corev1.LastAppliedConfigAnnotation:      `{"spec":{"template":{"spec":{"containers":[{"env":[{"name":"DB_PASSWORD","value":"pg-admin-pw"}]}]}}}}`,
+			}},
+		Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main",
+			Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "pg-admin-pw"}}}}}}},
+		Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}},
+	}, nil
+}
+
+func (j *archiveJobManager) Pods(context.Context, string, string) ([]corev1.Pod, error) {
+	j.podsCalls++
+	terminated := corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}
+	return []corev1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly-x2x4d"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init-db"}},
+			Containers:     []corev1.Container{{Name: "main"}, {Name: "sidecar"}},
+		},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{{Name: "init-db", State: terminated}},
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "main", State: terminated},
+				{Name: "sidecar", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}}}},
+		},
+	}}, nil
+}
+
+func (j *archiveJobManager) Logs(_ context.Context, _, _ string, opts kube.LogOptions) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(j.logs[opts.Pod+"/"+opts.Container])), nil
+}
+
+func (j *archiveJobManager) Events(context.Context, *batchv1.Job, []corev1.Pod) ([]corev1.Event, error) {
+	return []corev1.Event{{ObjectMeta: metav1.ObjectMeta{Name: "nightly-x2x4d.1"}, Reason: "BackOff"}}, nil
+}
+
+func (j *archiveJobManager) SecretValues(context.Context, *batchv1.Job) ([]string, error) {
+	return []string{"s3cr3t-from-secret"}, nil
+}
+
+func (j *archiveJobManager) Kill(context.Context, string, string, kube.KillOptions) error {
+	j.killed = true
+	return nil
+}
+
+func (j *archiveJobManager) Run(ctx context.Context, namespace, name string, opts kube.RunOptions) error {
+	if j.running {
+		return &kube.JobAlreadyRunningError{}
+	}
+	job, _ := j.Get(ctx, namespace, name)
+	opts.BeforeRecreate(ctx, job)
+	j.killed = true // the pods of the previous run are deleted along with the Job
+	return nil
+}
+
+func TestArchivedLogs(t *testing.T) {
+	backend, err := archive.NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+	jobManager := &archiveJobManager{logs: map[string]string{
+		"nightly-x2x4d/init-db": "migrated\n",
+		"nightly-x2x4d/main":    "exporting 1200 invoices\n",
+	}}
+	svc := NewJobService(jobManager, nopAuditLogger{}, store.NewMemory(store.DefaultRetention),
+		&LogArchive{Backend: backend, MaxBytes: 16}, nil).(*jobService)
+	ctx := context.Background()
+
+	require.NoError(t, svc.Kill(ctx, "billing", "nightly", kube.KillOptions{}))
+	assert.True(t, jobManager.killed)
+
+	logs, err := svc.ArchivedLogs(ctx, "billing", "nightly", "20250602-030000-4f2a9c")
+	require.NoError(t, err)
+	require.Len(t, logs, 2, "containers which never started have no logs, manifests are not listed")
+	assert.Equal(t, "init-db", logs[0].Container)
+	assert.Equal(t, int64(9), logs[0].Size)
+
+	stream, _, err := svc.ArchivedLog(ctx, "billing", "nightly", "20250602-030000-4f2a9c", "nightly-x2x4d", "main")
+	require.NoError(t, err)
+	defer stream.Close()
+	content, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, "exporting 1200 i\n[truncated by KJA after 16 bytes]\n", string(content))
+
+	_, _, err = svc.ArchivedLog(ctx, "billing", "nightly", "20250602-030000-4f2a9c", "nightly-x2x4d", "sidecar")
+	assert.True(t, k8serrors.IsNotFound(err))
+	_, _, err = svc.ArchivedLog(ctx, "billing", "nightly", "20250602-030000-4f2a9c", "..", "main")
+	assert.True(t, k8serrors.IsNotFound(err))
+}
+
+func TestRunsAreArchivedBeforeDeletion(t *testing.T) {
+	ctx := context.Background()
+	for name, deleteRun := range map[string]func(svc *jobService){
+		"watchdog": func(svc *jobService) { svc.killRunaway(ctx, "billing", "nightly", "too long") },
+		"run": func(svc *jobService) {
+			_, err := svc.Run(ctx, "billing", "nightly", kube.RunOptions{})
+			require.NoError(t, err)
+		},
+		"queue": func(svc *jobService) {
+			queued := model.QueuedRun{ID: "q1", Namespace: "billing", Name: "nightly", TriggeredBy: "alice"}
+			svc.queue.runs["billing/nightly"] = []*model.QueuedRun{&queued}
+			svc.startQueuedRun(ctx, queued)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			backend, err := archive.NewFilesystem(t.TempDir())
+			require.NoError(t, err)
+			jobManager := &archiveJobManager{logs: map[string]string{"nightly-x2x4d/main": "exported\n"}}
+			svc := NewJobService(jobManager, nopAuditLogger{}, store.NewMemory(store.DefaultRetention),
+				&LogArchive{Backend: backend}, nil).(*jobService)
+
+			deleteRun(svc)
+			assert.True(t, jobManager.killed)
+			logs, err := svc.ArchivedLogs(ctx, "billing", "nightly", "20250602-030000-4f2a9c")
+			require.NoError(t, err)
+			assert.NotEmpty(t, logs)
+		})
+	}
+}
+
+func TestRejectedRunsAreNotArchived(t *testing.T) {
+	backend, err := archive.NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+	jobManager := &archiveJobManager{running: true}
+	svc := NewJobService(jobManager, nopAuditLogger{}, store.NewMemory(store.DefaultRetention),
+		&LogArchive{Backend: backend}, nil).(*jobService)
+	ctx := context.Background()
+
+	_, err = svc.Run(ctx, "billing", "nightly", kube.RunOptions{NoQueue: true})
+	var alreadyRunning *kube.JobAlreadyRunningError
+	require.ErrorAs(t, err, &alreadyRunning)
+	queued := model.QueuedRun{ID: "q1", Namespace: "billing", Name: "nightly", TriggeredBy: "alice"}
+	svc.queue.runs["billing/nightly"] = []*model.QueuedRun{&queued}
+	svc.startQueuedRun(ctx, queued)
+
+	assert.Zero(t, jobManager.podsCalls, "the job is not re-created, its pods are left alone")
+}
+
+func TestArchivedLogsDisabled(t *testing.T) {
+	svc := NewJobService(&archiveJobManager{}, nopAuditLogger{}, store.NewMemory(store.DefaultRetention), nil, nil)
+
+	_, err := svc.ArchivedLogs(context.Background(), "billing", "nightly", "20250602-030000-4f2a9c")
+	var disabled *ArchiveDisabledError
+	assert.ErrorAs(t, err, &disabled)
+}
+
+func TestBundle(t *testing.T) {
+	backend, err := archive.NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+	jobManager := &archiveJobManager{logs: map[string]string{"nightly-x2x4d/main": "exporting with s3cr3t-from-secret\n"}}
+	redactor, err := redact.New(redact.DefaultPatterns, redact.DefaultNames)
+	require.NoError(t, err)
+	svc := NewJobService(jobManager, nopAuditLogger{}, store.NewMemory(store.DefaultRetention),
+		&LogArchive{Backend: backend, MaxBytes: 1024}, redactor).(*jobService)
+	ctx := context.Background()
+	require.NoError(t, backend.Put(ctx, "billing/nightly/20250602-030000-4f2a9c/nightly-4rt2p/main.log", strings.NewReader("killed\n"), 7))
+	require.NoError(t, backend.Put(ctx, "billing/nightly/20250601-030000-77e0b1/job.yaml", strings.NewReader("kind: Job\n"), 10))
+	require.NoError(t, backend.Put(ctx, "billing/nightly/20250601-030000-77e0b1/nightly-8bq2w/main.log", strings.NewReader("password=hunter2\n"), 17))
+
+	contents := func(files []bundle.File) map[string]string {
+		contents := map[string]string{}
+		for _, file := range files {
+			content, err := file.Content(ctx)
+			require.NoError(t, err)
+			contents[file.Name] = string(content)
+		}
+		return contents
+	}
+
+	files, err := svc.Bundle(ctx, "billing", "nightly", "20250602-030000-4f2a9c")
+	require.NoError(t, err)
+	current := contents(files)
+	assert.Equal(t, "exporting with [REDACTED]\n", current["nightly-x2x4d/main.log"], "the current run is read from the cluster, redacted")
+	assert.Equal(t, "killed\n", current["nightly-4rt2p/main.log"], "completed by the archive")
+	assert.Contains(t, current["job.yaml"], "kind: Job")
+	assert.NotContains(t, current["job.yaml"], "pg-admin-pw", "inline values of sensitive environment variables are masked")
+	assert.NotContains(t, current["job.yaml"], "last-applied-configuration")
+	assert.Contains(t, current["nightly-x2x4d/pod.yaml"], "kind: Pod")
+	assert.Contains(t, current["events.yaml"], "reason: BackOff")
+	assert.Contains(t, current["run.yaml"], "id: 20250602-030000-4f2a9c")
+	assert.NotContains(t, current, "nightly-x2x4d/sidecar.log", "containers which never started have no logs")
+
+	files, err = svc.Bundle(ctx, "billing", "nightly", "20250601-030000-77e0b1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"job.yaml": "kind: Job\n", "nightly-8bq2w/main.log": "password=[REDACTED]\n"}, contents(files),
+		"past runs are read from the archive, redacted")
+
+	_, err = svc.Bundle(ctx, "billing", "nightly", "20250531-030000-c4d2e8")
+	assert.True(t, k8serrors.IsNotFound(err))
+}
@@ -0,0 +1,471 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"goapp/pkg/model"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pipelineRunsKept is the number of runs kept in memory for each pipeline
+const pipelineRunsKept = 20
+
+type PipelineService interface {
+	ListPipelines(ctx context.Context) ([]model.Pipeline, error)
+	// StartPipeline starts a run of the pipeline in the background, fails with
+	// PipelineAlreadyRunningError if the previous run is not finished
+	StartPipeline(ctx context.Context, namespace, name string) (*model.PipelineRun, error)
+	// PipelineRuns returns the runs of the pipeline, most recent first
+	PipelineRuns(ctx context.Context, namespace, name string) ([]model.PipelineRun, error)
+	PipelineRun(ctx context.Context, namespace, name, runID string) (*model.PipelineRun, error)
+	// CancelPipelineRun stops starting steps and kills the running ones
+	CancelPipelineRun(ctx context.Context, namespace, name, runID string) error
+}
+
+// PipelineAlreadyRunningError is returned when starting a pipeline whose previous run is not finished.
+type PipelineAlreadyRunningError struct {
+	RunID string
+}
+
+func (e *PipelineAlreadyRunningError) Error() string {
+	return fmt.Sprintf("pipeline is already running (run %s), wait for completion or cancel it", e.RunID)
+}
+
+// InvalidPipelineError is returned when the definition of a pipeline can not be run.
+type InvalidPipelineError struct {
+	Reason string
+}
+
+func (e *InvalidPipelineError) Error() string {
+	return "invalid pipeline: " + e.Reason
+}
+
+type pipelineService struct {
+	pipelineManager kube.PipelineManager
+	jobSvc          JobService
+	pollInterval    time.Duration
+
+	mu sync.Mutex
+	// runs of each pipeline ('<namespace>/<name>'), oldest first
+	runs map[string][]*pipelineRun
+}
+
+// pipelineRun is a run being executed, its fields are guarded by pipelineService.mu
+type pipelineRun struct {
+	run      model.PipelineRun
+	pipeline model.Pipeline
+	cancel   context.CancelFunc
+}
+
+// NewPipelineService returns a PipelineService running steps through jobSvc, checking the
+// state of the running steps every pollInterval. Pipeline runs are kept in memory.
+func NewPipelineService(p kube.PipelineManager, jobSvc JobService, pollInterval time.Duration) PipelineService {
+	return &pipelineService{pipelineManager: p, jobSvc: jobSvc, pollInterval: pollInterval, runs: map[string][]*pipelineRun{}}
+}
+
+func (s *pipelineService) ListPipelines(ctx context.Context) ([]model.Pipeline, error) {
+	configMaps, err := s.pipelineManager.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pipelines := make([]model.Pipeline, 0, len(configMaps))
+	for i := range configMaps {
+		pipeline, err := parsePipeline(&configMaps[i])
+		if err != nil {
+			logging.FromContext(ctx).Warn("ignoring invalid pipeline", "namespace", configMaps[i].Namespace, "name", configMaps[i].Name, "error", err)
+			continue
+		}
+		pipelines = append(pipelines, *pipeline)
+	}
+	return pipelines, nil
+}
+
+func (s *pipelineService) StartPipeline(ctx context.Context, namespace, name string) (*model.PipelineRun, error) {
+	configMap, err := s.pipelineManager.Get(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := parsePipeline(configMap)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := namespace + "/" + name
+	if runs := s.runs[key]; len(runs) > 0 && !runs[len(runs)-1].run.State.Finished() {
+		return nil, &PipelineAlreadyRunningError{RunID: runs[len(runs)-1].run.ID}
+	}
+
+	now := metav1.Now()
+	pr := &pipelineRun{pipeline: *pipeline, run: model.PipelineRun{
+		ID:          kube.NewRunID(),
+		Namespace:   namespace,
+		Name:        name,
+		TriggeredBy: logging.UserFromContext(ctx),
+		State:       model.PipelineRunning,
+		StartTime:   &now,
+	}}
+	for _, step := range pipeline.Steps {
+		pr.run.Steps = append(pr.run.Steps, model.PipelineStepRun{Job: step.Job, State: model.PipelinePending})
+	}
+	s.runs[key] = append(s.runs[key], pr)
+	if len(s.runs[key]) > pipelineRunsKept {
+		s.runs[key] = s.runs[key][1:]
+	}
+
+	// the run outlives the request starting it, it keeps its user and logger
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	pr.cancel = cancel
+	runCtx, logger := logging.With(runCtx, "pipeline", key, "pipeline_run_id", pr.run.ID)
+	logger.Info("starting pipeline", "steps", len(pipeline.Steps))
+	go s.execute(runCtx, pr)
+
+	run := pr.snapshot()
+	return &run, nil
+}
+
+func (s *pipelineService) PipelineRuns(_ context.Context, namespace, name string) ([]model.PipelineRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := s.runs[namespace+"/"+name]
+	result := make([]model.PipelineRun, 0, len(runs))
+	for i := len(runs) - 1; i >= 0; i-- {
+		result = append(result, runs[i].snapshot())
+	}
+	return result, nil
+}
+
+func (s *pipelineService) PipelineRun(_ context.Context, namespace, name, runID string) (*model.PipelineRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pr, err := s.find(namespace, name, runID)
+	if err != nil {
+		return nil, err
+	}
+	run := pr.snapshot()
+	return &run, nil
+}
+
+func (s *pipelineService) CancelPipelineRun(ctx context.Context, namespace, name, runID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pr, err := s.find(namespace, name, runID)
+	if err != nil {
+		return err
+	}
+	if !pr.run.State.Finished() {
+		logging.FromContext(ctx).Info("cancelling pipeline run", "pipeline", namespace+"/"+name, "pipeline_run_id", runID)
+		pr.cancel()
+	}
+	return nil
+}
+
+// find returns a run of a pipeline, s.mu must be held
+func (s *pipelineService) find(namespace, name, runID string) (*pipelineRun, error) {
+	for _, pr := range s.runs[namespace+"/"+name] {
+		if pr.run.ID == runID {
+			return pr, nil
+		}
+	}
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Resource: "pipelineruns"}, runID)
+}
+
+// snapshot returns a copy of the run, safe to use once s.mu is released
+func (pr *pipelineRun) snapshot() model.PipelineRun {
+	run := pr.run
+	run.Steps = append([]model.PipelineStepRun(nil), pr.run.Steps...)
+	return run
+}
+
+// execute starts the steps whose predecessors are done, and follows the running ones until
+// every step is finished or the run is cancelled.
+func (s *pipelineService) execute(ctx context.Context, pr *pipelineRun) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		for _, i := range s.readySteps(pr) {
+			s.startStep(ctx, pr, i)
+		}
+		s.pollSteps(ctx, pr)
+		if s.finish(ctx, pr) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			s.cancel(ctx, pr)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readySteps returns the steps which can start, and skips the ones which never will
+func (s *pipelineService) readySteps(pr *pipelineRun) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := map[string]int{}
+	stopping := false
+	for i, step := range pr.pipeline.Steps {
+		index[step.Job] = i
+		if pr.run.Steps[i].State == model.PipelineFailed && !step.ContinueOnError {
+			stopping = true // stop on failure: no new step starts
+		}
+	}
+
+	var ready []int
+	for i, step := range pr.pipeline.Steps {
+		if pr.run.Steps[i].State != model.PipelinePending {
+			continue
+		}
+		if stopping {
+			pr.run.Steps[i].State = model.PipelineSkipped
+			continue
+		}
+		waiting := false
+		for _, after := range step.After {
+			previous := pr.run.Steps[index[after]]
+			if !previous.State.Finished() {
+				waiting = true
+			}
+		}
+		if !waiting {
+			ready = append(ready, i)
+		}
+	}
+	return ready
+}
+
+func (s *pipelineService) startStep(ctx context.Context, pr *pipelineRun, i int) {
+	job := pr.pipeline.Steps[i].Job
+	namespace, name, _ := strings.Cut(job, "/")
+	logger := logging.FromContext(ctx).With("step", job)
+
+	now := metav1.Now()
+	_, err := s.jobSvc.Run(ctx, namespace, name, kube.RunOptions{NoQueue: true})
+	var runID string
+	if err == nil {
+		runs, runsErr := s.jobSvc.Runs(ctx, namespace, name)
+		if runsErr == nil && len(runs) > 0 {
+			runID = runs[0].ID
+		}
+		err = runsErr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	step := &pr.run.Steps[i]
+	step.StartTime = &now
+	if err != nil {
+		logger.Warn("pipeline step failed to start", "error", err)
+		step.State = model.PipelineFailed
+		step.Error = err.Error()
+		step.CompletionTime = &now
+		return
+	}
+	logger.Info("pipeline step started", "run_id", runID)
+	step.State = model.PipelineRunning
+	step.RunID = runID
+}
+
+// pollSteps updates the state of the running steps from the runs of their Job
+func (s *pipelineService) pollSteps(ctx context.Context, pr *pipelineRun) {
+	s.mu.Lock()
+	running := map[int]model.PipelineStepRun{}
+	for i, step := range pr.run.Steps {
+		if step.State == model.PipelineRunning {
+			running[i] = step
+		}
+	}
+	s.mu.Unlock()
+
+	for i, step := range running {
+		namespace, name, _ := strings.Cut(step.Job, "/")
+		runs, err := s.jobSvc.Runs(ctx, namespace, name)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to get the state of a pipeline step", "step", step.Job, "error", err)
+			continue // next poll
+		}
+
+		state, reason := model.PipelineRunning, ""
+		switch {
+		case len(runs) == 0 || runs[0].ID != step.RunID:
+			state, reason = model.PipelineFailed, "the Job was run again outside of the pipeline"
+		case runs[0].State == model.RunSucceeded:
+			state = model.PipelineSucceeded
+		case runs[0].State == model.RunFailed:
+			state, reason = model.PipelineFailed, "the Job failed"
+		case runs[0].State == model.RunKilled:
+			state, reason = model.PipelineFailed, "the Job was killed"
+		}
+		if state == model.PipelineRunning {
+			continue
+		}
+
+		logging.FromContext(ctx).Info("pipeline step finished", "step", step.Job, "state", state)
+		s.mu.Lock()
+		now := metav1.Now()
+		pr.run.Steps[i].State = state
+		pr.run.Steps[i].Error = reason
+		pr.run.Steps[i].CompletionTime = &now
+		s.mu.Unlock()
+	}
+}
+
+// finish completes the run once all its steps are finished, and tells whether it did
+func (s *pipelineService) finish(ctx context.Context, pr *pipelineRun) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := model.PipelineSucceeded
+	for i, step := range pr.run.Steps {
+		switch {
+		case !step.State.Finished():
+			return false
+		case step.State == model.PipelineSkipped,
+			step.State == model.PipelineFailed && !pr.pipeline.Steps[i].ContinueOnError:
+			state = model.PipelineFailed
+		}
+	}
+	s.complete(ctx, pr, state)
+	return true
+}
+
+// cancel kills the running steps and cancels the pending ones
+func (s *pipelineService) cancel(ctx context.Context, pr *pipelineRun) {
+	// the run context is cancelled, killing must not be
+	killCtx := context.WithoutCancel(ctx)
+	s.mu.Lock()
+	var running []string
+	for i := range pr.run.Steps {
+		step := &pr.run.Steps[i]
+		if step.State == model.PipelineRunning {
+			running = append(running, step.Job)
+		}
+		if !step.State.Finished() {
+			now := metav1.Now()
+			step.State = model.PipelineCancelled
+			step.CompletionTime = &now
+		}
+	}
+	s.mu.Unlock()
+
+	for _, job := range running {
+		namespace, name, _ := strings.Cut(job, "/")
+		reason := fmt.Sprintf("pipeline %s/%s run %s cancelled", pr.run.Namespace, pr.run.Name, pr.run.ID)
+		if err := s.jobSvc.Kill(killCtx, namespace, name, kube.KillOptions{Reason: reason}); err != nil {
+			logging.FromContext(ctx).Warn("failed to kill a pipeline step", "step", job, "error", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.complete(ctx, pr, model.PipelineCancelled)
+}
+
+// complete ends the run with state, s.mu must be held
+func (s *pipelineService) complete(ctx context.Context, pr *pipelineRun, state model.PipelineState) {
+	now := metav1.Now()
+	pr.run.State = state
+	pr.run.CompletionTime = &now
+	pr.cancel()
+	logging.FromContext(ctx).Info("pipeline finished", "state", state)
+}
+
+// pipelineDefinition is the YAML stored in the pipeline ConfigMaps
+//
+//	steps:
+//	  - job: extract              # Job of the ConfigMap namespace
+//	  - job: billing/transform
+//	    after: [extract]
+//	    continueOnError: true
+type pipelineDefinition struct {
+	Steps []model.PipelineStep `json:"steps"`
+}
+
+// parsePipeline reads and validates the pipeline defined by a ConfigMap, Jobs are
+// normalized to '<namespace>/<name>'.
+func parsePipeline(configMap *corev1.ConfigMap) (*model.Pipeline, error) {
+	var definition pipelineDefinition
+	if err := yaml.UnmarshalStrict([]byte(configMap.Data[kube.PipelineDefinitionKey]), &definition); err != nil {
+		return nil, &InvalidPipelineError{Reason: fmt.Sprintf("%s: %v", kube.PipelineDefinitionKey, err)}
+	}
+	if len(definition.Steps) == 0 {
+		return nil, &InvalidPipelineError{Reason: "no steps"}
+	}
+
+	qualify := func(job string) string {
+		if strings.Contains(job, "/") {
+			return job
+		}
+		return configMap.Namespace + "/" + job
+	}
+	pipeline := &model.Pipeline{Namespace: configMap.Namespace, Name: configMap.Name}
+	known := map[string]bool{}
+	for _, step := range definition.Steps {
+		step.Job = qualify(step.Job)
+		if known[step.Job] {
+			return nil, &InvalidPipelineError{Reason: fmt.Sprintf("job %s is in several steps", step.Job)}
+		}
+		known[step.Job] = true
+		for i := range step.After {
+			step.After[i] = qualify(step.After[i])
+		}
+		pipeline.Steps = append(pipeline.Steps, step)
+	}
+	for _, step := range pipeline.Steps {
+		for _, after := range step.After {
+			if !known[after] {
+				return nil, &InvalidPipelineError{Reason: fmt.Sprintf("step %s comes after %s which is not a step", step.Job, after)}
+			}
+		}
+	}
+	if cycle := findCycle(pipeline.Steps); cycle != nil {
+		return nil, &InvalidPipelineError{Reason: "steps depend on each other: " + strings.Join(cycle, ", ")}
+	}
+	return pipeline, nil
+}
+
+// findCycle returns the steps which can never start because they depend on each other, nil if none
+func findCycle(steps []model.PipelineStep) []string {
+	remaining := map[string][]string{}
+	for _, step := range steps {
+		remaining[step.Job] = step.After
+	}
+	for progress := true; progress; {
+		progress = false
+		for job, after := range remaining {
+			ready := true
+			for _, previous := range after {
+				if _, ok := remaining[previous]; ok {
+					ready = false
+				}
+			}
+			if ready {
+				delete(remaining, job)
+				progress = true
+			}
+		}
+	}
+	if len(remaining) == 0 {
+		return nil
+	}
+	cycle := make([]string, 0, len(remaining))
+	for job := range remaining {
+		cycle = append(cycle, job)
+	}
+	sort.Strings(cycle)
+	return cycle
+}
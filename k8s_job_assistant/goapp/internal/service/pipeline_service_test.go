@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/kube"
+	"goapp/pkg/model"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakePipelineManager struct {
+	configMap *corev1.ConfigMap
+}
+
+func (p *fakePipelineManager) List(context.Context) ([]corev1.ConfigMap, error) {
+	return []corev1.ConfigMap{*p.configMap}, nil
+}
+
+func (p *fakePipelineManager) Get(context.Context, string, string) (*corev1.ConfigMap, error) {
+	return p.configMap, nil
+}
+
+// pipelineJobService runs Jobs instantly, ending in the state set in results (Succeeded by default)
+type pipelineJobService struct {
+	JobService
+	mu      sync.Mutex
+	results map[string]model.RunState
+	started []string
+	killed  []string
+	runs    map[string][]model.Run
+}
+
+func (s *pipelineJobService) Run(_ context.Context, namespace, name string, opts kube.RunOptions) (*model.QueuedRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := namespace + "/" + name
+	state, ok := s.results[job]
+	if !ok {
+		state = model.RunSucceeded
+	}
+	s.started = append(s.started, job)
+	run := model.Run{ID: fmt.Sprintf("run-%d", len(s.started)), State: state}
+	s.runs[job] = append([]model.Run{run}, s.runs[job]...)
+	if !opts.NoQueue {
+		return nil, errors.New("pipeline steps must not be queued")
+	}
+	return nil, nil
+}
+
+func (s *pipelineJobService) Runs(_ context.Context, namespace, name string) ([]model.Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runs[namespace+"/"+name], nil
+}
+
+func (s *pipelineJobService) Kill(_ context.Context, namespace, name string, _ kube.KillOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.killed = append(s.killed, namespace+"/"+name)
+	return nil
+}
+
+func pipelineConfigMap(definition string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "billing", Name: "monthly-close"},
+		Data:       map[string]string{kube.PipelineDefinitionKey: definition},
+	}
+}
+
+func TestParsePipeline(t *testing.T) {
+	pipeline, err := parsePipeline(pipelineConfigMap(`
+steps:
+  - job: extract
+  - job: reporting/invoices
+    after: [extract]
+    continueOnError: true
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []model.PipelineStep{
+		{Job: "billing/extract"},
+		{Job: "reporting/invoices", After: []string{"billing/extract"}, ContinueOnError: true},
+	}, pipeline.Steps)
+
+	for definition, reason := range map[string]string{
+		"steps: []":                           "no steps",
+		"steps: [{job: a}, {job: billing/a}]": "job billing/a is in several steps",
+		"steps: [{job: a, after: [b]}]":       "step billing/a comes after billing/b which is not a step",
+		"steps: [{job: a, after: [b]}, {job: b, after: [a]}, {job: c}]": "steps depend on each other: billing/a, billing/b",
+	} {
+		_, err := parsePipeline(pipelineConfigMap(definition))
+		var invalid *InvalidPipelineError
+		require.ErrorAs(t, err, &invalid, definition)
+		assert.Equal(t, reason, invalid.Reason)
+	}
+	_, err = parsePipeline(pipelineConfigMap("steps: [{job: a, unknown: true}]"))
+	assert.Error(t, err)
+}
+
+func runPipeline(t *testing.T, definition string, results map[string]model.RunState) (*model.PipelineRun, *pipelineJobService) {
+	jobSvc := &pipelineJobService{results: results, runs: map[string][]model.Run{}}
+	svc := NewPipelineService(&fakePipelineManager{configMap: pipelineConfigMap(definition)}, jobSvc, time.Millisecond)
+	run, err := svc.StartPipeline(context.Background(), "billing", "monthly-close")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		run, err = svc.PipelineRun(context.Background(), "billing", "monthly-close", run.ID)
+		return err == nil && run.State.Finished()
+	}, 5*time.Second, time.Millisecond)
+	return run, jobSvc
+}
+
+func TestPipelineRunsStepsInOrder(t *testing.T) {
+	run, jobSvc := runPipeline(t, `
+steps:
+  - job: d
+    after: [b, c]
+  - job: b
+    after: [a]
+  - job: c
+    after: [a]
+  - job: a
+`, nil)
+
+	assert.Equal(t, model.PipelineSucceeded, run.State)
+	require.Len(t, jobSvc.started, 4)
+	assert.Equal(t, "billing/a", jobSvc.started[0])
+	assert.ElementsMatch(t, []string{"billing/b", "billing/c"}, jobSvc.started[1:3])
+	assert.Equal(t, "billing/d", jobSvc.started[3])
+	for _, step := range run.Steps {
+		assert.Equal(t, model.PipelineSucceeded, step.State, step.Job)
+		assert.NotEmpty(t, step.RunID, step.Job)
+	}
+}
+
+func TestPipelineStopsOnFailure(t *testing.T) {
+	definition := `
+steps:
+  - job: a
+  - job: b
+    after: [a]
+  - job: c
+    after: [b]
+`
+	run, jobSvc := runPipeline(t, definition, map[string]model.RunState{"billing/a": model.RunFailed})
+	assert.Equal(t, model.PipelineFailed, run.State)
+	assert.Equal(t, []string{"billing/a"}, jobSvc.started)
+	assert.Equal(t, []model.PipelineState{model.PipelineFailed, model.PipelineSkipped, model.PipelineSkipped},
+		[]model.PipelineState{run.Steps[0].State, run.Steps[1].State, run.Steps[2].State})
+
+	run, jobSvc = runPipeline(t, definition+"    continueOnError: true\n", map[string]model.RunState{"billing/c": model.RunFailed})
+	assert.Equal(t, model.PipelineSucceeded, run.State, "the failed step continues on error")
+	assert.Len(t, jobSvc.started, 3)
+}
+
+func TestPipelineAlreadyRunning(t *testing.T) {
+	jobSvc := &pipelineJobService{results: map[string]model.RunState{"billing/a": model.RunRunning}, runs: map[string][]model.Run{}}
+	svc := NewPipelineService(&fakePipelineManager{configMap: pipelineConfigMap("steps: [{job: a}, {job: b, after: [a]}]")}, jobSvc, time.Millisecond)
+	run, err := svc.StartPipeline(context.Background(), "billing", "monthly-close")
+	require.NoError(t, err)
+
+	_, err = svc.StartPipeline(context.Background(), "billing", "monthly-close")
+	var alreadyRunning *PipelineAlreadyRunningError
+	require.ErrorAs(t, err, &alreadyRunning)
+	assert.Equal(t, run.ID, alreadyRunning.RunID)
+
+	require.NoError(t, svc.CancelPipelineRun(context.Background(), "billing", "monthly-close", run.ID))
+	require.Eventually(t, func() bool {
+		run, err = svc.PipelineRun(context.Background(), "billing", "monthly-close", run.ID)
+		return err == nil && run.State.Finished()
+	}, 5*time.Second, time.Millisecond)
+	assert.Equal(t, model.PipelineCancelled, run.State)
+	assert.Equal(t, model.PipelineCancelled, run.Steps[1].State)
+	assert.Equal(t, []string{"billing/a"}, jobSvc.started, "cancelled steps are not started")
+	assert.Equal(t, []string{"billing/a"}, jobSvc.killed)
+
+	_, err = svc.PipelineRun(context.Background(), "billing", "monthly-close", "unknown")
+	assert.Error(t, err)
+}
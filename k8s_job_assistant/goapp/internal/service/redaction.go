@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"goapp/internal/bundle"
+	"goapp/internal/redact"
+	batchv1 "k8s.io/api/batch/v1"
+	"maps"
+)
+
+// redaction returns what to mask in the logs and manifests of a Job: its Secrets, the inline
+// values of its sensitive environment variables and the patterns of the redactor. When the
+// Secrets cannot be read the Redaction still masks the rest, it is returned with the error.
+func (s *jobService) redaction(ctx context.Context, job *batchv1.Job) (*redact.Redaction, error) {
+	if s.redactor == nil {
+		return nil, nil
+	}
+	values := s.redactor.InlineValues(&job.Spec.Template.Spec)
+	secretValues, err := s.jobManager.SecretValues(ctx, job)
+	if err != nil {
+		err = fmt.Errorf("failed to read the Secrets of job %s/%s to redact them: %w", job.Namespace, job.Name, err)
+	}
+	return s.redactor.Redaction(append(values, secretValues...)), err
+}
+
+// redactFiles masks the content of files
+func redactFiles(files []bundle.File, redaction *redact.Redaction) {
+	for i, file := range files {
+		files[i].Content = func(ctx context.Context) ([]byte, error) {
+			content, err := file.Content(ctx)
+			return redaction.Bytes(content), err
+		}
+	}
+}
+
+// redactParams returns params with the values of the sensitive ones masked, params being set
+// as environment variables. params are copied when some are masked.
+func redactParams(redactor *redact.Redactor, params map[string]string) map[string]string {
+	var redacted map[string]string
+	for name := range params {
+		if redactor.SensitiveName(name) {
+			if redacted == nil {
+				redacted = maps.Clone(params)
+			}
+			redacted[name] = redact.Mask
+		}
+	}
+	if redacted == nil {
+		return params
+	}
+	return redacted
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"goapp/internal/audit"
+	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"time"
+)
+
+// restartTimeout bounds how long Restart retries the run once the kill is done, while
+// Kubernetes has not seen the Job suspended yet and still reports it running
+const restartTimeout = 30 * time.Second
+
+// Restart kills the current run of a Job and runs it again with the same params and overrides, as
+// a single operation: the Job is held (see jobLocks) so no other run, kill or queued run gets in between.
+func (s *jobService) Restart(ctx context.Context, namespace, jobName string, opts kube.KillOptions) error {
+	unlock, err := s.locks.exclusive(namespace, jobName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if opts.Reason != "" {
+		ctx = audit.WithReason(ctx, opts.Reason)
+	}
+	operationID := uuid.NewString()
+	ctx, span := startActionSpan(ctx, "JobService.Restart", namespace, jobName)
+	span.SetAttributes(attribute.String("kja.operation.id", operationID))
+	ctx, logger := logging.With(ctx, "operation", "restart", "operation_id", operationID, "namespace", namespace, "name", jobName)
+
+	err = s.restart(ctx, namespace, jobName, opts)
+	s.endAction(ctx, span, "restart", namespace, jobName, err)
+	if err != nil {
+		logger.Warn("the job may be left killed", "error", err)
+	}
+	return err
+}
+
+func (s *jobService) restart(ctx context.Context, namespace, jobName string, opts kube.KillOptions) error {
+	job, err := s.jobManager.Get(ctx, namespace, jobName)
+	if err != nil {
+		return err
+	}
+	params := map[string]string{}
+	if applied := job.Annotations[s.jobManager.AnnotationKey(kube.RunParamsAnnotation)]; applied != "" {
+		if err := json.Unmarshal([]byte(applied), &params); err != nil {
+			return fmt.Errorf("invalid %s annotation: %w", s.jobManager.AnnotationKey(kube.RunParamsAnnotation), err)
+		}
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Info("restarting job", "mode", opts.Mode, "params", len(params))
+	s.archiveBeforeKill(ctx, namespace, jobName)
+	if err := s.jobManager.Kill(ctx, namespace, jobName, opts); err != nil {
+		return fmt.Errorf("kill failed: %w", err)
+	}
+
+	// the kill is done: the run must happen even if the caller goes away
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), restartTimeout)
+	defer cancel()
+	for attempts := 1; ; attempts++ {
+		err := s.jobManager.Run(ctx, namespace, jobName, kube.RunOptions{Params: params, KeepOverrides: true, NoQueue: true})
+		var alreadyRunning *kube.JobAlreadyRunningError
+		if !errors.As(err, &alreadyRunning) {
+			if err != nil {
+				return fmt.Errorf("run failed: %w", err)
+			}
+			logger.Info("job restarted", "attempts", attempts)
+			return nil
+		}
+		// the pods are gone but the Job status does not tell it is suspended yet
+		logger.Debug("job not seen suspended yet, retrying the run", "attempts", attempts)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("run failed: %w", err)
+		case <-time.After(s.restartPoll):
+		}
+	}
+}
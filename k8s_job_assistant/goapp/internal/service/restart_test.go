@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/kube"
+	"goapp/internal/store"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+// restartJobManager reports the killed Job running for a few runs, as Kubernetes does until it
+// sees the Job suspended. Other JobManager methods are not used.
+type restartJobManager struct {
+	kube.JobManager
+	// staleRuns is how many runs fail with JobAlreadyRunningError after the kill
+	staleRuns int
+	// onKill is called while killing, the Job being held by the restart
+	onKill func()
+	killed kube.KillOptions
+	runs   []kube.RunOptions
+}
+
+func (j *restartJobManager) AnnotationKey(name string) string {
+	return "job-assistant/" + name
+}
+
+func (j *restartJobManager) Get(_ context.Context, namespace, name string) (*batchv1.Job, error) {
+	return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name,
+		Annotations: map[string]string{"job-assistant/run-params": `{"DATE":"2025-06-01"}`}}}, nil
+}
+
+func (j *restartJobManager) Kill(_ context.Context, _, _ string, opts kube.KillOptions) error {
+	j.killed = opts
+	if j.onKill != nil {
+		j.onKill()
+	}
+	return nil
+}
+
+func (j *restartJobManager) Run(_ context.Context, _, _ string, opts kube.RunOptions) error {
+	j.runs = append(j.runs, opts)
+	if len(j.runs) <= j.staleRuns {
+		return &kube.JobAlreadyRunningError{}
+	}
+	return nil
+}
+
+func TestRestart(t *testing.T) {
+	jobManager := &restartJobManager{staleRuns: 2}
+	svc := NewJobService(jobManager, nopAuditLogger{}, store.NewMemory(store.DefaultRetention), nil, nil).(*jobService)
+	svc.restartPoll = time.Millisecond
+	var busyErr error
+	jobManager.onKill = func() {
+		_, busyErr = svc.Run(context.Background(), "billing", "nightly", kube.RunOptions{})
+	}
+
+	err := svc.Restart(context.Background(), "billing", "nightly", kube.KillOptions{Mode: kube.KillForce})
+	require.NoError(t, err)
+	assert.Equal(t, kube.KillForce, jobManager.killed.Mode)
+	require.Len(t, jobManager.runs, 3, "the run is retried until the killed Job is not seen running")
+	assert.Equal(t, kube.RunOptions{Params: map[string]string{"DATE": "2025-06-01"}, KeepOverrides: true, NoQueue: true}, jobManager.runs[2],
+		"params and overrides of the killed run are kept")
+
+	var busy *JobBusyError
+	assert.ErrorAs(t, busyErr, &busy, "runs are rejected while the Job is restarted")
+
+	_, err = svc.Run(context.Background(), "billing", "nightly", kube.RunOptions{})
+	assert.NoError(t, err, "the Job is released once restarted")
+}
+
+func TestRestartIsRejectedWhileKilling(t *testing.T) {
+	jobManager := &restartJobManager{}
+	svc := NewJobService(jobManager, nopAuditLogger{}, store.NewMemory(store.DefaultRetention), nil, nil).(*jobService)
+	var restartErr error
+	jobManager.onKill = func() {
+		restartErr = svc.Restart(context.Background(), "billing", "nightly", kube.KillOptions{})
+	}
+
+	require.NoError(t, svc.Kill(context.Background(), "billing", "nightly", kube.KillOptions{}))
+	var busy *JobBusyError
+	assert.ErrorAs(t, restartErr, &busy)
+	assert.Empty(t, jobManager.runs)
+}
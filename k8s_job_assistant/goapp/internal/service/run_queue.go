@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"goapp/pkg/model"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RunQueueFullError is returned when running a Job which is running and already has as many
+// queued runs as its queue annotation allows.
+type RunQueueFullError struct {
+	Namespace string
+	JobName   string
+	Depth     int
+}
+
+func (e *RunQueueFullError) Error() string {
+	return fmt.Sprintf("job %s/%s is running and its queue is full (%d runs)", e.Namespace, e.JobName, e.Depth)
+}
+
+// runQueue holds the runs requested while their Job was running, in memory
+type runQueue struct {
+	mu sync.Mutex
+	// runs of each Job ('<namespace>/<name>'), next first
+	runs map[string][]*model.QueuedRun
+	// starting are the IDs of the queued runs being started, they can not be cancelled anymore
+	starting map[string]bool
+	// wake is signalled when a run finishes, for queued runs to start right away
+	wake chan struct{}
+}
+
+func newRunQueue() *runQueue {
+	return &runQueue{runs: map[string][]*model.QueuedRun{}, starting: map[string]bool{}, wake: make(chan struct{}, 1)}
+}
+
+func (q *runQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default: // already signalled
+	}
+}
+
+// enqueue queues a run of a running Job when its queue annotation allows it, and tells whether it did.
+// A run with the same params and overrides as an already queued one is not queued twice, the queued one is returned.
+func (s *jobService) enqueue(ctx context.Context, namespace, jobName string, opts kube.RunOptions) (*model.QueuedRun, bool, error) {
+	if opts.NoQueue {
+		return nil, false, nil
+	}
+	job, err := s.jobManager.Get(ctx, namespace, jobName)
+	if err != nil {
+		return nil, false, err
+	}
+	annotation := s.jobManager.AnnotationKey(kube.QueueAnnotation)
+	value, ok := job.Annotations[annotation]
+	if !ok {
+		return nil, false, nil
+	}
+	depth, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || depth <= 0 {
+		logging.FromContext(ctx).Warn("ignoring invalid queue annotation, expected a positive number of runs", "annotation", annotation, "value", value)
+		return nil, false, nil
+	}
+
+	s.queue.mu.Lock()
+	defer s.queue.mu.Unlock()
+	key := namespace + "/" + jobName
+	for i, queued := range s.queue.runs[key] {
+		if maps.Equal(queued.Params, opts.Params) && reflect.DeepEqual(queued.Overrides, opts.Overrides) {
+			logging.FromContext(ctx).Info("run already queued", "queued_run_id", queued.ID)
+			return queuedAt(queued, i), true, nil
+		}
+	}
+	if len(s.queue.runs[key]) >= depth {
+		return nil, true, &RunQueueFullError{Namespace: namespace, JobName: jobName, Depth: depth}
+	}
+
+	now := metav1.Now()
+	queued := &model.QueuedRun{
+		ID:          kube.NewRunID(),
+		Namespace:   namespace,
+		Name:        jobName,
+		TriggeredBy: logging.UserFromContext(ctx),
+		Params:      opts.Params,
+		Overrides:   opts.Overrides,
+		QueuedAt:    &now,
+	}
+	s.queue.runs[key] = append(s.queue.runs[key], queued)
+	logging.FromContext(ctx).Info("run queued", "queued_run_id", queued.ID, "position", len(s.queue.runs[key]))
+	return queuedAt(queued, len(s.queue.runs[key])-1), true, nil
+}
+
+// queuedAt returns a copy of a queued run at index i of its queue
+func queuedAt(queued *model.QueuedRun, i int) *model.QueuedRun {
+	run := *queued
+	run.Position = i + 1
+	return &run
+}
+
+// Queue returns the queued runs of all the Jobs, sorted by Job then position.
+func (s *jobService) Queue(_ context.Context) []model.QueuedRun {
+	s.queue.mu.Lock()
+	defer s.queue.mu.Unlock()
+	keys := make([]string, 0, len(s.queue.runs))
+	for key := range s.queue.runs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := []model.QueuedRun{}
+	for _, key := range keys {
+		for i, queued := range s.queue.runs[key] {
+			run := *queuedAt(queued, i)
+			run.Params = redactParams(s.redactor, run.Params)
+			result = append(result, run)
+		}
+	}
+	return result
+}
+
+// CancelQueuedRun removes a run from the queue of its Job.
+func (s *jobService) CancelQueuedRun(ctx context.Context, namespace, jobName, id string) error {
+	ctx, logger := logging.With(ctx, "operation", "cancel_queued", "namespace", namespace, "name", jobName)
+	s.queue.mu.Lock()
+	defer s.queue.mu.Unlock()
+	key := namespace + "/" + jobName
+	if s.queue.starting[id] {
+		return &JobBusyError{Namespace: namespace, JobName: jobName, Operation: "start of queued run " + id}
+	}
+	for i, queued := range s.queue.runs[key] {
+		if queued.ID == id {
+			s.dequeue(key, i)
+			logger.Info("queued run cancelled", "queued_run_id", id)
+			s.auditLogger.Record(ctx, "cancel_queued", namespace, jobName, "success", nil)
+			return nil
+		}
+	}
+	return k8serrors.NewNotFound(schema.GroupResource{Resource: "queuedruns"}, id)
+}
+
+// dequeue removes the run at index i of a queue, s.queue.mu must be held
+func (s *jobService) dequeue(key string, i int) {
+	runs := s.queue.runs[key]
+	runs = append(runs[:i:i], runs[i+1:]...)
+	if len(runs) == 0 {
+		delete(s.queue.runs, key)
+		return
+	}
+	s.queue.runs[key] = runs
+}
+
+// ProcessQueue starts the next queued run of each Job once it is not running anymore, checking
+// every interval and each time WatchFinishedRuns sees a run finish, until ctx is done.
+func (s *jobService) ProcessQueue(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.queue.wake:
+		}
+		s.startQueued(ctx)
+	}
+}
+
+// startQueued tries to start the next run of each queue, runs stay queued while their Job is running
+func (s *jobService) startQueued(ctx context.Context) {
+	s.queue.mu.Lock()
+	next := make([]model.QueuedRun, 0, len(s.queue.runs))
+	for _, runs := range s.queue.runs {
+		next = append(next, *runs[0])
+	}
+	s.queue.mu.Unlock()
+
+	for _, queued := range next {
+		unlock, err := s.locks.share(queued.Namespace, queued.Name)
+		if err != nil {
+			continue // being restarted, next time
+		}
+		s.startQueuedRun(ctx, queued)
+		unlock()
+	}
+}
+
+// startQueuedRun starts a queued run and removes it from its queue, unless its Job is still running.
+// The run is marked as starting first, unless cancelled since startQueued listed it, so it can not
+// be cancelled once its Job is being re-created.
+func (s *jobService) startQueuedRun(ctx context.Context, queued model.QueuedRun) {
+	key := queued.Namespace + "/" + queued.Name
+	s.queue.mu.Lock()
+	if !slices.ContainsFunc(s.queue.runs[key], func(run *model.QueuedRun) bool { return run.ID == queued.ID }) {
+		s.queue.mu.Unlock()
+		return // cancelled meanwhile
+	}
+	s.queue.starting[queued.ID] = true
+	s.queue.mu.Unlock()
+
+	// the run is triggered by whoever queued it
+	runCtx := logging.WithUser(ctx, queued.TriggeredBy)
+	runCtx, span := startActionSpan(runCtx, "JobService.RunQueued", queued.Namespace, queued.Name)
+	runCtx, logger := logging.With(runCtx, "operation", "run", "namespace", queued.Namespace, "name", queued.Name, "queued_run_id", queued.ID)
+
+	logger.Info("starting queued run")
+	err := s.jobManager.Run(runCtx, queued.Namespace, queued.Name, kube.RunOptions{
+		Params: queued.Params, Overrides: queued.Overrides, BeforeRecreate: s.archiveFinishedRun,
+	})
+	var alreadyRunning *kube.JobAlreadyRunningError
+	started := !errors.As(err, &alreadyRunning)
+
+	s.queue.mu.Lock()
+	delete(s.queue.starting, queued.ID)
+	if started {
+		for i, run := range s.queue.runs[key] {
+			if run.ID == queued.ID {
+				s.dequeue(key, i)
+				break
+			}
+		}
+	}
+	s.queue.mu.Unlock()
+
+	if !started {
+		logger.Debug("job still running, the run stays queued")
+		span.End()
+		return // next time
+	}
+	s.endAction(runCtx, span, "run", queued.Namespace, queued.Name, err)
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"goapp/internal/store"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sync"
+	"testing"
+)
+
+// queueJobManager runs Jobs unless running is set, other JobManager methods are not used
+type queueJobManager struct {
+	kube.JobManager
+	mu      sync.Mutex
+	queue   string
+	running bool
+	started []string // users who started a run
+	onRun   func()   // called first by Run, when set
+}
+
+func (j *queueJobManager) AnnotationKey(name string) string {
+	return "job-assistant/" + name
+}
+
+func (j *queueJobManager) Get(_ context.Context, namespace, name string) (*batchv1.Job, error) {
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Annotations: map[string]string{}}}
+	if j.queue != "" {
+		job.Annotations[j.AnnotationKey(kube.QueueAnnotation)] = j.queue
+	}
+	return job, nil
+}
+
+func (j *queueJobManager) Run(ctx context.Context, _, _ string, _ kube.RunOptions) error {
+	if j.onRun != nil {
+		j.onRun()
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.running {
+		return &kube.JobAlreadyRunningError{}
+	}
+	j.running = true
+	j.started = append(j.started, logging.UserFromContext(ctx))
+	return nil
+}
+
+type nopAuditLogger struct{}
+
+func (nopAuditLogger) Record(context.Context, string, string, string, string, error) {}
+
+func (nopAuditLogger) Close(context.Context) error { return nil }
+
+func TestRunIsRejectedWithoutQueue(t *testing.T) {
+	svc := NewJobService(&queueJobManager{running: true}, nopAuditLogger{}, store.NewMemory(store.DefaultRetention), nil, nil)
+	queued, err := svc.Run(context.Background(), "billing", "refresh", kube.RunOptions{})
+	var alreadyRunning *kube.JobAlreadyRunningError
+	assert.ErrorAs(t, err, &alreadyRunning)
+	assert.Nil(t, queued)
+}
+
+func TestRunQueue(t *testing.T) {
+	jobManager := &queueJobManager{queue: "2", running: true}
+	svc := NewJobService(jobManager, nopAuditLogger{}, store.NewMemory(store.DefaultRetention), nil, nil).(*jobService)
+	ctx := context.Background()
+
+	first, err := svc.Run(logging.WithUser(ctx, "jane.doe"), "billing", "refresh", kube.RunOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, first)
+	assert.Equal(t, 1, first.Position)
+	assert.Equal(t, "jane.doe", first.TriggeredBy)
+
+	again, err := svc.Run(logging.WithUser(ctx, "john.doe"), "billing", "refresh", kube.RunOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID, "the same run is not queued twice")
+
+	second, err := svc.Run(ctx, "billing", "refresh", kube.RunOptions{Params: map[string]string{"DATE": "2025-06-02"}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, second.Position)
+
+	_, err = svc.Run(ctx, "billing", "refresh", kube.RunOptions{Params: map[string]string{"DATE": "2025-06-03"}})
+	var queueFull *RunQueueFullError
+	require.ErrorAs(t, err, &queueFull)
+	assert.Equal(t, 2, queueFull.Depth)
+
+	_, err = svc.Run(ctx, "billing", "refresh", kube.RunOptions{NoQueue: true})
+	var alreadyRunning *kube.JobAlreadyRunningError
+	assert.ErrorAs(t, err, &alreadyRunning)
+
+	svc.startQueued(ctx)
+	assert.Len(t, svc.Queue(ctx), 2, "runs stay queued while the Job is running")
+
+	jobManager.running = false
+	svc.startQueued(ctx)
+	assert.Equal(t, []string{"jane.doe"}, jobManager.started, "the run is triggered by whoever queued it")
+	queue := svc.Queue(ctx)
+	require.Len(t, queue, 1)
+	assert.Equal(t, second.ID, queue[0].ID)
+	assert.Equal(t, 1, queue[0].Position)
+
+	require.NoError(t, svc.CancelQueuedRun(ctx, "billing", "refresh", second.ID))
+	assert.Empty(t, svc.Queue(ctx))
+	assert.Error(t, svc.CancelQueuedRun(ctx, "billing", "refresh", second.ID))
+}
+
+func TestCancelledQueuedRunDoesNotStart(t *testing.T) {
+	jobManager := &queueJobManager{queue: "1", running: true}
+	svc := NewJobService(jobManager, nopAuditLogger{}, store.NewMemory(store.DefaultRetention), nil, nil).(*jobService)
+	ctx := context.Background()
+
+	queued, err := svc.Run(ctx, "billing", "refresh", kube.RunOptions{})
+	require.NoError(t, err)
+	listed := svc.Queue(ctx) // as startQueued does, before the run is cancelled
+	require.NoError(t, svc.CancelQueuedRun(ctx, "billing", "refresh", queued.ID))
+	jobManager.running = false
+	svc.startQueuedRun(ctx, listed[0])
+	assert.Empty(t, jobManager.started, "cancelled before it started")
+
+	jobManager.running = true
+	queued, err = svc.Run(ctx, "billing", "refresh", kube.RunOptions{})
+	require.NoError(t, err)
+	jobManager.running = false
+	var cancelErr error
+	jobManager.onRun = func() { cancelErr = svc.CancelQueuedRun(ctx, "billing", "refresh", queued.ID) }
+	svc.startQueued(ctx)
+	var busy *JobBusyError
+	assert.ErrorAs(t, cancelErr, &busy, "a starting run can not be cancelled")
+	assert.Len(t, jobManager.started, 1)
+	assert.Empty(t, svc.Queue(ctx))
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"goapp/internal/kube"
+	"goapp/pkg/model"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"time"
+)
+
+// defaultBackoffLimit is the backoffLimit of Jobs which do not set it
+const defaultBackoffLimit = 6
+
+// runState tells the state of the current run of a Job
+func (s *jobService) runState(job *batchv1.Job) model.RunState {
+	switch {
+	case hasCondition(job, batchv1.JobComplete):
+		return model.RunSucceeded
+	case hasCondition(job, batchv1.JobFailed):
+		return model.RunFailed
+	case s.killedAt(job) != nil:
+		return model.RunKilled
+	case job.Status.Active > 0:
+		return model.RunRunning
+	case job.Status.StartTime == nil && job.Annotations[s.jobManager.AnnotationKey(kube.RunIDAnnotation)] == "":
+		return model.RunNeverRun
+	case job.Spec.Suspend != nil && *job.Spec.Suspend:
+		return model.RunSuspended
+	}
+	return model.RunPending
+}
+
+// runStatus details the current run of a Job, nil when it never ran
+func (s *jobService) runStatus(job *batchv1.Job, state model.RunState) *model.RunStatus {
+	if state == model.RunNeverRun {
+		return nil
+	}
+	status := &model.RunStatus{
+		StartTime:      job.Status.StartTime,
+		CompletionTime: job.Status.CompletionTime,
+		Active:         job.Status.Active,
+		Succeeded:      job.Status.Succeeded,
+		Failed:         job.Status.Failed,
+		BackoffLimit:   pointer.Int32Deref(job.Spec.BackoffLimit, defaultBackoffLimit),
+	}
+	if failed := jobCondition(job, batchv1.JobFailed); failed != nil {
+		status.FailureReason = failed.Reason
+		status.FailureMessage = failed.Message
+	}
+	if end := s.runEnd(job, state); job.Status.StartTime != nil && end != nil {
+		status.Duration = duration(job.Status.StartTime.Time, *end)
+	}
+	return status
+}
+
+// runEnd returns when the current run ended, now while it goes on, nil when it is unknown
+func (s *jobService) runEnd(job *batchv1.Job, state model.RunState) *time.Time {
+	var end *metav1.Time
+	switch state {
+	case model.RunSucceeded:
+		end = job.Status.CompletionTime
+	case model.RunFailed:
+		if failed := jobCondition(job, batchv1.JobFailed); failed != nil {
+			end = &failed.LastTransitionTime
+		}
+	case model.RunKilled:
+		end = s.killedAt(job)
+	case model.RunSuspended:
+		if suspended := jobCondition(job, batchv1.JobSuspended); suspended != nil {
+			end = &suspended.LastTransitionTime
+		}
+	default:
+		now := s.now()
+		return &now
+	}
+	if end == nil || end.IsZero() {
+		return nil
+	}
+	return &end.Time
+}
+
+// lastSuccess returns the last successful run of a Job: the current one, or the previous one kept
+// in its annotations when the Job was run again
+func (s *jobService) lastSuccess(job *batchv1.Job) *model.SuccessfulRun {
+	if hasCondition(job, batchv1.JobComplete) && job.Status.StartTime != nil && job.Status.CompletionTime != nil {
+		return &model.SuccessfulRun{
+			StartTime:      job.Status.StartTime,
+			CompletionTime: job.Status.CompletionTime,
+			Duration:       duration(job.Status.StartTime.Time, job.Status.CompletionTime.Time),
+		}
+	}
+	start, err := time.Parse(time.RFC3339, job.Annotations[s.jobManager.AnnotationKey(kube.LastSuccessStartTimeAnnotation)])
+	if err != nil {
+		return nil
+	}
+	completion, err := time.Parse(time.RFC3339, job.Annotations[s.jobManager.AnnotationKey(kube.LastSuccessCompletionTimeAnnotation)])
+	if err != nil {
+		return nil
+	}
+	return &model.SuccessfulRun{
+		StartTime:      &metav1.Time{Time: start},
+		CompletionTime: &metav1.Time{Time: completion},
+		Duration:       duration(start, completion),
+	}
+}
+
+// killedAt returns when the current run was killed through KJA, nil if it was not
+func (s *jobService) killedAt(job *batchv1.Job) *metav1.Time {
+	killedAt, err := time.Parse(time.RFC3339, job.Annotations[s.jobManager.AnnotationKey(kube.KilledAtAnnotation)])
+	if err != nil {
+		return nil
+	}
+	return &metav1.Time{Time: killedAt}
+}
+
+func duration(start, end time.Time) *metav1.Duration {
+	return &metav1.Duration{Duration: end.Sub(start).Truncate(time.Second)}
+}
+
+func jobCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		if job.Status.Conditions[i].Type == conditionType && job.Status.Conditions[i].Status == corev1.ConditionTrue {
+			return &job.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func hasCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
+	return jobCondition(job, conditionType) != nil
+}
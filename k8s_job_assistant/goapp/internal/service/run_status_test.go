@@ -0,0 +1,104 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"goapp/internal/kube"
+	"goapp/internal/store"
+	"goapp/pkg/model"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"testing"
+	"time"
+)
+
+// annotationsJobManager only knows the annotation keys, enough to decorate Jobs
+type annotationsJobManager struct {
+	kube.JobManager
+}
+
+func (j *annotationsJobManager) AnnotationKey(name string) string {
+	return "job-assistant/" + name
+}
+
+func TestDecorateRunState(t *testing.T) {
+	start := metav1.NewTime(time.Date(2025, 6, 2, 3, 0, 0, 0, time.UTC))
+	end := metav1.NewTime(start.Add(12*time.Minute + 40*time.Second))
+	svc := NewJobService(&annotationsJobManager{}, nopAuditLogger{}, store.NewMemory(store.DefaultRetention), nil, nil).(*jobService)
+	svc.now = func() time.Time { return start.Add(5 * time.Minute) }
+
+	run := map[string]string{"job-assistant/run-id": "20250602-030000-4f2a9c"}
+	started := batchv1.JobStatus{StartTime: &start}
+	for name, test := range map[string]struct {
+		annotations map[string]string
+		suspend     bool
+		status      batchv1.JobStatus
+		state       model.RunState
+		duration    time.Duration
+	}{
+		"never run":     {suspend: true, state: model.RunNeverRun},
+		"pending":       {annotations: run, state: model.RunPending},
+		"running":       {annotations: run, status: batchv1.JobStatus{StartTime: &start, Active: 2}, state: model.RunRunning, duration: 5 * time.Minute},
+		"suspended":     {annotations: run, suspend: true, status: withCondition(started, batchv1.JobSuspended, "", end), state: model.RunSuspended, duration: 12*time.Minute + 40*time.Second},
+		"killed":        {annotations: map[string]string{"job-assistant/killed-at": "2025-06-02T03:01:00Z"}, suspend: true, status: started, state: model.RunKilled, duration: time.Minute},
+		"failed":        {annotations: run, status: withCondition(started, batchv1.JobFailed, "BackoffLimitExceeded", end), state: model.RunFailed, duration: 12*time.Minute + 40*time.Second},
+		"not scheduled": {status: withCondition(batchv1.JobStatus{}, batchv1.JobFailed, "DeadlineExceeded", end), state: model.RunFailed},
+	} {
+		job := batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "billing", Name: "nightly", Annotations: test.annotations},
+			Spec:       batchv1.JobSpec{Suspend: pointer.Bool(test.suspend)},
+			Status:     test.status,
+		}
+		decorated := svc.decorate(job)
+		assert.Equal(t, test.state, decorated.State, name)
+		if test.state == model.RunNeverRun {
+			assert.Nil(t, decorated.CurrentRun, name)
+			continue
+		}
+		assert.Equal(t, int32(6), decorated.CurrentRun.BackoffLimit, name)
+		if test.duration == 0 {
+			assert.Nil(t, decorated.CurrentRun.Duration, name)
+		} else if assert.NotNil(t, decorated.CurrentRun.Duration, name) {
+			assert.Equal(t, test.duration, decorated.CurrentRun.Duration.Duration, name)
+		}
+		if test.state == model.RunFailed {
+			assert.NotEmpty(t, decorated.CurrentRun.FailureReason, name)
+		}
+	}
+}
+
+func TestDecorateLastSuccess(t *testing.T) {
+	svc := NewJobService(&annotationsJobManager{}, nopAuditLogger{}, store.NewMemory(store.DefaultRetention), nil, nil).(*jobService)
+	start := metav1.NewTime(time.Date(2025, 6, 3, 3, 0, 0, 0, time.UTC))
+	job := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			"job-assistant/run-id":                       "20250603-030000-8b1e2d",
+			"job-assistant/last-success-start-time":      "2025-06-02T03:00:00Z",
+			"job-assistant/last-success-completion-time": "2025-06-02T03:12:40Z",
+		}},
+		Status: withCondition(batchv1.JobStatus{StartTime: &start, Failed: 7}, batchv1.JobFailed, "BackoffLimitExceeded", start),
+	}
+
+	decorated := svc.decorate(job)
+	assert.Equal(t, model.RunFailed, decorated.State)
+	assert.Equal(t, int32(7), decorated.CurrentRun.Failed)
+	if assert.NotNil(t, decorated.LastSuccess, "the previous successful run is kept") {
+		assert.Equal(t, time.Date(2025, 6, 2, 3, 12, 40, 0, time.UTC), decorated.LastSuccess.CompletionTime.UTC())
+		assert.Equal(t, 12*time.Minute+40*time.Second, decorated.LastSuccess.Duration.Duration)
+	}
+	assert.Equal(t, &start, decorated.LastSuccessfullyRunStarTime, "the deprecated fields keep their meaning")
+
+	completion := metav1.NewTime(start.Add(10 * time.Minute))
+	job.Status = withCondition(batchv1.JobStatus{StartTime: &start, CompletionTime: &completion, Succeeded: 1}, batchv1.JobComplete, "", completion)
+	decorated = svc.decorate(job)
+	assert.Equal(t, model.RunSucceeded, decorated.State)
+	assert.Equal(t, &completion, decorated.LastSuccess.CompletionTime, "the current run succeeded")
+}
+
+func withCondition(status batchv1.JobStatus, conditionType batchv1.JobConditionType, reason string, at metav1.Time) batchv1.JobStatus {
+	status.Conditions = append(status.Conditions, batchv1.JobCondition{
+		Type: conditionType, Status: corev1.ConditionTrue, Reason: reason, LastTransitionTime: at,
+	})
+	return status
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"goapp/pkg/model"
+	batchv1 "k8s.io/api/batch/v1"
+	"sync"
+	"time"
+)
+
+// recordTimeout bounds the recording of a run in the run history
+const recordTimeout = 20 * time.Second
+
+// FinishedRun is a run started through KJA which just reached a final state.
+type FinishedRun struct {
+	Job model.DecoratedJob
+	Run model.Run
+	// Notify is the value of the notify annotation of the Job, empty if not set
+	Notify string
+}
+
+// runUpdate is a change of the current run of a Job, recorded by recordRuns
+type runUpdate struct {
+	job *batchv1.Job
+	run model.Run
+	// finished tells whether the run just finished and must be reported
+	finished bool
+}
+
+// runUpdates is the FIFO of the run updates seen by the watch handler. They are recorded by
+// recordRuns so that slow Kubernetes API or store calls never hold the informer.
+type runUpdates struct {
+	mu      sync.Mutex
+	updates []runUpdate
+	wake    chan struct{}
+}
+
+func (u *runUpdates) push(update runUpdate) {
+	u.mu.Lock()
+	u.updates = append(u.updates, update)
+	u.mu.Unlock()
+	select {
+	case u.wake <- struct{}{}:
+	default: // already signalled
+	}
+}
+
+func (u *runUpdates) take() []runUpdate {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	updates := u.updates
+	u.updates = nil
+	return updates
+}
+
+// WatchFinishedRuns records the runs started through KJA in the run history as their state
+// changes, and calls onFinished each time one succeeds, fails or is killed, until ctx is done.
+// Runs are only recorded and reported while leading tells this replica is the leader, so that
+// several replicas neither write nor notify the same run twice.
+// Runs which finished while KJA was not watching are recorded on the next resync, but not reported.
+func (s *jobService) WatchFinishedRuns(ctx context.Context, leading func() bool, onFinished func(context.Context, FinishedRun)) (hasSynced func() bool, err error) {
+	updates := &runUpdates{wake: make(chan struct{}, 1)}
+	go s.recordRuns(ctx, updates, onFinished)
+	return s.jobManager.Watch(ctx, func(oldJob, newJob *batchv1.Job) {
+		run := s.currentRun(newJob)
+		if run.State.Finished() {
+			s.queue.signal() // start the next queued run, if any, the queue is kept by each replica
+		}
+		if run.ID == "" || !leading() {
+			return // not started through KJA, or recorded by the leader
+		}
+		previous := s.currentRun(oldJob)
+		updates.push(runUpdate{
+			job:      newJob,
+			run:      run,
+			finished: run.State.Finished() && (previous.ID != run.ID || !previous.State.Finished()),
+		})
+	})
+}
+
+// recordRuns records the run updates pushed by the watch handler in order, and reports the
+// runs which just finished to onFinished, until ctx is done.
+func (s *jobService) recordRuns(ctx context.Context, updates *runUpdates, onFinished func(context.Context, FinishedRun)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-updates.wake:
+		}
+		for _, update := range updates.take() {
+			s.recordRun(ctx, update.job, &update.run)
+			if !update.finished {
+				continue
+			}
+			update.run.Params = redactParams(s.redactor, update.run.Params) // notified outside of KJA
+			onFinished(ctx, FinishedRun{
+				Job:    s.decorate(*update.job),
+				Run:    update.run,
+				Notify: update.job.Annotations[s.jobManager.AnnotationKey(kube.NotifyAnnotation)],
+			})
+		}
+	}
+}
+
+// recordRun keeps the current run of a Job in the run history each time its ID or state changes,
+// adding the exit codes of its containers and archiving their logs once finished. The last recorded state is remembered
+// so resyncs only write runs which changed while KJA was not watching.
+func (s *jobService) recordRun(ctx context.Context, job *batchv1.Job, run *model.Run) {
+	if run.ID == "" {
+		return // not started through KJA
+	}
+	key := job.Namespace + "/" + job.Name
+	recorded := run.ID + "/" + string(run.State)
+	if previous, ok := s.recorded.Load(key); ok && previous == recorded {
+		return
+	}
+
+	watchCtx := ctx
+	ctx, cancel := context.WithTimeout(ctx, recordTimeout)
+	defer cancel()
+	logger := logging.FromContext(ctx).With("namespace", job.Namespace, "name", job.Name, "run_id", run.ID)
+	if run.State.Finished() {
+		exitCodes, err := s.jobManager.ExitCodes(ctx, job.Namespace, job.Name)
+		if err != nil {
+			logger.Warn("failed to get the exit codes of the run", "error", err)
+		}
+		run.ExitCodes = exitCodes
+		go s.archiveFinishedRun(watchCtx, job.DeepCopy()) // may take a while, not to delay the other Jobs
+	}
+	if err := s.store.PutRun(ctx, job.Namespace, job.Name, *run); err != nil {
+		logger.Warn("failed to record the run", "state", run.State, "error", err)
+		return
+	}
+	s.recorded.Store(key, recorded)
+}
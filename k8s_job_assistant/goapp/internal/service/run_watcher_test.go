@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/kube"
+	"goapp/internal/store"
+	"goapp/pkg/model"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+// historyJobManager hands the watch handler to the test and serves the last Job it was given.
+// Other JobManager methods are not used.
+type historyJobManager struct {
+	annotationsJobManager
+	onUpdate      kube.JobUpdateHandler
+	job           *batchv1.Job
+	exitCodeCalls int
+}
+
+func (j *historyJobManager) Watch(_ context.Context, onUpdate kube.JobUpdateHandler) (func() bool, error) {
+	j.onUpdate = onUpdate
+	return func() bool { return true }, nil
+}
+
+func (j *historyJobManager) Get(context.Context, string, string) (*batchv1.Job, error) {
+	return j.job, nil
+}
+
+func (j *historyJobManager) ExitCodes(context.Context, string, string) ([]model.ExitCode, error) {
+	j.exitCodeCalls++
+	return []model.ExitCode{{Pod: "nightly-x2x4d", Container: "main", ExitCode: 3, Reason: "Error"}}, nil
+}
+
+// waitRecorded waits until the run history recorded the given run ID and state of the Job
+func waitRecorded(t *testing.T, svc *jobService, runID string, state model.RunState) {
+	require.Eventually(t, func() bool {
+		recorded, _ := svc.recorded.Load("billing/nightly")
+		return recorded == runID+"/"+string(state)
+	}, time.Second, time.Millisecond)
+}
+
+// update hands a new version of the Job to the watch handler
+func (j *historyJobManager) update(runID string, status batchv1.JobStatus) {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "billing", Name: "nightly", Annotations: map[string]string{
+			"job-assistant/run-id":       runID,
+			"job-assistant/triggered-by": "alice",
+			"job-assistant/run-params":   `{"DATE":"2025-06-02"}`,
+		}},
+		Status: status,
+	}
+	previous := j.job
+	if previous == nil {
+		previous = job
+	}
+	j.job = job
+	j.onUpdate(previous, job)
+}
+
+func TestRunHistory(t *testing.T) {
+	jobManager := &historyJobManager{}
+	svc := NewJobService(jobManager, nopAuditLogger{}, store.NewMemory(store.DefaultRetention), nil, nil).(*jobService)
+	finished := make(chan model.Run, 10)
+	_, err := svc.WatchFinishedRuns(context.Background(), func() bool { return true }, func(_ context.Context, run FinishedRun) {
+		finished <- run.Run
+	})
+	require.NoError(t, err)
+
+	start := metav1.NewTime(time.Date(2025, 6, 2, 3, 0, 0, 0, time.UTC))
+	end := metav1.NewTime(start.Add(10 * time.Minute))
+	jobManager.update("20250602-030000-4f2a9c", batchv1.JobStatus{StartTime: &start, Active: 1})
+	jobManager.update("20250602-030000-4f2a9c", withCondition(batchv1.JobStatus{StartTime: &start}, batchv1.JobFailed, "BackoffLimitExceeded", end))
+	jobManager.update("20250602-030000-4f2a9c", jobManager.job.Status) // resync
+	jobManager.update("20250603-030000-a81c3e", batchv1.JobStatus{StartTime: &start, Active: 1})
+
+	waitRecorded(t, svc, "20250603-030000-a81c3e", model.RunRunning)
+	require.Len(t, finished, 1)
+	assert.Equal(t, int32(3), (<-finished).ExitCodes[0].ExitCode, "the exit codes are reported")
+	assert.Equal(t, 1, jobManager.exitCodeCalls, "a resync does not record the run again")
+
+	runs, err := svc.Runs(context.Background(), "billing", "nightly")
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "20250603-030000-a81c3e", runs[0].ID)
+	assert.Equal(t, model.RunRunning, runs[0].State)
+	assert.Equal(t, "20250602-030000-4f2a9c", runs[1].ID)
+	assert.Equal(t, model.RunFailed, runs[1].State)
+	assert.Equal(t, "alice", runs[1].TriggeredBy)
+	assert.Equal(t, "BackoffLimitExceeded", runs[1].FailureReason)
+	assert.Equal(t, map[string]string{"DATE": "2025-06-02"}, runs[1].Params)
+	assert.Equal(t, "Error", runs[1].ExitCodes[0].Reason)
+}
+
+func TestFollowersDoNotRecordRuns(t *testing.T) {
+	jobManager := &historyJobManager{}
+	svc := NewJobService(jobManager, nopAuditLogger{}, store.NewMemory(store.DefaultRetention), nil, nil).(*jobService)
+	_, err := svc.WatchFinishedRuns(context.Background(), func() bool { return false }, func(context.Context, FinishedRun) {
+		t.Error("a follower reported a finished run")
+	})
+	require.NoError(t, err)
+
+	start := metav1.NewTime(time.Date(2025, 6, 2, 3, 0, 0, 0, time.UTC))
+	jobManager.update("20250602-030000-4f2a9c", withCondition(batchv1.JobStatus{StartTime: &start}, batchv1.JobComplete, "", start))
+	time.Sleep(10 * time.Millisecond)
+
+	runs, err := svc.store.Runs(context.Background(), "billing", "nightly")
+	require.NoError(t, err)
+	assert.Empty(t, runs, "the leader records the runs")
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goapp/internal/audit"
+	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"goapp/internal/redact"
+	"goapp/internal/store"
+	"goapp/pkg/model"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sort"
+	"time"
+)
+
+// localTimeLayouts are the accepted layouts of a schedule time given with a time zone
+var localTimeLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+type ScheduleService interface {
+	// Schedule records a run of a managed Job at a given time
+	Schedule(ctx context.Context, namespace, name string, req model.ScheduleRequest) (*model.ScheduledRun, error)
+	// Schedules returns the scheduled runs, next first
+	Schedules(ctx context.Context) ([]model.ScheduledRun, error)
+	CancelSchedule(ctx context.Context, namespace, name, id string) error
+	// FireDue runs the scheduled runs which are due every interval, until ctx is done. A single
+	// replica must fire them, see kube.RunLeaderElection.
+	FireDue(ctx context.Context, interval time.Duration)
+}
+
+// InvalidScheduleError is returned when the time of a schedule can not be used.
+type InvalidScheduleError struct {
+	Reason string
+}
+
+func (e *InvalidScheduleError) Error() string {
+	return "invalid schedule: " + e.Reason
+}
+
+type scheduleService struct {
+	store       store.Store
+	jobSvc      JobService
+	auditLogger audit.Logger
+	redactor    *redact.Redactor
+	now         func() time.Time
+}
+
+// NewScheduleService returns a ScheduleService persisting the schedules in st, and running them
+// through jobSvc. The sensitive params of the listed schedules are masked by redactor, when set.
+func NewScheduleService(st store.Store, jobSvc JobService, a audit.Logger, redactor *redact.Redactor) ScheduleService {
+	return &scheduleService{store: st, jobSvc: jobSvc, auditLogger: a, redactor: redactor, now: time.Now}
+}
+
+func (s *scheduleService) Schedule(ctx context.Context, namespace, name string, req model.ScheduleRequest) (*model.ScheduledRun, error) {
+	ctx, logger := logging.With(ctx, "operation", "schedule", "namespace", namespace, "name", name)
+	at, err := parseScheduleTime(req.At, req.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	if !at.After(s.now()) {
+		return nil, &InvalidScheduleError{Reason: fmt.Sprintf("%s is in the past", at.Format(time.RFC3339))}
+	}
+	if err := s.jobSvc.CheckParams(ctx, namespace, name, req.Params); err != nil {
+		return nil, err // only managed Jobs can be scheduled, with the params they allow
+	}
+
+	now := metav1.NewTime(s.now())
+	run := &model.ScheduledRun{
+		ID:          kube.NewRunID(),
+		Namespace:   namespace,
+		Name:        name,
+		At:          &metav1.Time{Time: at.UTC()},
+		TimeZone:    req.TimeZone,
+		Params:      req.Params,
+		ScheduledBy: logging.UserFromContext(ctx),
+		CreatedAt:   &now,
+	}
+	err = s.store.PutSchedule(ctx, *run)
+	s.auditLogger.Record(ctx, "schedule", namespace, name, actionOutcome(err), err)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("run scheduled", "schedule_id", run.ID, "at", run.At.Time)
+	return run, nil
+}
+
+func (s *scheduleService) Schedules(ctx context.Context) ([]model.ScheduledRun, error) {
+	runs, err := s.schedules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range runs {
+		runs[i].Params = redactParams(s.redactor, runs[i].Params)
+	}
+	return runs, nil
+}
+
+// schedules returns the scheduled runs, next first, with their params as given
+func (s *scheduleService) schedules(ctx context.Context) ([]model.ScheduledRun, error) {
+	stored, err := s.store.Schedules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]model.ScheduledRun, 0, len(stored))
+	for _, run := range stored {
+		if run.At == nil {
+			logging.FromContext(ctx).Warn("ignoring schedule without time", "schedule_id", run.ID)
+			continue
+		}
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].At.Before(runs[j].At) || runs[i].At.Equal(runs[j].At) && runs[i].ID < runs[j].ID
+	})
+	return runs, nil
+}
+
+func (s *scheduleService) CancelSchedule(ctx context.Context, namespace, name, id string) error {
+	ctx, logger := logging.With(ctx, "operation", "cancel_schedule", "namespace", namespace, "name", name)
+	runs, err := s.Schedules(ctx)
+	if err != nil {
+		return err
+	}
+	notFound := k8serrors.NewNotFound(schema.GroupResource{Resource: "scheduledruns"}, id)
+	for _, run := range runs {
+		if run.ID != id || run.Namespace != namespace || run.Name != name {
+			continue
+		}
+		deleted, err := s.store.DeleteSchedule(ctx, id)
+		if err == nil && !deleted {
+			err = notFound // fired meanwhile
+		}
+		s.auditLogger.Record(ctx, "cancel_schedule", namespace, name, actionOutcome(err), err)
+		if err == nil {
+			logger.Info("scheduled run cancelled", "schedule_id", id)
+		}
+		return err
+	}
+	return notFound
+}
+
+func (s *scheduleService) FireDue(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.fireDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fireDue runs the scheduled runs whose time has come. Schedules are removed before running
+// them: a run is never fired twice, even when the leadership changes meanwhile. A run whose Job
+// is busy, running or has a full queue is put back, and retried on the next call.
+func (s *scheduleService) fireDue(ctx context.Context) {
+	runs, err := s.schedules(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to list scheduled runs", "error", err)
+		return
+	}
+	now := s.now()
+	for _, run := range runs {
+		if run.At.After(now) {
+			return // sorted, the next ones are not due either
+		}
+		runCtx, logger := logging.With(logging.WithUser(ctx, run.ScheduledBy), "schedule_id", run.ID, "namespace", run.Namespace, "name", run.Name)
+		deleted, err := s.store.DeleteSchedule(runCtx, run.ID)
+		if err != nil || !deleted {
+			logger.Warn("skipping scheduled run removed meanwhile", "error", err)
+			continue
+		}
+		logger.Info("firing scheduled run", "late", now.Sub(run.At.Time).Round(time.Second), "attempts", run.Attempts)
+		_, err = s.jobSvc.Run(runCtx, run.Namespace, run.Name, kube.RunOptions{Params: run.Params})
+		if err == nil {
+			continue
+		}
+		if retryableRunError(err) {
+			run.Attempts++
+			run.LastError = err.Error()
+			if putErr := s.store.PutSchedule(runCtx, run); putErr != nil {
+				logger.Warn("failed to put back the scheduled run, it is lost", "error", putErr)
+			} else {
+				logger.Info("scheduled run postponed, its job is busy", "attempts", run.Attempts, "error", err)
+				if run.Attempts > 1 {
+					continue // audited on the first attempt only
+				}
+			}
+		} else {
+			logger.Warn("scheduled run failed to start", "error", err)
+		}
+		s.auditLogger.Record(runCtx, "fire_schedule", run.Namespace, run.Name, actionOutcome(err), err)
+	}
+}
+
+// retryableRunError tells whether a run failed because its Job was busy, and may start later
+func retryableRunError(err error) bool {
+	var busy *JobBusyError
+	var alreadyRunning *kube.JobAlreadyRunningError
+	var queueFull *RunQueueFullError
+	return errors.As(err, &busy) || errors.As(err, &alreadyRunning) || errors.As(err, &queueFull)
+}
+
+// parseScheduleTime reads an RFC3339 time, or a local time of timeZone when set
+func parseScheduleTime(value, timeZone string) (time.Time, error) {
+	if timeZone == "" {
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, &InvalidScheduleError{Reason: fmt.Sprintf("at %q must be an RFC3339 time, or a local time with timeZone", value)}
+		}
+		return at, nil
+	}
+
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return time.Time{}, &InvalidScheduleError{Reason: fmt.Sprintf("unknown time zone %q", timeZone)}
+	}
+	for _, layout := range localTimeLayouts {
+		if at, err := time.ParseInLocation(layout, value, location); err == nil {
+			return at, nil
+		}
+	}
+	return time.Time{}, &InvalidScheduleError{Reason: fmt.Sprintf("at %q must be a local time such as 2025-06-03T02:00 with timeZone", value)}
+}
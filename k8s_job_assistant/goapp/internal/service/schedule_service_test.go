@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"goapp/internal/redact"
+	"goapp/internal/store"
+	"goapp/pkg/model"
+	"testing"
+	"time"
+)
+
+// scheduleJobService allows the DATE and DB_PASSWORD params and records the runs started, other
+// JobService methods are not used
+type scheduleJobService struct {
+	JobService
+	started    []string
+	lastParams map[string]string
+	// err fails the runs when set
+	err error
+}
+
+// actionsAuditLogger records the audited actions and their outcome
+type actionsAuditLogger struct {
+	nopAuditLogger
+	actions []string
+}
+
+func (a *actionsAuditLogger) Record(_ context.Context, action, _, _, outcome string, _ error) {
+	a.actions = append(a.actions, action+" "+outcome)
+}
+
+func (s *scheduleJobService) CheckParams(_ context.Context, _, _ string, params map[string]string) error {
+	for name := range params {
+		if name != "DATE" && name != "DB_PASSWORD" {
+			return &kube.InvalidRunOptionsError{Reason: "param " + name + " is not allowed"}
+		}
+	}
+	return nil
+}
+
+func (s *scheduleJobService) Run(ctx context.Context, namespace, name string, opts kube.RunOptions) (*model.QueuedRun, error) {
+	s.started = append(s.started, logging.UserFromContext(ctx)+" "+namespace+"/"+name+" "+opts.Params["DATE"])
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.lastParams = opts.Params
+	return nil, nil
+}
+
+func TestParseScheduleTime(t *testing.T) {
+	at, err := parseScheduleTime("2025-06-03T02:00", "Europe/Paris")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC), at.UTC())
+
+	at, err = parseScheduleTime("2025-06-03T02:00:00+02:00", "")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC), at.UTC())
+
+	for _, invalid := range [][2]string{{"2025-06-03T02:00", ""}, {"tonight", "Europe/Paris"}, {"2025-06-03T02:00", "Mars/Olympus"}} {
+		_, err = parseScheduleTime(invalid[0], invalid[1])
+		var invalidSchedule *InvalidScheduleError
+		assert.ErrorAs(t, err, &invalidSchedule, invalid)
+	}
+}
+
+func TestScheduleFiresOnce(t *testing.T) {
+	jobSvc := &scheduleJobService{}
+	svc := NewScheduleService(store.NewMemory(store.DefaultRetention), jobSvc, nopAuditLogger{}, nil).(*scheduleService)
+	now := time.Date(2025, 6, 2, 18, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := logging.WithUser(context.Background(), "jane.doe")
+
+	_, err := svc.Schedule(ctx, "billing", "nightly", model.ScheduleRequest{At: "2025-06-02T12:00", TimeZone: "Europe/Paris"})
+	var invalid *InvalidScheduleError
+	require.ErrorAs(t, err, &invalid, "in the past")
+	_, err = svc.Schedule(ctx, "billing", "nightly", model.ScheduleRequest{At: "2025-06-03T02:00:00Z", Params: map[string]string{"REGION": "eu"}})
+	var invalidParams *kube.InvalidRunOptionsError
+	require.ErrorAs(t, err, &invalidParams, "params are checked when scheduling")
+
+	tonight, err := svc.Schedule(ctx, "billing", "nightly", model.ScheduleRequest{
+		At: "2025-06-03T02:00", TimeZone: "Europe/Paris", Params: map[string]string{"DATE": "2025-06-02"},
+	})
+	require.NoError(t, err)
+	later, err := svc.Schedule(ctx, "billing", "refresh", model.ScheduleRequest{At: "2025-06-03T08:00:00Z"})
+	require.NoError(t, err)
+	cancelled, err := svc.Schedule(ctx, "billing", "refresh", model.ScheduleRequest{At: "2025-06-03T09:00:00Z"})
+	require.NoError(t, err)
+
+	require.Error(t, svc.CancelSchedule(ctx, "billing", "nightly", cancelled.ID), "the Job must match")
+	require.NoError(t, svc.CancelSchedule(ctx, "billing", "refresh", cancelled.ID))
+	runs, err := svc.Schedules(ctx)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, []string{tonight.ID, later.ID}, []string{runs[0].ID, runs[1].ID})
+
+	svc.fireDue(context.Background())
+	assert.Empty(t, jobSvc.started, "not due yet")
+
+	now = time.Date(2025, 6, 3, 0, 0, 30, 0, time.UTC)
+	svc.fireDue(context.Background())
+	svc.fireDue(context.Background())
+	assert.Equal(t, []string{"jane.doe billing/nightly 2025-06-02"}, jobSvc.started, "fired once, by the user who scheduled it")
+	runs, err = svc.Schedules(ctx)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, later.ID, runs[0].ID)
+}
+
+func TestScheduledSensitiveParamsAreMasked(t *testing.T) {
+	redactor, err := redact.New(nil, []string{"*_PASSWORD"})
+	require.NoError(t, err)
+	jobSvc := &scheduleJobService{}
+	svc := NewScheduleService(store.NewMemory(store.DefaultRetention), jobSvc, nopAuditLogger{}, redactor).(*scheduleService)
+	now := time.Date(2025, 6, 2, 18, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	params := map[string]string{"DATE": "2025-06-02", "DB_PASSWORD": "pg-admin-pw"}
+	_, err = svc.Schedule(context.Background(), "billing", "nightly", model.ScheduleRequest{At: "2025-06-03T02:00:00Z", Params: params})
+	require.NoError(t, err)
+
+	runs, err := svc.Schedules(context.Background())
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, map[string]string{"DATE": "2025-06-02", "DB_PASSWORD": redact.Mask}, runs[0].Params)
+
+	now = time.Date(2025, 6, 3, 2, 0, 30, 0, time.UTC)
+	svc.fireDue(context.Background())
+	assert.Equal(t, params, jobSvc.lastParams, "the run gets the actual params")
+}
+
+func TestBusyScheduledRunIsRetried(t *testing.T) {
+	jobSvc := &scheduleJobService{err: &JobBusyError{Namespace: "billing", JobName: "nightly", Operation: "restart"}}
+	auditLogger := &actionsAuditLogger{}
+	svc := NewScheduleService(store.NewMemory(store.DefaultRetention), jobSvc, auditLogger, nil).(*scheduleService)
+	now := time.Date(2025, 6, 2, 18, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	scheduled, err := svc.Schedule(context.Background(), "billing", "nightly", model.ScheduleRequest{At: "2025-06-03T02:00:00Z"})
+	require.NoError(t, err)
+
+	now = time.Date(2025, 6, 3, 2, 0, 30, 0, time.UTC)
+	svc.fireDue(context.Background())
+	svc.fireDue(context.Background())
+	runs, err := svc.Schedules(context.Background())
+	require.NoError(t, err)
+	require.Len(t, runs, 1, "put back while the job is busy")
+	assert.Equal(t, scheduled.ID, runs[0].ID)
+	assert.Equal(t, 2, runs[0].Attempts)
+	assert.Contains(t, runs[0].LastError, "busy")
+	assert.Equal(t, []string{"schedule success", "fire_schedule error"}, auditLogger.actions, "audited once")
+
+	jobSvc.err = nil
+	svc.fireDue(context.Background())
+	assert.Len(t, jobSvc.started, 3)
+	runs, err = svc.Schedules(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, runs, "removed once started")
+
+	jobSvc.err = &kube.InvalidRunOptionsError{Reason: "param DATE is not allowed"}
+	_, err = svc.Schedule(context.Background(), "billing", "nightly", model.ScheduleRequest{At: "2025-06-03T03:00:00Z"})
+	require.NoError(t, err)
+	now = time.Date(2025, 6, 3, 3, 0, 30, 0, time.UTC)
+	svc.fireDue(context.Background())
+	runs, err = svc.Schedules(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, runs, "not retried when the run can not start")
+	assert.Equal(t, "fire_schedule error", auditLogger.actions[len(auditLogger.actions)-1])
+}
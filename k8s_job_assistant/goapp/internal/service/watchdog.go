@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"goapp/internal/audit"
+	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"goapp/internal/metrics"
+	"time"
+)
+
+// WatchdogUser is the user recorded for runs killed by the watchdog
+const WatchdogUser = "watchdog"
+
+// Watchdog kills the runs lasting longer than the max-duration annotation of their Job, checking
+// every interval until ctx is done. Runs reaching warnRatio of their max duration are reported
+// once beforehand. A single replica must run it, see kube.RunLeaderElection.
+func (s *jobService) Watchdog(ctx context.Context, interval time.Duration, warnRatio float64) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	warned := map[string]bool{}
+	for {
+		warned = s.checkRuntimes(ctx, warned, warnRatio, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkRuntimes kills the runaway runs and warns about the ones getting close. It returns the
+// runs reported so far, previously warned runs which are still running are not reported again.
+func (s *jobService) checkRuntimes(ctx context.Context, warned map[string]bool, warnRatio float64, now time.Time) map[string]bool {
+	jobs, err := s.jobManager.List(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Warn("watchdog failed to list jobs", "error", err)
+		return warned
+	}
+
+	stillWarned := map[string]bool{}
+	annotation := s.jobManager.AnnotationKey(kube.MaxDurationAnnotation)
+	for i := range jobs {
+		job := &jobs[i]
+		value, ok := job.Annotations[annotation]
+		suspended := job.Spec.Suspend != nil && *job.Spec.Suspend
+		if !ok || job.Status.Active == 0 || job.Status.StartTime == nil || suspended {
+			continue // not limited, not running, or being killed
+		}
+		logger := logging.FromContext(ctx).With("namespace", job.Namespace, "name", job.Name)
+		run := fmt.Sprintf("%s/%s", job.UID, job.Status.StartTime.UTC().Format(time.RFC3339))
+
+		maxDuration, err := time.ParseDuration(value)
+		if err != nil || maxDuration <= 0 {
+			if !warned[run] {
+				logger.Warn("ignoring invalid max duration, expected a duration such as 2h30m", "annotation", annotation, "value", value)
+			}
+			stillWarned[run] = true
+			continue
+		}
+
+		elapsed := now.Sub(job.Status.StartTime.Time).Round(time.Second)
+		switch {
+		case elapsed >= maxDuration:
+			reason := fmt.Sprintf("killed by the watchdog after %s, beyond the max duration of %s", elapsed, maxDuration)
+			s.killRunaway(ctx, job.Namespace, job.Name, reason)
+		case float64(elapsed) >= warnRatio*float64(maxDuration):
+			if !warned[run] {
+				logger.Warn("run getting close to its max duration, it will be killed beyond",
+					"elapsed", elapsed.String(), "max_duration", maxDuration.String())
+				metrics.IncWatchdogWarning(job.Namespace, job.Name)
+			}
+			stillWarned[run] = true
+		}
+	}
+	return stillWarned
+}
+
+// killRunaway kills a run on behalf of the watchdog, the reason is recorded on the Job and audited
+func (s *jobService) killRunaway(ctx context.Context, namespace, jobName, reason string) {
+	unlock, err := s.locks.share(namespace, jobName)
+	if err != nil {
+		return // being restarted, the new run is checked next time
+	}
+	defer unlock()
+
+	ctx = audit.WithReason(logging.WithUser(ctx, WatchdogUser), reason)
+	ctx, span := startActionSpan(ctx, "JobService.Kill", namespace, jobName)
+	ctx, logger := logging.With(ctx, "operation", "kill", "namespace", namespace, "name", jobName)
+	logger.Warn("killing runaway run", "reason", reason)
+
+	s.archiveBeforeKill(ctx, namespace, jobName)
+	err = s.jobManager.Kill(ctx, namespace, jobName, kube.KillOptions{Reason: reason})
+	s.endAction(ctx, span, "kill", namespace, jobName, err)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"goapp/internal/store"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"testing"
+	"time"
+)
+
+// watchdogJobManager lists fixed Jobs and records the kills, other JobManager methods are not used
+type watchdogJobManager struct {
+	kube.JobManager
+	jobs   []batchv1.Job
+	killed map[string]string // reason by Job name
+	users  []string
+}
+
+func (j *watchdogJobManager) AnnotationKey(name string) string {
+	return "job-assistant/" + name
+}
+
+func (j *watchdogJobManager) List(context.Context) ([]batchv1.Job, error) {
+	return j.jobs, nil
+}
+
+func (j *watchdogJobManager) Kill(ctx context.Context, _, name string, opts kube.KillOptions) error {
+	j.killed[name] = opts.Reason
+	j.users = append(j.users, logging.UserFromContext(ctx))
+	return nil
+}
+
+func runningJob(name, maxDuration string, start time.Time, suspended bool) batchv1.Job {
+	job := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "billing", Name: name, UID: types.UID("uid-" + name),
+			Annotations: map[string]string{"job-assistant/max-duration": maxDuration}},
+		Spec:   batchv1.JobSpec{Suspend: pointer.Bool(suspended)},
+		Status: batchv1.JobStatus{Active: 1, StartTime: &metav1.Time{Time: start}},
+	}
+	if maxDuration == "" {
+		delete(job.Annotations, "job-assistant/max-duration")
+	}
+	return job
+}
+
+func TestWatchdog(t *testing.T) {
+	now := time.Date(2025, 6, 2, 4, 0, 0, 0, time.UTC)
+	jobManager := &watchdogJobManager{killed: map[string]string{}, jobs: []batchv1.Job{
+		runningJob("runaway", "2h", now.Add(-2*time.Hour-time.Minute), false),
+		runningJob("close", "1h", now.Add(-50*time.Minute), false),
+		runningJob("fine", "1h", now.Add(-10*time.Minute), false),
+		runningJob("unlimited", "", now.Add(-10*time.Hour), false),
+		runningJob("being-killed", "1h", now.Add(-2*time.Hour), true),
+		runningJob("invalid", "two hours", now.Add(-10*time.Hour), false),
+	}}
+	svc := NewJobService(jobManager, nopAuditLogger{}, store.NewMemory(store.DefaultRetention), nil, nil).(*jobService)
+
+	warned := svc.checkRuntimes(context.Background(), map[string]bool{}, 0.8, now)
+	assert.Equal(t, map[string]string{"runaway": "killed by the watchdog after 2h1m0s, beyond the max duration of 2h0m0s"}, jobManager.killed)
+	assert.Equal(t, []string{WatchdogUser}, jobManager.users)
+	assert.Len(t, warned, 2, "the close and invalid runs are reported once")
+
+	jobManager.jobs = jobManager.jobs[1:2]
+	warned = svc.checkRuntimes(context.Background(), warned, 0.8, now.Add(time.Minute))
+	require.Len(t, warned, 1, "finished runs are forgotten")
+}
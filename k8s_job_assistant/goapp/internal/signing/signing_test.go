@@ -0,0 +1,36 @@
+package signing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("s3cr3t")
+	now := time.Unix(1750000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"file":"export.csv"}`)
+	signature := Sign(secret, timestamp, body)
+
+	assert.NoError(t, Verify(secret, timestamp, signature, body, 5*time.Minute, now.Add(time.Minute)))
+	assert.ErrorIs(t, Verify(secret, timestamp, signature, []byte(`{"file":"other.csv"}`), 5*time.Minute, now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify([]byte("other"), timestamp, signature, body, 5*time.Minute, now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify(secret, timestamp, signature, body, 5*time.Minute, now.Add(10*time.Minute)), ErrExpiredTimestamp)
+	assert.ErrorIs(t, Verify(secret, "", signature, body, 5*time.Minute, now), ErrExpiredTimestamp)
+}
+
+func TestReplayGuard(t *testing.T) {
+	guard := NewReplayGuard(5 * time.Minute)
+	now := time.Unix(1750000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	assert.NoError(t, guard.Check("billing/nightly sha256=01ab", timestamp, now))
+	assert.ErrorIs(t, guard.Check("billing/nightly sha256=01ab", timestamp, now.Add(time.Minute)), ErrReplayed)
+	assert.NoError(t, guard.Check("billing/refresh sha256=01ab", timestamp, now), "another target")
+	assert.NoError(t, guard.Check("billing/nightly sha256=02cd", timestamp, now), "another request")
+
+	guard.Check("billing/nightly sha256=03ef", strconv.FormatInt(now.Add(6*time.Minute).Unix(), 10), now.Add(6*time.Minute))
+	assert.NotContains(t, guard.seen, "billing/nightly sha256=01ab", "forgotten once out of the window")
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// boltBackend keeps the buckets in a BoltDB file, which a single process can open: KJA must run
+// a single replica, with the file on a persistent volume.
+type boltBackend struct {
+	db *bolt.DB
+}
+
+// NewBolt returns a Store keeping everything in the BoltDB file at path, created when missing.
+func NewBolt(path string, retention Retention) (Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return newStore(&boltBackend{db: db}, retention), nil
+}
+
+func (b *boltBackend) list(_ context.Context, bucket string) (map[string][]byte, error) {
+	values := map[string][]byte{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		return bkt.ForEach(func(k, v []byte) error {
+			values[string(k)] = append([]byte(nil), v...) // only valid within the transaction
+			return nil
+		})
+	})
+	return values, err
+}
+
+func (b *boltBackend) put(_ context.Context, bucket, key string, value []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bkt, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return bkt.Put([]byte(key), value)
+	})
+}
+
+func (b *boltBackend) delete(_ context.Context, bucket, key string) (bool, error) {
+	deleted := false
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil || bkt.Get([]byte(key)) == nil {
+			return nil
+		}
+		deleted = true
+		return bkt.Delete([]byte(key))
+	})
+	return deleted && err == nil, err
+}
+
+func (b *boltBackend) close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"goapp/internal/kube"
+	"sync"
+)
+
+// configMapBackend keeps each bucket in a ConfigMap named after it, ie: 'kja-runs.billing.nightly'
+// for the runs of billing/nightly, so it is shared by all the replicas of KJA.
+type configMapBackend struct {
+	newConfigMap func(name string) kube.ConfigMapStore
+	prefix       string
+
+	mu         sync.Mutex
+	configMaps map[string]kube.ConfigMapStore
+}
+
+// NewConfigMap returns a Store keeping everything in ConfigMaps of the namespace of KJA, created
+// by newConfigMap (see kube.NewConfigMapStore) and named prefix-bucket: 'kja-schedules',
+// 'kja-audit' and 'kja-runs.<namespace>.<name>' for the 'kja' prefix.
+func NewConfigMap(newConfigMap func(name string) kube.ConfigMapStore, prefix string, retention Retention) Store {
+	return newStore(&configMapBackend{newConfigMap: newConfigMap, prefix: prefix, configMaps: map[string]kube.ConfigMapStore{}}, retention)
+}
+
+func (c *configMapBackend) configMap(bucket string) kube.ConfigMapStore {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	configMap, ok := c.configMaps[bucket]
+	if !ok {
+		configMap = c.newConfigMap(c.prefix + "-" + bucket)
+		c.configMaps[bucket] = configMap
+	}
+	return configMap
+}
+
+func (c *configMapBackend) list(ctx context.Context, bucket string) (map[string][]byte, error) {
+	data, err := c.configMap(bucket).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(data))
+	for key, value := range data {
+		values[key] = []byte(value)
+	}
+	return values, nil
+}
+
+func (c *configMapBackend) put(ctx context.Context, bucket, key string, value []byte) error {
+	return c.configMap(bucket).Put(ctx, key, string(value))
+}
+
+func (c *configMapBackend) delete(ctx context.Context, bucket, key string) (bool, error) {
+	return c.configMap(bucket).Delete(ctx, key)
+}
+
+func (c *configMapBackend) close() error {
+	return nil
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"maps"
+	"sync"
+)
+
+// memoryBackend keeps the buckets in memory, they are lost when KJA restarts
+type memoryBackend struct {
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+}
+
+// NewMemory returns a Store keeping everything in memory, for a single replica which does not
+// need the history to survive restarts, and for tests.
+func NewMemory(retention Retention) Store {
+	return newStore(&memoryBackend{buckets: map[string]map[string][]byte{}}, retention)
+}
+
+func (m *memoryBackend) list(_ context.Context, bucket string) (map[string][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.buckets[bucket]), nil
+}
+
+func (m *memoryBackend) put(_ context.Context, bucket, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets[bucket] == nil {
+		m.buckets[bucket] = map[string][]byte{}
+	}
+	m.buckets[bucket][key] = value
+	return nil
+}
+
+func (m *memoryBackend) delete(_ context.Context, bucket, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.buckets[bucket][key]; !ok {
+		return false, nil
+	}
+	delete(m.buckets[bucket], key)
+	return true, nil
+}
+
+func (m *memoryBackend) close() error {
+	return nil
+}
@@ -0,0 +1,185 @@
+// Package store persists what KJA knows beyond the Job objects, which a run deletes: the history
+// of the runs, the audit entries and the scheduled runs. Backends keep JSON values in buckets,
+// see NewMemory, NewBolt and NewConfigMap.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"goapp/internal/audit"
+	"goapp/pkg/model"
+	"log/slog"
+	"sort"
+)
+
+// Backends selectable with the -store flag
+const (
+	BackendMemory    = "memory"
+	BackendBolt      = "bolt"
+	BackendConfigMap = "configmap"
+)
+
+type Store interface {
+	// PutRun records a run of a Job, replacing the record with the same ID. Only the most recent
+	// runs of each Job are kept, see Retention.
+	PutRun(ctx context.Context, namespace, name string, run model.Run) error
+	// Runs returns the recorded runs of a Job, most recent first
+	Runs(ctx context.Context, namespace, name string) ([]model.Run, error)
+	// AddAuditEntry records an audit entry, only the most recent ones are kept
+	AddAuditEntry(ctx context.Context, entry audit.Entry) error
+	// AuditEntries returns the recorded audit entries, most recent first
+	AuditEntries(ctx context.Context) ([]audit.Entry, error)
+	PutSchedule(ctx context.Context, run model.ScheduledRun) error
+	// Schedules returns the scheduled runs, in no particular order
+	Schedules(ctx context.Context) ([]model.ScheduledRun, error)
+	// DeleteSchedule removes a scheduled run and tells whether it was there: a single caller wins
+	// when several delete it
+	DeleteSchedule(ctx context.Context, id string) (bool, error)
+	Close() error
+}
+
+// Retention bounds what is kept, the oldest records are removed beyond
+type Retention struct {
+	RunsPerJob   int
+	AuditEntries int
+}
+
+// DefaultRetention fits a ConfigMap (1MiB) per Job runs and for the audit entries
+var DefaultRetention = Retention{RunsPerJob: 50, AuditEntries: 1000}
+
+// backend stores values by key in buckets, keys are made of [-._a-zA-Z0-9] like ConfigMap keys
+type backend interface {
+	list(ctx context.Context, bucket string) (map[string][]byte, error)
+	put(ctx context.Context, bucket, key string, value []byte) error
+	delete(ctx context.Context, bucket, key string) (bool, error)
+	close() error
+}
+
+const (
+	schedulesBucket = "schedules"
+	auditBucket     = "audit"
+	// runsBucketPrefix is followed by the namespace and the name of the Job, ie: 'runs.billing.nightly'
+	runsBucketPrefix = "runs."
+)
+
+// store keeps the records as JSON in a backend
+type store struct {
+	backend   backend
+	retention Retention
+}
+
+func newStore(b backend, retention Retention) Store {
+	return &store{backend: b, retention: retention}
+}
+
+// runsBucket is the bucket of the runs of a Job, namespaces can not contain dots so it is not ambiguous
+func runsBucket(namespace, name string) string {
+	return runsBucketPrefix + namespace + "." + name
+}
+
+func (s *store) PutRun(ctx context.Context, namespace, name string, run model.Run) error {
+	if run.ID == "" {
+		return fmt.Errorf("run of %s/%s without ID", namespace, name)
+	}
+	value, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	bucket := runsBucket(namespace, name)
+	if err := s.backend.put(ctx, bucket, run.ID, value); err != nil {
+		return err
+	}
+	return s.trim(ctx, bucket, s.retention.RunsPerJob)
+}
+
+func (s *store) Runs(ctx context.Context, namespace, name string) ([]model.Run, error) {
+	return listJSON[model.Run](ctx, s.backend, runsBucket(namespace, name), true)
+}
+
+// AddAuditEntry keys the entry by its time so entries are sorted, a random suffix avoids collisions
+func (s *store) AddAuditEntry(ctx context.Context, entry audit.Entry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	key := entry.Time.UTC().Format("20060102-150405.000000000") + "-" + uuid.NewString()[:8]
+	if err := s.backend.put(ctx, auditBucket, key, value); err != nil {
+		return err
+	}
+	return s.trim(ctx, auditBucket, s.retention.AuditEntries)
+}
+
+func (s *store) AuditEntries(ctx context.Context) ([]audit.Entry, error) {
+	return listJSON[audit.Entry](ctx, s.backend, auditBucket, true)
+}
+
+func (s *store) PutSchedule(ctx context.Context, run model.ScheduledRun) error {
+	value, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	return s.backend.put(ctx, schedulesBucket, run.ID, value)
+}
+
+func (s *store) Schedules(ctx context.Context) ([]model.ScheduledRun, error) {
+	return listJSON[model.ScheduledRun](ctx, s.backend, schedulesBucket, false)
+}
+
+func (s *store) DeleteSchedule(ctx context.Context, id string) (bool, error) {
+	return s.backend.delete(ctx, schedulesBucket, id)
+}
+
+func (s *store) Close() error {
+	return s.backend.close()
+}
+
+// trim removes the oldest values of a bucket beyond limit, keys being sorted by time
+func (s *store) trim(ctx context.Context, bucket string, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+	values, err := s.backend.list(ctx, bucket)
+	if err != nil || len(values) <= limit {
+		return err
+	}
+	keys := sortedKeys(values)
+	for _, key := range keys[:len(keys)-limit] {
+		if _, err := s.backend.delete(ctx, bucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listJSON decodes the values of a bucket sorted by key, invalid ones are skipped
+func listJSON[T any](ctx context.Context, b backend, bucket string, newestFirst bool) ([]T, error) {
+	values, err := b.list(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	keys := sortedKeys(values)
+	if newestFirst {
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	}
+	result := make([]T, 0, len(keys))
+	for _, key := range keys {
+		var value T
+		if err := json.Unmarshal(values[key], &value); err != nil {
+			slog.Warn("ignoring invalid stored value", "bucket", bucket, "key", key, "error", err)
+			continue
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+func sortedKeys(values map[string][]byte) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/internal/audit"
+	"goapp/internal/kube"
+	"goapp/pkg/model"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"maps"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConfigMap keeps the data of a ConfigMap in memory
+type fakeConfigMap struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (f *fakeConfigMap) List(context.Context) (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return maps.Clone(f.values), nil
+}
+
+func (f *fakeConfigMap) Put(_ context.Context, key, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[key] = value
+	return nil
+}
+
+func (f *fakeConfigMap) Delete(_ context.Context, key string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.values[key]
+	delete(f.values, key)
+	return ok, nil
+}
+
+// backends returns a Store of each backend, keeping 2 runs per Job and 3 audit entries
+func backends(t *testing.T) map[string]Store {
+	retention := Retention{RunsPerJob: 2, AuditEntries: 3}
+	bolt, err := NewBolt(filepath.Join(t.TempDir(), "kja.db"), retention)
+	require.NoError(t, err)
+	configMaps := map[string]*fakeConfigMap{}
+	return map[string]Store{
+		BackendMemory: NewMemory(retention),
+		BackendBolt:   bolt,
+		BackendConfigMap: NewConfigMap(func(name string) kube.ConfigMapStore {
+			configMaps[name] = &fakeConfigMap{values: map[string]string{}}
+			return configMaps[name]
+		}, "kja", retention),
+	}
+}
+
+func TestRuns(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			defer s.Close()
+			ctx := context.Background()
+			runs, err := s.Runs(ctx, "billing", "nightly")
+			require.NoError(t, err)
+			assert.Empty(t, runs)
+
+			start := &metav1.Time{Time: time.Date(2025, 6, 2, 2, 0, 0, 0, time.UTC)}
+			for _, id := range []string{"20250601-020000-aaaa", "20250602-020000-bbbb", "20250603-020000-cccc"} {
+				require.NoError(t, s.PutRun(ctx, "billing", "nightly", model.Run{ID: id, State: model.RunRunning, StartTime: start}))
+			}
+			require.NoError(t, s.PutRun(ctx, "billing", "nightly", model.Run{
+				ID:          "20250603-020000-cccc",
+				State:       model.RunFailed,
+				TriggeredBy: "alice",
+				Params:      map[string]string{"DATE": "2025-06-03"},
+				ExitCodes:   []model.ExitCode{{Pod: "nightly-x2x4d", Container: "main", ExitCode: 3, Reason: "Error"}},
+			}))
+			require.NoError(t, s.PutRun(ctx, "billing", "weekly", model.Run{ID: "20250601-020000-dddd"}))
+
+			runs, err = s.Runs(ctx, "billing", "nightly")
+			require.NoError(t, err)
+			require.Len(t, runs, 2, "the oldest run is removed")
+			assert.Equal(t, "20250603-020000-cccc", runs[0].ID)
+			assert.Equal(t, model.RunFailed, runs[0].State)
+			assert.Equal(t, "alice", runs[0].TriggeredBy)
+			assert.Equal(t, "2025-06-03", runs[0].Params["DATE"])
+			assert.Equal(t, int32(3), runs[0].ExitCodes[0].ExitCode)
+			assert.Equal(t, "20250602-020000-bbbb", runs[1].ID)
+			assert.True(t, runs[1].StartTime.Equal(start))
+
+			assert.Error(t, s.PutRun(ctx, "billing", "nightly", model.Run{}), "a run without ID can not be stored")
+		})
+	}
+}
+
+func TestAuditEntries(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			defer s.Close()
+			ctx := context.Background()
+			at := time.Date(2025, 6, 2, 18, 0, 0, 0, time.UTC)
+			for i, action := range []string{"run", "kill", "run", "restart"} {
+				require.NoError(t, s.AddAuditEntry(ctx, audit.Entry{Time: at.Add(time.Duration(i) * time.Second), User: "alice", Action: action}))
+			}
+			// same time, both are kept
+			require.NoError(t, s.AddAuditEntry(ctx, audit.Entry{Time: at.Add(3 * time.Second), User: "bob", Action: "kill"}))
+
+			entries, err := s.AuditEntries(ctx)
+			require.NoError(t, err)
+			require.Len(t, entries, 3)
+			assert.ElementsMatch(t, []string{"alice", "bob"}, []string{entries[0].User, entries[1].User})
+			assert.Equal(t, "run", entries[2].Action)
+		})
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			defer s.Close()
+			ctx := context.Background()
+			at := &metav1.Time{Time: time.Date(2025, 6, 3, 2, 0, 0, 0, time.UTC)}
+			require.NoError(t, s.PutSchedule(ctx, model.ScheduledRun{ID: "a", Namespace: "billing", Name: "nightly", At: at}))
+			require.NoError(t, s.PutSchedule(ctx, model.ScheduledRun{ID: "b", Namespace: "billing", Name: "weekly", At: at}))
+
+			schedules, err := s.Schedules(ctx)
+			require.NoError(t, err)
+			assert.Len(t, schedules, 2)
+
+			deleted, err := s.DeleteSchedule(ctx, "a")
+			require.NoError(t, err)
+			assert.True(t, deleted)
+			deleted, err = s.DeleteSchedule(ctx, "a")
+			require.NoError(t, err)
+			assert.False(t, deleted, "a single caller deletes a schedule")
+
+			schedules, err = s.Schedules(ctx)
+			require.NoError(t, err)
+			require.Len(t, schedules, 1)
+			assert.Equal(t, "weekly", schedules[0].Name)
+			assert.True(t, schedules[0].At.Equal(at))
+		})
+	}
+}
+
+func TestBoltSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kja.db")
+	s, err := NewBolt(path, DefaultRetention)
+	require.NoError(t, err)
+	require.NoError(t, s.PutRun(context.Background(), "billing", "nightly", model.Run{ID: "20250603-020000-cccc", State: model.RunSucceeded}))
+	require.NoError(t, s.Close())
+
+	s, err = NewBolt(path, DefaultRetention)
+	require.NoError(t, err)
+	defer s.Close()
+	runs, err := s.Runs(context.Background(), "billing", "nightly")
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, model.RunSucceeded, runs[0].State)
+}
@@ -0,0 +1,36 @@
+// Package ui holds the React UI assets embedded into the KJA binary
+//
+// The React app build output (reactapp/build) is copied into dist/ before
+// building the Go binary, see the Dockerfile or `make ui`.
+package ui
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+//go:embed all:dist
+var embedded embed.FS
+
+// IndexFile is the SPA entrypoint, served for any route not handled by the API
+const IndexFile = "index.html"
+
+// FS returns the UI assets, read from dir when set (to work on a local React
+// build without rebuilding the Go binary) or embedded in the binary otherwise.
+func FS(dir string) (fs.FS, error) {
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("invalid UI directory: %w", err)
+		}
+		return os.DirFS(dir), nil
+	}
+	return fs.Sub(embedded, "dist")
+}
+
+// HasIndex tells whether assets contains a built UI.
+func HasIndex(assets fs.FS) bool {
+	_, err := fs.Stat(assets, IndexFile)
+	return err == nil
+}
@@ -1,16 +1,43 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"goapp/internal/archive"
+	"goapp/internal/audit"
+	"goapp/internal/grpcapi"
 	"goapp/internal/handler"
+	"goapp/internal/health"
 	"goapp/internal/kube"
+	"goapp/internal/logging"
+	"goapp/internal/metrics"
+	"goapp/internal/notify"
+	"goapp/internal/openapi"
+	"goapp/internal/redact"
 	"goapp/internal/service"
+	"goapp/internal/store"
+	"goapp/internal/tracing"
+	"goapp/internal/ui"
+	"google.golang.org/grpc"
 	"k8s.io/client-go/util/homedir"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -24,30 +51,397 @@ func main() {
 		flag.StringVar(&kubeconfigPath, "kubeconfig", "",
 			"(optional) absolute path to the kubeconfig file")
 	}
+	var traceExporter string
+	flag.StringVar(&traceExporter, "trace-exporter", tracing.ExporterNone,
+		"(optional) OpenTelemetry trace exporter: none, stdout or otlp (configured with OTEL_EXPORTER_OTLP_* env)")
+	var logLevel, logFormat, userHeader string
+	flag.StringVar(&logLevel, "log-level", "info", "(optional) log level: debug, info, warn or error")
+	flag.StringVar(&logFormat, "log-format", logging.FormatJSON, "(optional) log format: json or text")
+	flag.StringVar(&userHeader, "user-header", "X-Forwarded-User",
+		"(optional) request header carrying the user authenticated by the proxy in front of KJA")
+	var trustedProxies []netip.Prefix
+	flag.Func("trusted-proxies", "(optional) comma separated addresses or CIDRs of the proxies setting -user-header, "+
+		"the header of other clients is not trusted to override runs",
+		func(value string) (err error) {
+			trustedProxies, err = logging.ParseTrustedProxies(value)
+			return err
+		})
+	var uiDir string
+	flag.StringVar(&uiDir, "ui-dir", "", "(optional) serve the UI from this directory instead of the embedded one, ie: ../reactapp/build")
+	var auditLogPath string
+	flag.StringVar(&auditLogPath, "audit-log", "-", "(optional) file audit entries are appended to, '-' for stdout")
+	var shutdownDelay, shutdownTimeout time.Duration
+	flag.DurationVar(&shutdownDelay, "shutdown-delay", 5*time.Second,
+		"(optional) how long to keep serving while reported not ready, for load balancers to stop routing traffic")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second,
+		"(optional) how long to wait for in-flight requests before aborting them")
+	var grpcAddr, grpcTLSCert, grpcTLSKey, grpcClientCA string
+	flag.StringVar(&grpcAddr, "grpc-addr", ":9090", "(optional) address the gRPC API listens on, empty to disable it")
+	flag.StringVar(&grpcTLSCert, "grpc-tls-cert", "", "(optional) certificate of the gRPC server, plaintext when not set")
+	flag.StringVar(&grpcTLSKey, "grpc-tls-key", "", "(optional) private key of the gRPC server certificate")
+	flag.StringVar(&grpcClientCA, "grpc-client-ca", "",
+		"(optional) CA gRPC client certificates must be signed by (mTLS), the certificate common name identifies the user")
+	var notifyConfigPath string
+	flag.StringVar(&notifyConfigPath, "notify-config", "",
+		"(optional) notification channels config file, enables notifications on finished runs, see RUNBOOK.md")
+	var watchdogWarning float64
+	flag.Float64Var(&watchdogWarning, "watchdog-warning", 0.8,
+		"(optional) fraction of the max-duration annotation of a Job at which its runs are reported before being killed")
+	var namespace string
+	flag.StringVar(&namespace, "namespace", os.Getenv("POD_NAMESPACE"),
+		"(optional) namespace of KJA, holding the store ConfigMaps and the scheduler Lease, scheduling is disabled when empty (defaults to $POD_NAMESPACE)")
+	var storeBackend, storePath string
+	flag.StringVar(&storeBackend, "store", "",
+		"(optional) where run history, audit entries and schedules are kept: memory, bolt or configmap, defaults to configmap when -namespace is set and memory otherwise")
+	flag.StringVar(&storePath, "store-path", "/var/lib/kja/kja.db", "(optional) BoltDB file of the bolt store, on a persistent volume")
+	retention := store.DefaultRetention
+	flag.IntVar(&retention.RunsPerJob, "store-runs-per-job", retention.RunsPerJob, "(optional) number of runs kept per Job in the run history")
+	flag.IntVar(&retention.AuditEntries, "store-audit-entries", retention.AuditEntries, "(optional) number of audit entries kept in the store")
+	var archiveBackend, archiveDir string
+	var s3Config archive.S3Config
+	var archiveMaxBytes int64
+	flag.StringVar(&archiveBackend, "archive", archive.BackendNone, "(optional) where the logs of the runs are archived: none, filesystem or s3")
+	flag.StringVar(&archiveDir, "archive-dir", "/var/lib/kja/logs", "(optional) directory of the filesystem archive, on a persistent volume")
+	flag.StringVar(&s3Config.Endpoint, "archive-s3-endpoint", "", "(optional) S3 API of the s3 archive, ie: https://s3.eu-west-1.amazonaws.com or http://minio:9000")
+	flag.StringVar(&s3Config.Region, "archive-s3-region", "us-east-1", "(optional) region of the S3 bucket")
+	flag.StringVar(&s3Config.Bucket, "archive-s3-bucket", "", "(optional) bucket of the s3 archive, credentials are read from $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY")
+	flag.BoolVar(&s3Config.PathStyle, "archive-s3-path-style", false, "(optional) address the bucket in the path rather than the host name, for MinIO")
+	flag.Int64Var(&archiveMaxBytes, "archive-max-bytes", 10<<20, "(optional) logs archived per container, beyond they are truncated")
+	redactEnabled, redactDefaultPatterns := true, true
+	var redactPatterns []string
+	redactEnvNames := strings.Join(redact.DefaultNames, ",")
+	flag.BoolVar(&redactEnabled, "redact", redactEnabled, "(optional) redact credentials from the logs and manifests KJA returns")
+	flag.BoolVar(&redactDefaultPatterns, "redact-default-patterns", redactDefaultPatterns,
+		"(optional) redact the values matching the built-in patterns: AWS keys, passwords, tokens, private keys...")
+	flag.Func("redact-pattern", "(optional) redact the values matching this regular expression, only its groups when it has some, repeat it for each pattern",
+		func(pattern string) error {
+			redactPatterns = append(redactPatterns, pattern)
+			return nil
+		})
+	flag.StringVar(&redactEnvNames, "redact-env-names", redactEnvNames,
+		"(optional) comma separated names (globs, case insensitive) of the environment variables whose inline values are redacted")
 	flag.Parse()
 
-	router := gin.Default()
+	logger, err := logging.NewLogger(os.Stdout, logLevel, logFormat)
+	if err != nil {
+		slog.Error("invalid logging configuration", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+	if len(trustedProxies) == 0 && (grpcAddr == "" || grpcClientCA == "") {
+		slog.Warn("no trusted user identity, run overrides are refused: set -trusted-proxies or -grpc-client-ca")
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), traceExporter)
+	if err != nil {
+		slog.Error("failed to setup tracing", "error", err)
+		os.Exit(1)
+	}
+
+	// Keep run history, audit entries and schedules across restarts
+	kubeClient := kube.InitKubeClient(kubeconfigPath)
+	if storeBackend == "" {
+		storeBackend = store.BackendMemory
+		if namespace != "" {
+			storeBackend = store.BackendConfigMap
+		}
+	}
+	var st store.Store
+	switch storeBackend {
+	case store.BackendMemory:
+		st = store.NewMemory(retention)
+	case store.BackendBolt:
+		st, err = store.NewBolt(storePath, retention)
+	case store.BackendConfigMap:
+		if namespace == "" {
+			err = errors.New("the configmap store needs -namespace")
+			break
+		}
+		st = store.NewConfigMap(func(name string) kube.ConfigMapStore {
+			return kube.NewConfigMapStore(kubeClient, namespace, name)
+		}, "kja", retention)
+	default:
+		err = fmt.Errorf("unknown store %q, expected memory, bolt or configmap", storeBackend)
+	}
+	if err != nil {
+		slog.Error("failed to setup the store", "store", storeBackend, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("store ready", "store", storeBackend)
+
+	// Archive the logs of the runs before their pods are deleted
+	var logArchive *service.LogArchive
+	var archiveStore archive.Backend
+	switch archiveBackend {
+	case archive.BackendNone:
+	case archive.BackendFilesystem:
+		archiveStore, err = archive.NewFilesystem(archiveDir)
+	case archive.BackendS3:
+		s3Config.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		s3Config.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		archiveStore, err = archive.NewS3(s3Config)
+	default:
+		err = fmt.Errorf("unknown archive %q, expected none, filesystem or s3", archiveBackend)
+	}
+	if err != nil {
+		slog.Error("failed to setup the log archive", "archive", archiveBackend, "error", err)
+		os.Exit(1)
+	}
+	if archiveStore != nil {
+		logArchive = &service.LogArchive{Backend: archiveStore, MaxBytes: archiveMaxBytes}
+		slog.Info("log archival enabled", "archive", archiveBackend)
+	}
+
+	auditWriter := os.Stdout
+	if auditLogPath != "-" {
+		auditWriter, err = os.OpenFile(auditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+		if err != nil {
+			slog.Error("failed to open audit log", "path", auditLogPath, "error", err)
+			os.Exit(1)
+		}
+	}
+	auditLogger := audit.NewJSONLogger(auditWriter, 1000, st)
+
+	if logLevel != "debug" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(otelgin.Middleware(tracing.ServiceName))
+	router.Use(logging.GinMiddleware(logger, userHeader, trustedProxies))
+	router.Use(metrics.GinMiddleware())
 
 	// Setup Job Manager, Service and http Handler
-	jobManager := kube.NewJobManager(kube.InitKubeClient(kubeconfigPath), "job-assistant")
-	jobService := service.NewJobService(jobManager)
+	jobManager := kube.NewJobManager(kubeClient, "job-assistant")
+	// Redact credentials from the logs and manifests
+	var redactor *redact.Redactor
+	if redactEnabled {
+		if redactDefaultPatterns {
+			redactPatterns = slices.Concat(redact.DefaultPatterns, redactPatterns)
+		}
+		if redactor, err = redact.New(redactPatterns, strings.Split(redactEnvNames, ",")); err != nil {
+			slog.Error("invalid redaction", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	jobService := service.NewJobService(jobManager, auditLogger, st, logArchive, redactor)
 	handler.DecorateRouterWithJobHandlers(router, jobService)
+	handler.DecorateRouterWithAuditHandlers(router, st)
+	handler.DecorateRouterWithHookHandlers(router, jobService)
+	pipelineManager := kube.NewPipelineManager(kubeClient, "job-assistant")
+	pipelineService := service.NewPipelineService(pipelineManager, jobService, 5*time.Second)
+	handler.DecorateRouterWithPipelineHandlers(router, pipelineService)
 
-	//Serve Static React app
-	if _, err := os.Stat("ui/index.html"); err == nil {
-		router.LoadHTMLFiles("ui/index.html")
-		router.Static("/assets", "./ui/assets")
-		router.GET("/", func(c *gin.Context) {
-			c.HTML(http.StatusOK, "index.html", gin.H{})
-		})
+	// Liveness and readiness probes
+	checker := health.NewChecker(5 * time.Second)
+	checker.AddReadinessCheck("kubernetes-api", kube.APIServerReadyCheck(kubeClient))
+	handler.DecorateRouterWithHealthHandlers(router, checker)
+
+	// Start queued runs and notify the channels routed by the notify annotation when a run finishes
+	watchJobsCtx, stopWatchingJobs := context.WithCancel(context.Background())
+	defer stopWatchingJobs()
+	var notifier *notify.Notifier
+	if notifyConfigPath != "" {
+		notifyConfig, err := notify.LoadConfig(notifyConfigPath)
+		if err == nil {
+			notifier, err = notify.New(notifyConfig)
+		}
+		if err != nil {
+			slog.Error("invalid notifications configuration", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("notifications enabled", "channels", len(notifyConfig.Channels))
+	}
+	// Runs are recorded and notified by the leader replica only, see below
+	var leading atomic.Bool
+	leading.Store(namespace == "")
+	hasSynced, err := jobService.WatchFinishedRuns(watchJobsCtx, leading.Load, func(ctx context.Context, run service.FinishedRun) {
+		if notifier != nil {
+			notifier.RunFinished(ctx, run)
+		}
+	})
+	if err != nil {
+		slog.Error("failed to watch jobs", "error", err)
+		os.Exit(1)
+	}
+	checker.AddReadinessCheck("jobs-informer", health.Synced("jobs informer", hasSynced))
+	go jobService.ProcessQueue(watchJobsCtx, 30*time.Second)
+
+	// Schedule single runs, persisted in the store, kill runaway runs and record finished runs from the leader replica only
+	watchdog := func(ctx context.Context) {
+		jobService.Watchdog(ctx, 30*time.Second, watchdogWarning)
+	}
+	leaderDone := make(chan struct{})
+	if namespace != "" {
+		scheduleService := service.NewScheduleService(st, jobService, auditLogger, redactor)
+		handler.DecorateRouterWithScheduleHandlers(router, scheduleService)
+		identity, err := os.Hostname()
+		if err != nil {
+			slog.Error("failed to get the leader election identity", "error", err)
+			os.Exit(1)
+		}
+		go func() {
+			defer close(leaderDone)
+			err := kube.RunLeaderElection(watchJobsCtx, kubeClient, namespace, "kja-scheduler", identity, func(ctx context.Context) {
+				leading.Store(true)
+				defer leading.Store(false)
+				go watchdog(ctx)
+				scheduleService.FireDue(ctx, 10*time.Second)
+			})
+			if err != nil {
+				slog.Error("scheduler disabled, leader election failed", "error", err)
+			}
+		}()
 	} else {
-		log.Println("ui/index.html not found, not serving static file")
+		close(leaderDone)
+		slog.Warn("scheduling disabled, set -namespace or $POD_NAMESPACE to enable it")
+		go watchdog(watchJobsCtx) // single replica, no leader election
 	}
 
-	// Start server
-	err := router.Run(":8080")
+	// Serve the OpenAPI document of the API, rendered at /docs
+	apiDoc, err := openapi.Spec()
 	if err != nil {
-		log.Fatal(router.Run(":8080"))
+		slog.Error("failed to generate OpenAPI document", "error", err)
+		os.Exit(1)
+	}
+	if err := handler.DecorateRouterWithOpenAPIHandlers(router, apiDoc); err != nil {
+		slog.Error("failed to serve OpenAPI document", "error", err)
+		os.Exit(1)
+	}
+
+	// Expose KJA and managed Jobs metrics
+	prometheus.MustRegister(metrics.NewJobCollector(jobService.ListDecoratedJobs))
+	router.GET("/metrics", metrics.Handler())
+
+	// Serve React app, embedded in the binary unless -ui-dir is set
+	uiAssets, err := ui.FS(uiDir)
+	if err != nil {
+		slog.Error("failed to load UI", "error", err)
+		os.Exit(1)
+	}
+	if !ui.HasIndex(uiAssets) {
+		slog.Warn("UI not built, only serving the API", "uiDir", uiDir)
+	}
+	handler.DecorateRouterWithUIHandlers(router, uiAssets)
+
+	// Serve the gRPC API on its own port, WatchJobs streams end once draining starts
+	watchCtx, stopWatches := context.WithCancel(context.Background())
+	defer stopWatches()
+	var grpcServer *grpc.Server
+	grpcErr := make(chan error, 1)
+	if grpcAddr != "" {
+		var tlsConfig *tls.Config
+		if grpcTLSCert != "" {
+			tlsConfig, err = grpcapi.TLSConfig(grpcTLSCert, grpcTLSKey, grpcClientCA)
+			if err != nil {
+				slog.Error("invalid gRPC TLS configuration", "error", err)
+				os.Exit(1)
+			}
+		} else {
+			slog.Warn("gRPC API served in plaintext, set -grpc-tls-cert and -grpc-tls-key to enable TLS")
+		}
+		grpcServer = grpcapi.NewServer(logger, userHeader, trustedProxies, tlsConfig)
+		grpcapi.RegisterJobService(watchCtx, grpcServer, jobService, 2*time.Second)
+
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			slog.Error("failed to listen for gRPC", "addr", grpcAddr, "error", err)
+			os.Exit(1)
+		}
+		go func() {
+			slog.Info("gRPC server starting", "addr", grpcAddr, "tls", tlsConfig != nil, "mtls", grpcClientCA != "")
+			grpcErr <- grpcServer.Serve(listener)
+		}()
+	}
+
+	// Start server, in-flight requests get their context from baseCtx so they can be
+	// aborted if they don't complete within the shutdown timeout
+	baseCtx, abortInFlight := context.WithCancel(context.Background())
+	server := &http.Server{
+		Addr:        ":8080",
+		Handler:     router,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("server starting", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	exitCode := 0
+	select {
+	case err = <-serverErr:
+		slog.Error("server stopped", "error", err)
+		exitCode = 1
+	case err = <-grpcErr:
+		slog.Error("gRPC server stopped", "error", err)
+		exitCode = 1
+	case <-signalCtx.Done():
+		slog.Info("shutdown signal received, draining", "delay", shutdownDelay)
+		checker.Drain()
+		time.Sleep(shutdownDelay)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		stopWatches()
+		grpcStopped := make(chan struct{})
+		if grpcServer != nil {
+			go func() {
+				grpcServer.GracefulStop()
+				close(grpcStopped)
+			}()
+		}
+		if err = server.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("in-flight requests did not complete in time, aborting them", "timeout", shutdownTimeout, "error", err)
+			abortInFlight()
+			_ = server.Close()
+		}
+		if grpcServer != nil {
+			select {
+			case <-grpcStopped:
+			case <-shutdownCtx.Done():
+				slog.Warn("in-flight gRPC calls did not complete in time, aborting them", "timeout", shutdownTimeout)
+				grpcServer.Stop()
+			}
+		}
+		cancel()
+		if err = <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server stopped", "error", err)
+			exitCode = 1
+		}
+	}
+	abortInFlight()
+
+	// flush audit entries and traces before exiting
+	flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stopWatchingJobs()
+	select {
+	case <-leaderDone: // the scheduler Lease is released for another replica to take over right away
+	case <-flushCtx.Done():
+	}
+	if notifier != nil {
+		if err = notifier.Close(flushCtx); err != nil {
+			slog.Error("failed to send pending notifications", "error", err)
+		}
+	}
+	if err = auditLogger.Close(flushCtx); err != nil {
+		slog.Error("failed to flush audit log", "error", err)
+		exitCode = 1
+	}
+	if auditWriter != os.Stdout {
+		_ = auditWriter.Sync()
+		_ = auditWriter.Close()
+	}
+	if err = st.Close(); err != nil {
+		slog.Error("failed to close the store", "error", err)
+	}
+	if err = shutdownTracing(flushCtx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
 	}
-	log.Println("Server starting on :8080...")
+	slog.Info("server stopped")
+	os.Exit(exitCode)
 }
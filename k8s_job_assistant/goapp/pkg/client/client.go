@@ -0,0 +1,449 @@
+// Package client is a typed Go client of the Kubernetes Job Assistant HTTP API
+//
+//	c, err := client.New("https://kja.your.company.com", client.WithToken(token))
+//	if err != nil {...}
+//	_, err = c.Run(ctx, "billing", "nightly-export", client.RunOptions{})
+//	var alreadyRunning *client.JobAlreadyRunningError
+//	if errors.As(err, &alreadyRunning) {...}
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goapp/pkg/model"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenSource returns the token sent as 'Authorization: Bearer <token>' with each request,
+// it is called for every request so short-lived tokens can be refreshed.
+type TokenSource func(ctx context.Context) (string, error)
+
+// Client calls the KJA HTTP API, it is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      TokenSource
+	userAgent  string
+	maxRetries int
+	backoff    time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used to call KJA (TLS, proxy, timeouts, ...).
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithToken sends a static bearer token with each request.
+func WithToken(token string) Option {
+	return WithTokenSource(func(context.Context) (string, error) { return token, nil })
+}
+
+// WithTokenSource sends a bearer token obtained from source with each request.
+func WithTokenSource(source TokenSource) Option {
+	return func(c *Client) { c.token = source }
+}
+
+// WithUserAgent sets the User-Agent of the requests, to identify the calling service.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// WithRetries retries failed requests up to maxRetries times, waiting backoff then doubling it.
+//
+// Read operations are retried on network errors and 502/503/504 answers. Actions (run, kill)
+// are only retried when KJA could not be reached at all, so they are never performed twice.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// New returns a client of the KJA API served at baseURL (ie: http://localhost:8080).
+func New(baseURL string, opts ...Option) (*Client, error) {
+	baseURL = strings.TrimRight(baseURL, "/")
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KJA URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid KJA URL %q: scheme must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    baseURL,
+		httpClient: http.DefaultClient,
+		userAgent:  "kja-go-client",
+		maxRetries: 2,
+		backoff:    500 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// List lists the Jobs managed by KJA.
+func (c *Client) List(ctx context.Context) (*model.ListJobs, error) {
+	var jobs model.ListJobs
+	return &jobs, c.getJSON(ctx, "/list", nil, &jobs)
+}
+
+// Status returns the status of a managed Job.
+func (c *Client) Status(ctx context.Context, namespace, name string) (*model.DecoratedJob, error) {
+	var job model.DecoratedJob
+	return &job, c.getJSON(ctx, jobPath("/status", namespace, name), nil, &job)
+}
+
+// Runs lists the runs of a managed Job, most recent first.
+func (c *Client) Runs(ctx context.Context, namespace, name string) (*model.ListRuns, error) {
+	var runs model.ListRuns
+	return &runs, c.getJSON(ctx, jobPath("/runs", namespace, name), nil, &runs)
+}
+
+// RunOptions customizes a run, the zero value runs the Job as defined.
+type RunOptions struct {
+	// Overrides replaces fields of the Job for this run, the Job must allow them
+	Overrides *model.RunOverrides
+}
+
+func (o RunOptions) query() url.Values {
+	query := url.Values{}
+	overrides := o.Overrides
+	if overrides == nil {
+		return query
+	}
+	if overrides.Container != "" {
+		query.Set("container", overrides.Container)
+	}
+	if overrides.ImageTag != "" {
+		query.Set("imageTag", overrides.ImageTag)
+	}
+	for _, arg := range overrides.Args {
+		query.Add("arg", arg)
+	}
+	for name, value := range overrides.Requests {
+		query.Set(name+"Request", value)
+	}
+	for name, value := range overrides.Limits {
+		query.Set(name+"Limit", value)
+	}
+	if overrides.Parallelism != nil {
+		query.Set("parallelism", strconv.Itoa(int(*overrides.Parallelism)))
+	}
+	if overrides.Completions != nil {
+		query.Set("completions", strconv.Itoa(int(*overrides.Completions)))
+	}
+	for key, value := range overrides.NodeSelector {
+		query.Add("nodeSelector", key+"="+value)
+	}
+	return query
+}
+
+// Run runs a managed Job, fails with JobAlreadyRunningError if it is still running. Jobs queueing
+// their runs (job-assistant/queue annotation) queue it instead, the queued run is then returned.
+func (c *Client) Run(ctx context.Context, namespace, name string, opts RunOptions) (*model.QueuedRun, error) {
+	resp, err := c.do(ctx, http.MethodGet, jobPath("/run", namespace, name), opts.query(), false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, nil
+	}
+	var queued model.QueuedRun
+	if err := json.NewDecoder(resp.Body).Decode(&queued); err != nil {
+		return nil, fmt.Errorf("invalid queued run: %w", err)
+	}
+	return &queued, nil
+}
+
+// KillOptions tells how a run is killed, the zero value kills gracefully.
+type KillOptions struct {
+	// Mode is graceful, force or escalate
+	Mode string
+	// GracePeriod overrides the terminationGracePeriodSeconds of the pods
+	GracePeriod *time.Duration
+	// EscalateAfter is how long the escalate mode waits before forcing the kill
+	EscalateAfter time.Duration
+	// Reason is shown in the status of the Job
+	Reason string
+}
+
+func (o KillOptions) query() url.Values {
+	query := url.Values{}
+	if o.Mode != "" {
+		query.Set("mode", o.Mode)
+	}
+	if o.GracePeriod != nil {
+		query.Set("grace", o.GracePeriod.String())
+	}
+	if o.EscalateAfter != 0 {
+		query.Set("escalateAfter", o.EscalateAfter.String())
+	}
+	if o.Reason != "" {
+		query.Set("reason", o.Reason)
+	}
+	return query
+}
+
+// Kill kills the running Job.
+func (c *Client) Kill(ctx context.Context, namespace, name string, opts KillOptions) error {
+	return c.action(ctx, http.MethodGet, jobPath("/kill", namespace, name), opts.query())
+}
+
+// Restart kills the current run of the Job and runs it again with the same params, opts tell
+// how the run is killed. Fails with JobAlreadyRunningError while the Job is being run, killed or restarted.
+func (c *Client) Restart(ctx context.Context, namespace, name string, opts KillOptions) error {
+	return c.action(ctx, http.MethodGet, jobPath("/restart", namespace, name), opts.query())
+}
+
+// RetryFailedIndexes re-runs the failed indexes of the last run of an Indexed Job, each one in a
+// Job of its own, and returns them. Fails with an APIError (400) when the Job is not Indexed or
+// its last run did not fail.
+func (c *Client) RetryFailedIndexes(ctx context.Context, namespace, name string) (*model.RetriedIndexes, error) {
+	resp, err := c.do(ctx, http.MethodPost, jobPath("/retry", namespace, name), nil, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var retried model.RetriedIndexes
+	if err := json.NewDecoder(resp.Body).Decode(&retried); err != nil {
+		return nil, fmt.Errorf("invalid KJA answer: %w", err)
+	}
+	return &retried, nil
+}
+
+// Queue lists the runs queued while their Job was running.
+func (c *Client) Queue(ctx context.Context) (*model.ListQueuedRuns, error) {
+	var queued model.ListQueuedRuns
+	return &queued, c.getJSON(ctx, "/queue", nil, &queued)
+}
+
+// CancelQueuedRun removes a run from the queue of its Job, fails with NotFoundError once it started.
+func (c *Client) CancelQueuedRun(ctx context.Context, namespace, name, id string) error {
+	return c.action(ctx, http.MethodPost, jobPath("/queue", namespace, name)+"/"+url.PathEscape(id)+"/cancel", nil)
+}
+
+// LogOptions selects which logs of a Job to stream.
+type LogOptions struct {
+	// Container to get the logs of, defaults to the first container
+	Container string
+	// Pod to get the logs of, defaults to the most recent pod
+	Pod string
+	// Follow keeps streaming while the run is going on
+	Follow bool
+	// TailLines only returns the last lines when set
+	TailLines *int64
+	// Filter selects the lines returned, after TailLines
+	Filter LogFilter
+}
+
+func (o LogOptions) query() url.Values {
+	query := o.Filter.query()
+	if o.Container != "" {
+		query.Set("container", o.Container)
+	}
+	if o.Pod != "" {
+		query.Set("pod", o.Pod)
+	}
+	if o.Follow {
+		query.Set("follow", "true")
+	}
+	if o.TailLines != nil {
+		query.Set("tail", strconv.FormatInt(*o.TailLines, 10))
+	}
+	return query
+}
+
+// LogFilter selects the lines of the logs KJA returns, the zero value returns them all.
+type LogFilter struct {
+	// Grep only returns the lines containing this text
+	Grep string
+	// Regex only returns the lines matching this RE2 expression
+	Regex string
+	// Levels only returns the JSON lines whose level field is one of these
+	Levels []string
+	// Since and Until only return the lines logged within this window, when set
+	Since, Until time.Time
+	// Timestamps starts each line with the time it was logged at
+	Timestamps bool
+}
+
+func (f LogFilter) query() url.Values {
+	query := url.Values{}
+	if f.Grep != "" {
+		query.Set("grep", f.Grep)
+	}
+	if f.Regex != "" {
+		query.Set("regex", f.Regex)
+	}
+	if len(f.Levels) > 0 {
+		query.Set("level", strings.Join(f.Levels, ","))
+	}
+	if !f.Since.IsZero() {
+		query.Set("since", f.Since.Format(time.RFC3339Nano))
+	}
+	if !f.Until.IsZero() {
+		query.Set("until", f.Until.Format(time.RFC3339Nano))
+	}
+	if f.Timestamps {
+		query.Set("timestamps", "true")
+	}
+	return query
+}
+
+// Logs streams the logs of the current run of a managed Job, the caller must close the stream.
+func (c *Client) Logs(ctx context.Context, namespace, name string, opts LogOptions) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, jobPath("/logs", namespace, name), opts.query(), true)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ArchivedLogs lists the archived logs of a run of a managed Job.
+func (c *Client) ArchivedLogs(ctx context.Context, namespace, name, runID string) (*model.ListArchivedLogs, error) {
+	var logs model.ListArchivedLogs
+	return &logs, c.getJSON(ctx, jobPath("/runs", namespace, name)+"/"+url.PathEscape(runID)+"/logs", nil, &logs)
+}
+
+// ArchivedLog streams the archived logs of a container of a run, the caller must close the stream.
+func (c *Client) ArchivedLog(ctx context.Context, namespace, name, runID, pod, container string, filter LogFilter) (io.ReadCloser, error) {
+	path := jobPath("/runs", namespace, name) + "/" + url.PathEscape(runID) + "/logs/" + url.PathEscape(pod) + "/" + url.PathEscape(container)
+	resp, err := c.do(ctx, http.MethodGet, path, filter.query(), true)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Bundle streams the bundle of a run (logs, manifests and events) as a zip or tar.gz archive,
+// the caller must close the stream.
+func (c *Client) Bundle(ctx context.Context, namespace, name, runID, format string) (io.ReadCloser, error) {
+	path := jobPath("/runs", namespace, name) + "/" + url.PathEscape(runID) + "/bundle"
+	resp, err := c.do(ctx, http.MethodGet, path, url.Values{"format": {format}}, true)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, out any) error {
+	resp, err := c.do(ctx, http.MethodGet, path, query, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid KJA answer: %w", err)
+	}
+	return nil
+}
+
+// action performs a non idempotent operation, the API exposes the Job ones as GET.
+func (c *Client) action(ctx context.Context, method, path string, query url.Values) error {
+	resp, err := c.do(ctx, method, path, query, false)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// do sends the request with retries, non 2xx answers are returned as typed errors.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, idempotent bool) (*http.Response, error) {
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, query)
+		if err == nil || attempt >= c.maxRetries || !retryable(err, idempotent) {
+			return resp, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, query url.Values) (*http.Response, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.token != nil {
+		token, err := c.token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get KJA token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	body := struct {
+		Error string `json:"error"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error == "" {
+		body.Error = http.StatusText(resp.StatusCode)
+	}
+	return nil, newAPIError(resp.StatusCode, body.Error)
+}
+
+// retryable tells whether a failed request can safely be sent again.
+func retryable(err error, idempotent bool) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// KJA could not be reached, the request was not processed
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	if !idempotent {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return true // network error
+}
+
+func jobPath(prefix, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", prefix, url.PathEscape(namespace), url.PathEscape(name))
+}
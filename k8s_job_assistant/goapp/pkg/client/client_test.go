@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"goapp/pkg/model"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, err := New(server.URL, append([]Option{WithRetries(2, time.Millisecond)}, opts...)...)
+	require.NoError(t, err)
+	return c
+}
+
+func TestTypedErrors(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/run/billing/nightly":
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":"job billing/nightly is already running"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"jobs.batch \"unknown\" not found"}`))
+		}
+	})
+
+	_, err := c.Run(context.Background(), "billing", "nightly", RunOptions{})
+	var alreadyRunning *JobAlreadyRunningError
+	require.ErrorAs(t, err, &alreadyRunning)
+	assert.Equal(t, "job billing/nightly is already running", alreadyRunning.Message)
+
+	_, err = c.Status(context.Background(), "billing", "unknown")
+	var notFound *NotFoundError
+	require.ErrorAs(t, err, &notFound)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestRunQueued(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(model.QueuedRun{ID: "20250602-030512-93c0d4", Position: 2})
+	})
+
+	queued, err := c.Run(context.Background(), "billing", "refresh", RunOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, queued)
+	assert.Equal(t, 2, queued.Position)
+}
+
+func TestRunOverrides(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, url.Values{
+			"imageTag":     {"1.5.0"},
+			"arg":          {"--from", "2025-06-01"},
+			"memoryLimit":  {"4Gi"},
+			"parallelism":  {"4"},
+			"nodeSelector": {"pool=highmem"},
+		}, r.URL.Query())
+	})
+
+	parallelism := int32(4)
+	_, err := c.Run(context.Background(), "billing", "nightly", RunOptions{Overrides: &model.RunOverrides{
+		ImageTag:     "1.5.0",
+		Args:         []string{"--from", "2025-06-01"},
+		Limits:       map[string]string{"memory": "4Gi"},
+		Parallelism:  &parallelism,
+		NodeSelector: map[string]string{"pool": "highmem"},
+	}})
+	require.NoError(t, err)
+}
+
+func TestRetries(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(model.ListJobs{Count: 0})
+	})
+
+	_, err := c.List(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load(), "reads are retried on 503")
+
+	calls.Store(0)
+	err = c.Kill(context.Background(), "billing", "nightly", KillOptions{})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load(), "actions are not retried once KJA answered")
+}
+
+func TestTokenAndUserAgent(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer s3cr3t", r.Header.Get("Authorization"))
+		assert.Equal(t, "billing-exporter", r.Header.Get("User-Agent"))
+		assert.Equal(t, "/logs/billing/nightly", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("follow"))
+		assert.Equal(t, "10", r.URL.Query().Get("tail"))
+		_, _ = w.Write([]byte("done\n"))
+	}, WithToken("s3cr3t"), WithUserAgent("billing-exporter"))
+
+	tail := int64(10)
+	logs, err := c.Logs(context.Background(), "billing", "nightly", LogOptions{Follow: true, TailLines: &tail})
+	require.NoError(t, err)
+	require.NoError(t, logs.Close())
+}
+
+func TestLogFilter(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/runs/billing/nightly/20250602-030000-4f2a9c/logs/nightly-x2x4d/main", r.URL.Path)
+		assert.Equal(t, url.Values{"regex": {"(?i)timeout"}, "level": {"error,warn"}, "since": {"2025-06-02T03:00:00Z"}}, r.URL.Query())
+		_, _ = w.Write([]byte("timeout\n"))
+	})
+
+	logs, err := c.ArchivedLog(context.Background(), "billing", "nightly", "20250602-030000-4f2a9c", "nightly-x2x4d", "main",
+		LogFilter{Regex: "(?i)timeout", Levels: []string{"error", "warn"}, Since: time.Date(2025, 6, 2, 3, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	require.NoError(t, logs.Close())
+}
+
+func TestKillOptions(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/kill/billing/nightly", r.URL.Path)
+		assert.Equal(t, url.Values{"mode": {"escalate"}, "grace": {"10s"}, "escalateAfter": {"1m0s"}, "reason": {"stuck on a lock"}}, r.URL.Query())
+	})
+
+	grace := 10 * time.Second
+	err := c.Kill(context.Background(), "billing", "nightly", KillOptions{Mode: "escalate", GracePeriod: &grace, EscalateAfter: time.Minute, Reason: "stuck on a lock"})
+	require.NoError(t, err)
+}
+
+func TestNewInvalidURL(t *testing.T) {
+	_, err := New("kja.local:8080")
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// APIError is an error answered by the KJA API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("KJA API error %d: %s", e.StatusCode, e.Message)
+}
+
+// JobAlreadyRunningError is returned when running a Job which is still running, wait for
+// its completion or kill it first.
+type JobAlreadyRunningError struct {
+	APIError
+}
+
+func (e *JobAlreadyRunningError) Error() string {
+	return fmt.Sprintf("job is already running: %s", e.Message)
+}
+
+func (e *JobAlreadyRunningError) Unwrap() error {
+	return &e.APIError
+}
+
+// NotFoundError is returned when the Job does not exist or is not managed by KJA, or
+// when it has no pod to get the logs from.
+type NotFoundError struct {
+	APIError
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("not found: %s", e.Message)
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return &e.APIError
+}
+
+// newAPIError returns the typed error matching the status code of the answer.
+func newAPIError(statusCode int, message string) error {
+	apiErr := APIError{StatusCode: statusCode, Message: message}
+	switch statusCode {
+	case http.StatusConflict:
+		return &JobAlreadyRunningError{APIError: apiErr}
+	case http.StatusNotFound:
+		return &NotFoundError{APIError: apiErr}
+	default:
+		return &apiErr
+	}
+}
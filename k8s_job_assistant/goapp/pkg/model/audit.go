@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// AuditEntry is one audited action on a managed Job.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestId,omitempty"`
+	User      string    `json:"user"`
+	Action    string    `json:"action"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Outcome   string    `json:"outcome"`
+	Reason    string    `json:"reason,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type ListAuditEntries struct {
+	Entries []AuditEntry `json:"entries"`
+	Count   int          `json:"count"`
+}
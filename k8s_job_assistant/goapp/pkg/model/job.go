@@ -0,0 +1,187 @@
+// Package model holds the types of the KJA HTTP API, shared by the server and the Go client
+package model
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type DecoratedJob struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	RunID     string `json:"runId,omitempty"`
+	// Deprecated: the start time of the current run, successful or not, use CurrentRun or LastSuccess
+	LastSuccessfullyRunStarTime *metav1.Time `json:"lastSuccessfullyRunStarTime,omitempty"`
+	// Deprecated: the latest condition of the Job, use State and CurrentRun
+	LastStatus LastStatus `json:"lastStatus"`
+	// Deprecated: the completion time of the current run, use CurrentRun or LastSuccess
+	LastSuccessfullyRunCompletionTime *metav1.Time `json:"lastSuccessfullyRunCompletionTime,omitempty"`
+	// State is the state of the current run, NeverRun when the Job never ran
+	State RunState `json:"state"`
+	// CurrentRun details the current run, unset when the Job never ran
+	CurrentRun *RunStatus `json:"currentRun,omitempty"`
+	// LastSuccess is the last run which succeeded, the current one or a previous one
+	LastSuccess *SuccessfulRun `json:"lastSuccess,omitempty"`
+	// Indexes is the per-index status of Indexed Jobs
+	Indexes *Indexes `json:"indexes,omitempty"`
+}
+
+// RunStatus is the status of the current run of a Job
+type RunStatus struct {
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Duration is from the start to the completion, the failure or the kill of the run, or until
+	// now while it goes on
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// Active, Succeeded and Failed count the pods of the run
+	Active    int32 `json:"active"`
+	Succeeded int32 `json:"succeeded"`
+	Failed    int32 `json:"failed"`
+	// BackoffLimit is how many pods can fail before the run fails
+	BackoffLimit int32 `json:"backoffLimit"`
+	// FailureReason is the reason of the Failed condition, ie: BackoffLimitExceeded,
+	// DeadlineExceeded or PodFailurePolicy
+	FailureReason  string `json:"failureReason,omitempty"`
+	FailureMessage string `json:"failureMessage,omitempty"`
+}
+
+// SuccessfulRun is a run which succeeded
+type SuccessfulRun struct {
+	StartTime      *metav1.Time     `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time     `json:"completionTime,omitempty"`
+	Duration       *metav1.Duration `json:"duration,omitempty"`
+}
+
+// Indexes is the status of the indexes of the current run of an Indexed Job, as ranges like "0-3,7"
+type Indexes struct {
+	Completions    int32  `json:"completions"`
+	Succeeded      string `json:"succeeded,omitempty"`
+	SucceededCount int    `json:"succeededCount"`
+	// Failed are the indexes which did not succeed once the run failed
+	Failed      string `json:"failed,omitempty"`
+	FailedCount int    `json:"failedCount"`
+	// Retries are the Jobs re-running failed indexes of the run
+	Retries []IndexRetry `json:"retries,omitempty"`
+}
+
+// IndexRetry is the Job re-running a failed index of an Indexed Job
+type IndexRetry struct {
+	Index int      `json:"index"`
+	Job   string   `json:"job"`
+	State RunState `json:"state"`
+}
+
+type RetriedIndexes struct {
+	Indexes []int `json:"indexes"`
+	Count   int   `json:"count"`
+}
+
+type ListJobs struct {
+	Jobs  []DecoratedJob `json:"jobs"`
+	Count int            `json:"count"`
+}
+
+type LastStatus struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+}
+
+// RunState is the state of one run of a Job
+type RunState string
+
+const (
+	// RunNeverRun is the state of a Job which never ran
+	RunNeverRun  RunState = "NeverRun"
+	RunPending   RunState = "Pending"
+	RunRunning   RunState = "Running"
+	RunSucceeded RunState = "Succeeded"
+	RunFailed    RunState = "Failed"
+	RunKilled    RunState = "Killed"
+	// RunSuspended is a run suspended without being killed through KJA, ie: by kubectl
+	RunSuspended RunState = "Suspended"
+)
+
+// Finished tells whether the run reached a final state.
+func (s RunState) Finished() bool {
+	return s == RunSucceeded || s == RunFailed || s == RunKilled
+}
+
+// Run is one execution of a Job triggered through KJA, kept in the run history of the Job
+type Run struct {
+	ID             string       `json:"id,omitempty"`
+	TriggeredBy    string       `json:"triggeredBy,omitempty"`
+	State          RunState     `json:"state"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	KilledAt       *metav1.Time `json:"killedAt,omitempty"`
+	KillReason     string       `json:"killReason,omitempty"`
+	// Params and Overrides the run was started with
+	Params    map[string]string `json:"params,omitempty"`
+	Overrides *RunOverrides     `json:"overrides,omitempty"`
+	// FailureReason is the reason of the Failed condition of the Job, ie: BackoffLimitExceeded
+	FailureReason string `json:"failureReason,omitempty"`
+	// ExitCodes of the containers of the pods of a finished run, when its pods were still there
+	ExitCodes []ExitCode `json:"exitCodes,omitempty"`
+}
+
+// ExitCode is how a container of a pod of a run terminated
+type ExitCode struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	ExitCode  int32  `json:"exitCode"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type ListRuns struct {
+	Runs  []Run `json:"runs"`
+	Count int   `json:"count"`
+}
+
+// ArchivedLog is the archived logs of a container of a pod of a run
+type ArchivedLog struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	// Size of the logs in bytes
+	Size int64 `json:"size"`
+}
+
+type ListArchivedLogs struct {
+	Logs  []ArchivedLog `json:"logs"`
+	Count int           `json:"count"`
+}
+
+// RunOverrides replaces fields of the Job for a single run, the Job lists the fields it lets
+// override in its overrides annotation, and the users allowed to in its override-users one.
+type RunOverrides struct {
+	// Container ImageTag, Args and Resources apply to, defaults to the first one
+	Container string `json:"container,omitempty"`
+	// ImageTag replaces the tag (or digest) of the image of the container
+	ImageTag string `json:"imageTag,omitempty"`
+	// Args replaces the args of the container
+	Args []string `json:"args,omitempty"`
+	// Requests and Limits replace the cpu and memory requests and limits of the container, ie: {"memory": "4Gi"}
+	Requests map[string]string `json:"requests,omitempty"`
+	Limits   map[string]string `json:"limits,omitempty"`
+	// Parallelism and Completions replace the ones of the Job
+	Parallelism *int32 `json:"parallelism,omitempty"`
+	Completions *int32 `json:"completions,omitempty"`
+	// NodeSelector replaces the node selector of the pods
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+}
+
+// QueuedRun is a run requested while the Job was running, started once the current run finishes
+type QueuedRun struct {
+	ID          string            `json:"id"`
+	Namespace   string            `json:"namespace"`
+	Name        string            `json:"name"`
+	TriggeredBy string            `json:"triggeredBy,omitempty"`
+	Params      map[string]string `json:"params,omitempty"`
+	Overrides   *RunOverrides     `json:"overrides,omitempty"`
+	QueuedAt    *metav1.Time      `json:"queuedAt,omitempty"`
+	// Position in the queue of the Job, 1 starts next
+	Position int `json:"position"`
+}
+
+type ListQueuedRuns struct {
+	Runs  []QueuedRun `json:"runs"`
+	Count int         `json:"count"`
+}
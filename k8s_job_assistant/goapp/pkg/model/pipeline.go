@@ -0,0 +1,71 @@
+package model
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Pipeline chains managed Jobs: each step runs once the steps it comes after are done
+type Pipeline struct {
+	Namespace string         `json:"namespace"`
+	Name      string         `json:"name"`
+	Steps     []PipelineStep `json:"steps"`
+}
+
+type PipelineStep struct {
+	// Job is the '<namespace>/<name>' of the managed Job the step runs
+	Job string `json:"job"`
+	// After lists the Jobs of the steps this one waits for
+	After []string `json:"after,omitempty"`
+	// ContinueOnError lets the steps after this one run even if it fails
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+type ListPipelines struct {
+	Pipelines []Pipeline `json:"pipelines"`
+	Count     int        `json:"count"`
+}
+
+// PipelineState is the state of a pipeline run, or of one of its steps
+type PipelineState string
+
+const (
+	PipelinePending   PipelineState = "Pending"
+	PipelineRunning   PipelineState = "Running"
+	PipelineSucceeded PipelineState = "Succeeded"
+	PipelineFailed    PipelineState = "Failed"
+	PipelineCancelled PipelineState = "Cancelled"
+	// PipelineSkipped steps did not run because a step they come after failed
+	PipelineSkipped PipelineState = "Skipped"
+)
+
+// Finished tells whether the pipeline run, or step, reached a final state.
+func (s PipelineState) Finished() bool {
+	return s != PipelinePending && s != PipelineRunning
+}
+
+// PipelineRun is one execution of a Pipeline
+type PipelineRun struct {
+	ID             string            `json:"id"`
+	Namespace      string            `json:"namespace"`
+	Name           string            `json:"name"`
+	TriggeredBy    string            `json:"triggeredBy,omitempty"`
+	State          PipelineState     `json:"state"`
+	StartTime      *metav1.Time      `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time      `json:"completionTime,omitempty"`
+	Steps          []PipelineStepRun `json:"steps"`
+}
+
+type PipelineStepRun struct {
+	Job string `json:"job"`
+	// RunID is the run of the Job started by this step
+	RunID          string        `json:"runId,omitempty"`
+	State          PipelineState `json:"state"`
+	Error          string        `json:"error,omitempty"`
+	StartTime      *metav1.Time  `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time  `json:"completionTime,omitempty"`
+}
+
+type ListPipelineRuns struct {
+	Runs  []PipelineRun `json:"runs"`
+	Count int           `json:"count"`
+}
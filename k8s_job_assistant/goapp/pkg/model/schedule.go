@@ -0,0 +1,37 @@
+package model
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ScheduleRequest schedules a single run of a managed Job
+type ScheduleRequest struct {
+	// At is the time of the run: RFC3339 (ie: 2025-06-03T02:00:00+02:00), or a local time
+	// (ie: 2025-06-03T02:00) of TimeZone
+	At       string `json:"at"`
+	TimeZone string `json:"timeZone,omitempty"`
+	// Params of the run, see the job-assistant/params annotation
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// ScheduledRun is a run of a managed Job waiting for its time
+type ScheduledRun struct {
+	ID        string       `json:"id"`
+	Namespace string       `json:"namespace"`
+	Name      string       `json:"name"`
+	At        *metav1.Time `json:"at"`
+	// TimeZone the run was scheduled in, for display
+	TimeZone    string            `json:"timeZone,omitempty"`
+	Params      map[string]string `json:"params,omitempty"`
+	ScheduledBy string            `json:"scheduledBy,omitempty"`
+	CreatedAt   *metav1.Time      `json:"createdAt,omitempty"`
+	// Attempts counts the attempts to start the run while its Job was busy, it is retried until it starts
+	Attempts int `json:"attempts,omitempty"`
+	// LastError is the error of the last attempt
+	LastError string `json:"lastError,omitempty"`
+}
+
+type ListScheduledRuns struct {
+	Runs  []ScheduledRun `json:"runs"`
+	Count int            `json:"count"`
+}
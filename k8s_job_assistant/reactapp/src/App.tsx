@@ -10,6 +10,27 @@ type Job = {
         message?: string;
     };
     lastSuccessfullyRunCompletionTime?: Date;
+    state: string;
+    runId?: string;
+    currentRun?: {
+        duration?: string;
+        active: number;
+        succeeded: number;
+        failed: number;
+        backoffLimit: number;
+        failureReason?: string;
+    };
+    lastSuccess?: {
+        completionTime?: Date;
+        duration?: string;
+    };
+    indexes?: {
+        completions: number;
+        succeededCount: number;
+        failed?: string;
+        failedCount: number;
+        retries?: { index: number; job: string; state: string }[];
+    };
 };
 
 export function App() {
@@ -85,11 +106,15 @@ export function App() {
         ...raw,
         lastSuccessfullyRunStarTime: raw.lastSuccessfullyRunStarTime ? new Date(raw.lastSuccessfullyRunStarTime) : undefined,
         lastSuccessfullyRunCompletionTime: raw.lastSuccessfullyRunCompletionTime ? new Date(raw.lastSuccessfullyRunCompletionTime) : undefined,
+        lastSuccess: raw.lastSuccess ? {
+            ...raw.lastSuccess,
+            completionTime: raw.lastSuccess.completionTime ? new Date(raw.lastSuccess.completionTime) : undefined,
+        } : undefined,
     });
 
-    const performJobAction = async (path: string) => {
+    const performJobAction = async (path: string, method = "GET") => {
         try {
-            const res = await fetch(path);
+            const res = await fetch(path, {method});
             if (!res.ok) {
                 const text = await res.text();
                 throw new Error(`Error ${res.status}: ${text}`);
@@ -112,6 +137,12 @@ export function App() {
     const killJob = (namespace: string, name: string) =>
         performJobAction(`/kill/${namespace}/${name}`);
 
+    const restartJob = (namespace: string, name: string) =>
+        performJobAction(`/restart/${namespace}/${name}`);
+
+    const retryFailedIndexes = (namespace: string, name: string) =>
+        performJobAction(`/retry/${namespace}/${name}`, "POST");
+
     return (
         <div style={{padding: "2rem", fontFamily: "Arial, sans-serif"}}>
             {error && (
@@ -136,6 +167,9 @@ export function App() {
                         <th style={thStyle}>Status</th>
                         <th style={thStyle}>Start time</th>
                         <th style={thStyle}>Completion time</th>
+                        <th style={thStyle}>Duration</th>
+                        <th style={thStyle}>Pods</th>
+                        <th style={thStyle}>Last success</th>
                         <th style={thStyle}>Actions</th>
                     </tr>
                     </thead>
@@ -144,20 +178,39 @@ export function App() {
                         <tr key={`${job.namespace}-${job.name}`}>
                             <td style={tdStyle}>{job.namespace}</td>
                             <td style={tdStyle}>{job.name}</td>
-                            <td style={tdStyle}>{job.lastStatus?.type} - {job.lastStatus?.message}</td>
+                            <td style={tdStyle}>
+                                {job.state}
+                                {(job.currentRun?.failureReason || job.lastStatus?.message) &&
+                                    ` - ${job.currentRun?.failureReason || job.lastStatus?.message}`}
+                                {job.indexes && (
+                                    <div>
+                                        Indexes: {job.indexes.succeededCount}/{job.indexes.completions} succeeded
+                                        {job.indexes.failed && `, failed ${job.indexes.failed}`}
+                                        {job.indexes.retries?.map((retry) => (
+                                            <div key={retry.job}>Retry of {retry.index}: {retry.state}</div>
+                                        ))}
+                                    </div>
+                                )}
+                            </td>
                             <td style={tdStyle}>{job.lastSuccessfullyRunStarTime?.toLocaleTimeString()}</td>
                             <td style={tdStyle}>{job.lastSuccessfullyRunCompletionTime?.toLocaleTimeString()}</td>
+                            <td style={tdStyle}>{job.currentRun?.duration}</td>
+                            <td style={tdStyle}>
+                                {job.currentRun && `${job.currentRun.active} active, ${job.currentRun.succeeded} succeeded, ` +
+                                    `${job.currentRun.failed}/${job.currentRun.backoffLimit} failed`}
+                            </td>
+                            <td style={tdStyle}>{job.lastSuccess?.completionTime?.toLocaleString()}</td>
                             <td style={tdStyle}>
                                 <button
                                     onClick={() => runJob(job.namespace, job.name)}
-                                    disabled={job.lastStatus.type === "Running"}
+                                    disabled={job.state === "Running"}
                                     style={buttonStyle}
                                 >
                                     Run
                                 </button>
                                 <button
                                     onClick={() => killJob(job.namespace, job.name)}
-                                    disabled={job.lastStatus.type !== "Running"}
+                                    disabled={job.state !== "Running"}
                                     style={{
                                         ...buttonStyle,
                                         marginLeft: "0.5rem"
@@ -165,6 +218,42 @@ export function App() {
                                 >
                                     Kill
                                 </button>
+                                <button
+                                    onClick={() => restartJob(job.namespace, job.name)}
+                                    disabled={job.state !== "Running"}
+                                    style={{
+                                        ...buttonStyle,
+                                        marginLeft: "0.5rem"
+                                    }}
+                                >
+                                    Restart
+                                </button>
+                                {job.runId && (
+                                    <a
+                                        href={`/runs/${job.namespace}/${job.name}/${job.runId}/bundle`}
+                                        download
+                                        style={{
+                                            ...buttonStyle,
+                                            marginLeft: "0.5rem",
+                                            color: "inherit",
+                                            textDecoration: "none"
+                                        }}
+                                    >
+                                        Bundle
+                                    </a>
+                                )}
+                                {job.indexes && (
+                                    <button
+                                        onClick={() => retryFailedIndexes(job.namespace, job.name)}
+                                        disabled={job.state !== "Failed" || !job.indexes.failed}
+                                        style={{
+                                            ...buttonStyle,
+                                            marginLeft: "0.5rem"
+                                        }}
+                                    >
+                                        Retry failed
+                                    </button>
+                                )}
                             </td>
                         </tr>
                     ))}